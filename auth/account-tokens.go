@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	PurposeVerifyEmail   = "verify-email"
+	PurposeResetPassword = "reset-password"
+	PurposeTwoFactor     = "two-factor"
+)
+
+// the shortest ACCOUNT_SECRET accepted, in bytes
+const minAccountSecretLength = 32
+
+var errNoAccountSecret = errors.New("account secret is not loaded")
+
+// signs the tokens sent by email, nil until LoadAccountSecretFromEnv succeeds
+var accountSecret []byte
+
+// Loads ACCOUNT_SECRET env variable. Anyone could forge the tokens signed with an empty or a short secret,
+// so the server must not start without it.
+func LoadAccountSecretFromEnv() error {
+	secret := os.Getenv("ACCOUNT_SECRET")
+	if len(secret) < minAccountSecretLength {
+		return fmt.Errorf("ACCOUNT_SECRET must be set to at least %d bytes", minAccountSecretLength)
+	}
+
+	accountSecret = []byte(secret)
+	return nil
+}
+
+// Claims of the tokens sent by email and of two factor challenges. Tokens for one purpose can't be used for another,
+// the id (jti) is random and is recorded by the issuer to make the token single-use.
+type AccountTokenClaims struct {
+	jwt.StandardClaims
+	Purpose string `json:"purpose"`
+	Email   string `json:"email"`
+}
+
+// signs the token with ACCOUNT_SECRET env variable
+func GenerateAccountToken(userId int, purpose string, email string, expirationTime time.Time) (string, AccountTokenClaims, error) {
+	id, err := GenerateRandomToken()
+	if err != nil {
+		return "", AccountTokenClaims{}, err
+	}
+
+	claims := AccountTokenClaims{Purpose: purpose, Email: email}
+	claims.Id = id
+	claims.Subject = strconv.Itoa(userId)
+	claims.IssuedAt = time.Now().Unix()
+	claims.ExpiresAt = expirationTime.Unix()
+
+	if accountSecret == nil {
+		return "", claims, errNoAccountSecret
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(accountSecret)
+	return token, claims, err
+}
+
+// checks the signature, expiration time and purpose of the token
+func ParseAccountToken(token string, purpose string) (AccountTokenClaims, error) {
+	claims := AccountTokenClaims{}
+	parsed, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		if accountSecret == nil {
+			return nil, errNoAccountSecret
+		}
+		return accountSecret, nil
+	})
+
+	if err != nil || !parsed.Valid || claims.Purpose != purpose || claims.Id == "" {
+		return claims, errors.New("invalid or expired token")
+	}
+
+	return claims, nil
+}
@@ -1,6 +1,11 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"net/http"
 	"os"
 	"time"
@@ -9,109 +14,122 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func GenerateJWTToken(userId string, secretKeyEnvVariable string, expirationTime time.Time) (string, error) {
-	secretKey := os.Getenv(secretKeyEnvVariable)
+const (
+	AccessTokenDuration  = time.Minute * 15
+	RefreshTokenDuration = time.Hour * 24 * 21
+)
 
-	claims := jwt.StandardClaims{}
-	//claims["authorized"] = true
-	claims.Id = userId
-	claims.ExpiresAt = expirationTime.Unix()
-	at := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	token, err := at.SignedString([]byte(secretKey))
-	return token, err
+const defaultIssuer = "blog-webapp-server"
+
+// Claims of access tokens, the user id is in sub
+type Claims struct {
+	jwt.StandardClaims
+	Role      string `json:"role"`
+	SessionId int    `json:"sid"`
 }
 
-func CreateTokenPair(c *gin.Context, userId string) {
-	accessTokenDuration := time.Minute * 15
-	refreshTokenDuration := time.Hour * 24 * 21
+// JWT_ISSUER env variable, blog-webapp-server by default
+func issuer() string {
+	if value := os.Getenv("JWT_ISSUER"); value != "" {
+		return value
+	}
+	return defaultIssuer
+}
 
-	accessTokenExpirationTime := time.Now().Add(accessTokenDuration)
-	refreshTokenExpirationTime := time.Now().Add(refreshTokenDuration)
+// JWT_AUDIENCE env variable, the issuer by default
+func audience() string {
+	if value := os.Getenv("JWT_AUDIENCE"); value != "" {
+		return value
+	}
+	return issuer()
+}
 
-	accessToken, err := GenerateJWTToken(userId, "ACCESS_SECRET", accessTokenExpirationTime)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": err.Error(),
-		})
-		return
+// checks the time claims, the issuer, the audience and the subject
+func (claims Claims) Valid() error {
+	if err := claims.StandardClaims.Valid(); err != nil {
+		return err
 	}
 
-	refreshToken, err := GenerateJWTToken(userId, "REFRESH_SECRET", refreshTokenExpirationTime)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": err.Error(),
-		})
-		return
+	if !claims.VerifyIssuer(issuer(), true) {
+		return jwt.NewValidationError("token has an invalid issuer", jwt.ValidationErrorIssuer)
 	}
 
-	c.SetCookie("accessToken", accessToken, int(accessTokenDuration.Seconds()), "/", "", false, true)
-	c.SetCookie("refreshToken", refreshToken, int(refreshTokenDuration.Seconds()), "/", "", false, true)
+	if !claims.VerifyAudience(audience(), true) {
+		return jwt.NewValidationError("token has an invalid audience", jwt.ValidationErrorAudience)
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"access_token":  accessToken,
-		"refresh_token": refreshToken,
-	})
+	if claims.Subject == "" {
+		return jwt.NewValidationError("token has no subject", jwt.ValidationErrorClaimsInvalid)
+	}
+
+	return nil
 }
 
-func CheckForAuthorization(c *gin.Context, cookieName string, secretKeyEnvVariable string) (jwt.StandardClaims, bool) {
-	tokenString, err := c.Cookie(cookieName)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"message": err.Error(),
-		})
-		return jwt.StandardClaims{}, false
+// signs the claims with the current signing key, sets the issuer, the audience and the time claims
+func GenerateJWTToken(claims Claims, expirationTime time.Time) (string, error) {
+	if signingKey == nil {
+		return "", errors.New("signing keys are not loaded")
 	}
 
-	claims := jwt.StandardClaims{}
-	token, err := jwt.ParseWithClaims(tokenString, &claims, func(t *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv(secretKeyEnvVariable)), nil
-	})
-	if err != nil {
-		if err == jwt.ErrSignatureInvalid {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"message": err.Error(),
-			})
-			return jwt.StandardClaims{}, false
-		} else {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"message": err.Error(),
-			})
-			return jwt.StandardClaims{}, false
-		}
-	}
+	claims.Issuer = issuer()
+	claims.Audience = audience()
+	claims.IssuedAt = time.Now().Unix()
+	claims.ExpiresAt = expirationTime.Unix()
 
-	if !token.Valid {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"message": "access denied",
-		})
-		return jwt.StandardClaims{}, false
+	token := jwt.NewWithClaims(signingKey.Method, claims)
+	token.Header["kid"] = signingKey.Id
+	return token.SignedString(signingKey.PrivateKey)
+}
+
+// random url-safe string, used as refresh tokens and as ids of account tokens
+func GenerateRandomToken() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
 	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
 
-	return claims, true
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
 }
 
-// Won't send out a response on failure
-func SilentlyCheckForAuthorization(c *gin.Context, cookieName string, secretKeyEnvVariable string) (jwt.StandardClaims, bool) {
-	tokenString, err := c.Cookie(cookieName)
+// Issues an access token for the session and sends it out with the session's refresh token
+func CreateTokenPair(c *gin.Context, userId string, role string, sessionId int, refreshToken string) {
+	accessTokenExpirationTime := time.Now().Add(AccessTokenDuration)
+
+	claims := Claims{}
+	claims.Subject = userId
+	claims.Role = role
+	claims.SessionId = sessionId
+
+	accessToken, err := GenerateJWTToken(claims, accessTokenExpirationTime)
 	if err != nil {
-		return jwt.StandardClaims{}, false
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
 	}
 
-	claims := jwt.StandardClaims{}
-	token, err := jwt.ParseWithClaims(tokenString, &claims, func(t *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv(secretKeyEnvVariable)), nil
+	c.SetCookie("accessToken", accessToken, int(AccessTokenDuration.Seconds()), "/", "", false, true)
+	c.SetCookie("refreshToken", refreshToken, int(RefreshTokenDuration.Seconds()), "/", "", false, true)
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
 	})
-	if err != nil {
-		if err == jwt.ErrSignatureInvalid {
-			return jwt.StandardClaims{}, false
-		} else {
-			return jwt.StandardClaims{}, false
-		}
-	}
+}
 
-	if !token.Valid {
-		return jwt.StandardClaims{}, false
-	}
+func ClearTokens(c *gin.Context) {
+	c.SetCookie("accessToken", "", -1, "/", "", false, true)
+	c.SetCookie("refreshToken", "", -1, "/", "", false, true)
+}
 
-	return claims, true
+// checks the signature with the verification key in the kid header, and the claims
+func ParseAccessToken(tokenString string) (Claims, error) {
+	claims := Claims{}
+	parser := jwt.Parser{ValidMethods: []string{jwt.SigningMethodRS256.Alg(), SigningMethodEdDSA.Alg()}}
+	_, err := parser.ParseWithClaims(tokenString, &claims, verificationKey)
+	return claims, err
 }
@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// key used to sign or verify access tokens, Id is sent in the kid header
+type key struct {
+	Id         string
+	Method     jwt.SigningMethod
+	PrivateKey interface{} // nil for keys that are only used for verification
+	PublicKey  interface{}
+}
+
+// the key new access tokens are signed with, and all keys access tokens are accepted from
+var (
+	signingKey       *key
+	verificationKeys = map[string]*key{}
+)
+
+// One key in the JSON Web Key Set
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// Loads the keys from PEM files in JWT_KEYS_DIRECTORY env variable, the name of the file without .pem
+// is the key id. RSA keys sign with RS256, Ed25519 keys with EdDSA. Tokens are signed with the private key
+// in JWT_SIGNING_KEY_ID, and accepted from every key in the directory, public keys included,
+// so the previous key can stay there while its tokens expire.
+// Without the directory a new Ed25519 key is generated, access tokens stop working on restart.
+func LoadKeysFromEnv() error {
+	directory := os.Getenv("JWT_KEYS_DIRECTORY")
+	if directory == "" {
+		log.Printf("JWT_KEYS_DIRECTORY is not set, access tokens are signed with a temporary key")
+		return generateTemporaryKey()
+	}
+
+	files, err := filepath.Glob(filepath.Join(directory, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	keys := map[string]*key{}
+	for _, file := range files {
+		id := strings.TrimSuffix(filepath.Base(file), ".pem")
+		loaded, err := loadKey(id, file)
+		if err != nil {
+			return fmt.Errorf("key '%s': %s", id, err.Error())
+		}
+		keys[id] = loaded
+	}
+
+	signingKeyId := os.Getenv("JWT_SIGNING_KEY_ID")
+	signing, ok := keys[signingKeyId]
+	if !ok || signing.PrivateKey == nil {
+		return fmt.Errorf("no private key '%s' in %s, set JWT_SIGNING_KEY_ID", signingKeyId, directory)
+	}
+
+	signingKey = signing
+	verificationKeys = keys
+	return nil
+}
+
+func generateTemporaryKey() error {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	id, err := GenerateRandomToken()
+	if err != nil {
+		return err
+	}
+
+	signingKey = &key{Id: id[:16], Method: SigningMethodEdDSA, PrivateKey: privateKey, PublicKey: publicKey}
+	verificationKeys = map[string]*key{signingKey.Id: signingKey}
+	return nil
+}
+
+// reads a PKCS #8 or PKCS #1 private key, or a PKIX public key
+func loadKey(id string, file string) (*key, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("not a PEM file")
+	}
+
+	var privateKey, publicKey interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		publicKey, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block '%s'", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch typed := privateKey.(type) {
+	case *rsa.PrivateKey:
+		publicKey = &typed.PublicKey
+	case ed25519.PrivateKey:
+		publicKey = typed.Public()
+	}
+
+	switch publicKey.(type) {
+	case *rsa.PublicKey:
+		return &key{Id: id, Method: jwt.SigningMethodRS256, PrivateKey: privateKey, PublicKey: publicKey}, nil
+	case ed25519.PublicKey:
+		return &key{Id: id, Method: SigningMethodEdDSA, PrivateKey: privateKey, PublicKey: publicKey}, nil
+	}
+
+	return nil, errors.New("only RSA and Ed25519 keys are supported")
+}
+
+// looks up the key by the kid header, the token must be signed with the key's method
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	id, _ := token.Header["kid"].(string)
+	found, ok := verificationKeys[id]
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+
+	if token.Method.Alg() != found.Method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+
+	return found.PublicKey, nil
+}
+
+// public keys of all verification keys, for other services to verify access tokens
+func GetJSONWebKeySet() JSONWebKeySet {
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, verification := range verificationKeys {
+		jwk := JSONWebKey{Kid: verification.Id, Use: "sig", Alg: verification.Method.Alg()}
+		switch publicKey := verification.PublicKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].Kid < set.Keys[j].Kid
+	})
+	return set
+}
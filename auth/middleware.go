@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+)
+
+const principalKey = "principal"
+
+// accessTokenOnly is the scope of routes personal access tokens can't be used for
+const accessTokenOnly = "-"
+
+var errNotSignedIn = errors.New("not signed in")
+
+// The authenticated client of the request, the zero value is an anonymous client
+type Principal struct {
+	UserId    int
+	Role      string
+	TokenType string    // TokenTypeAccess or TokenTypePersonal
+	SessionId int       // 0 for personal access tokens
+	TokenId   int       // id of the personal access token, 0 for access tokens
+	Scopes    []string  // scopes of personal access tokens
+	ExpiresAt time.Time // zero if the credentials don't expire
+}
+
+func (principal Principal) IsAnonymous() bool {
+	return principal.UserId == 0
+}
+
+// returns the principal set by the middleware, anonymous if the client isn't signed in
+func GetPrincipal(c *gin.Context) Principal {
+	if value, ok := c.Get(principalKey); ok {
+		return value.(Principal)
+	}
+	return Principal{}
+}
+
+// Middleware for routes that require the client to be signed in, with the access token in the cookie
+// or in the Authorization header, or with a personal access token.
+// Personal access tokens can only be used for GET requests, with the read scope.
+func Required() gin.HandlerFunc {
+	return authenticate("", true)
+}
+
+// Required, personal access tokens need the scope for any request method
+func RequiredScope(scope string) gin.HandlerFunc {
+	return authenticate(scope, true)
+}
+
+// Required, for account management routes (sessions, 2FA, tokens, etc.),
+// which personal access tokens can't be used for even with GET requests
+func RequiredSession() gin.HandlerFunc {
+	return authenticate(accessTokenOnly, true)
+}
+
+// Middleware for routes that are public but show more to signed in clients,
+// invalid credentials are treated as anonymous
+func Optional() gin.HandlerFunc {
+	return authenticate("", false)
+}
+
+func authenticate(scope string, required bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, status, err := getPrincipal(c, scope)
+		if err != nil {
+			if required {
+				c.AbortWithStatusJSON(status, gin.H{
+					"message": err.Error(),
+				})
+			}
+			return
+		}
+
+		c.Set(principalKey, principal)
+	}
+}
+
+// returns the status to respond with on failure
+func getPrincipal(c *gin.Context, scope string) (Principal, int, error) {
+	tokenString, ok := bearerToken(c)
+	if ok && strings.HasPrefix(tokenString, PersonalAccessTokenPrefix) {
+		return checkPersonalAccessToken(c, tokenString, scope)
+	}
+
+	if !ok {
+		cookie, err := c.Cookie("accessToken")
+		if err != nil || cookie == "" {
+			return Principal{}, http.StatusUnauthorized, errNotSignedIn
+		}
+		tokenString = cookie
+	}
+
+	claims, err := ParseAccessToken(tokenString)
+	if err != nil {
+		status := http.StatusBadRequest
+		if validationError, ok := err.(*jwt.ValidationError); ok && validationError.Errors&jwt.ValidationErrorMalformed == 0 {
+			status = http.StatusUnauthorized
+		}
+		return Principal{}, status, err
+	}
+
+	userId, err := strconv.Atoi(claims.Subject)
+	if err != nil || userId <= 0 {
+		return Principal{}, http.StatusUnauthorized, errors.New("access denied")
+	}
+
+	principal := Principal{
+		UserId:    userId,
+		Role:      claims.Role,
+		TokenType: TokenTypeAccess,
+		SessionId: claims.SessionId,
+	}
+	if claims.ExpiresAt != 0 {
+		principal.ExpiresAt = time.Unix(claims.ExpiresAt, 0)
+	}
+	return principal, 0, nil
+}
+
+// returns the token from the Authorization: Bearer header
+func bearerToken(c *gin.Context) (string, bool) {
+	header := c.GetHeader("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return "", false
+	}
+
+	token := strings.TrimSpace(header[7:])
+	return token, token != ""
+}
+
+// checks the personal access token and its scope for the route
+func checkPersonalAccessToken(c *gin.Context, token string, scope string) (Principal, int, error) {
+	if personalAccessTokenResolver == nil {
+		return Principal{}, http.StatusUnauthorized, errors.New("personal access tokens are not supported")
+	}
+
+	principal, err := personalAccessTokenResolver(token)
+	if err != nil {
+		return Principal{}, http.StatusUnauthorized, err
+	}
+
+	if scope == accessTokenOnly {
+		return Principal{}, http.StatusForbidden, errors.New("personal access tokens can't be used for this endpoint")
+	}
+
+	if scope == "" {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			return Principal{}, http.StatusForbidden, errors.New("personal access tokens can't be used for this endpoint")
+		}
+		scope = ScopeRead
+	}
+
+	if !principal.HasScope(scope) {
+		return Principal{}, http.StatusForbidden, errors.New("token doesn't have the '" + scope + "' scope")
+	}
+
+	return principal, 0, nil
+}
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Kept in a cookie while the user signs in at an OpenID Connect provider, ties the callback
+// to the browser that started the sign in. LinkUserId is set when a signed in user links the provider.
+type OidcStateClaims struct {
+	jwt.StandardClaims
+	Provider     string `json:"provider"`
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+	LinkUserId   int    `json:"link_user_id,omitempty"`
+}
+
+// signs the state with ACCOUNT_SECRET env variable, see LoadAccountSecretFromEnv
+func GenerateOidcStateToken(claims OidcStateClaims, expirationTime time.Time) (string, error) {
+	if accountSecret == nil {
+		return "", errNoAccountSecret
+	}
+
+	claims.ExpiresAt = expirationTime.Unix()
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(accountSecret)
+}
+
+func ParseOidcStateToken(token string) (OidcStateClaims, error) {
+	claims := OidcStateClaims{}
+	parsed, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		if accountSecret == nil {
+			return nil, errNoAccountSecret
+		}
+		return accountSecret, nil
+	})
+
+	if err != nil || !parsed.Valid {
+		return claims, errors.New("invalid or expired sign in state")
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,46 @@
+package auth
+
+const PersonalAccessTokenPrefix = "pat_"
+
+const (
+	TokenTypeAccess   = "access"
+	TokenTypePersonal = "personal"
+)
+
+const (
+	ScopeRead          = "read"
+	ScopeWriteArticles = "write:articles"
+	ScopeAdmin         = "admin"
+)
+
+var scopes = []string{ScopeRead, ScopeWriteArticles, ScopeAdmin}
+
+func IsValidScope(scope string) bool {
+	for _, valid := range scopes {
+		if scope == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// access tokens have every scope, every scope of personal access tokens includes read
+func (principal Principal) HasScope(scope string) bool {
+	if principal.TokenType != TokenTypePersonal {
+		return true
+	}
+
+	for _, granted := range principal.Scopes {
+		if granted == scope || scope == ScopeRead {
+			return true
+		}
+	}
+	return false
+}
+
+// looks up personal access tokens, personal access tokens are rejected until it's set
+var personalAccessTokenResolver func(token string) (Principal, error)
+
+func SetPersonalAccessTokenResolver(resolver func(token string) (Principal, error)) {
+	personalAccessTokenResolver = resolver
+}
@@ -0,0 +1,284 @@
+package controller
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/danielblagy/blog-webapp-server/auth"
+	"github.com/danielblagy/blog-webapp-server/entity"
+	"github.com/danielblagy/blog-webapp-server/pagination"
+	"github.com/danielblagy/blog-webapp-server/service"
+	"github.com/gin-gonic/gin"
+)
+
+type AdminController interface {
+	DeleteUser(c *gin.Context)
+	SuspendUser(c *gin.Context)
+	UnsuspendUser(c *gin.Context)
+	SetUserRole(c *gin.Context)
+	UnpublishArticle(c *gin.Context)
+	DeleteArticle(c *gin.Context)
+	GetAuditLog(c *gin.Context)
+}
+
+type AdminControllerProvider struct {
+	usersService    service.UsersService
+	articlesService service.ArticlesService
+	auditService    service.AuditService
+}
+
+func CreateAdminController(usersService service.UsersService, articlesService service.ArticlesService, auditService service.AuditService) AdminController {
+	return &AdminControllerProvider{
+		usersService:    usersService,
+		articlesService: articlesService,
+		auditService:    auditService,
+	}
+}
+
+// Checks that the signed in client has at least the required role, sends out a response on failure.
+// The role in the access token is checked against the database, so demoted or suspended users
+// lose access right away, not when their access token expires.
+func (controller *AdminControllerProvider) authorize(c *gin.Context, requiredRole string) (entity.User, bool) {
+	principal := auth.GetPrincipal(c)
+
+	if !entity.HasRole(principal.Role, requiredRole) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "access denied",
+		})
+		return entity.User{}, false
+	}
+
+	actor, err := controller.usersService.GetAccount(strconv.Itoa(principal.UserId))
+	if err != nil || actor.Suspended || !entity.HasRole(actor.Role, requiredRole) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "access denied",
+		})
+		return entity.User{}, false
+	}
+
+	return actor, true
+}
+
+// sends out a response if the id of the user or the article in the path is not a number
+func parseTargetId(c *gin.Context, targetType string) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "invalid " + targetType + " id",
+		})
+		return 0, false
+	}
+
+	return id, true
+}
+
+// gets the user the action is performed on, users can't perform admin actions on themselves,
+// sends out a response on failure
+func (controller *AdminControllerProvider) getTargetUser(c *gin.Context, actor entity.User) (entity.User, bool) {
+	id, ok := parseTargetId(c, "user")
+	if !ok {
+		return entity.User{}, false
+	}
+
+	target, err := controller.usersService.GetAccount(strconv.Itoa(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "user was not found",
+		})
+		return target, false
+	}
+
+	if target.Id == actor.Id {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "access denied",
+		})
+		return target, false
+	}
+
+	return target, true
+}
+
+// the action is done already, so failing to record it is only logged
+func (controller *AdminControllerProvider) record(c *gin.Context, actor entity.User, action string, targetType string, targetId int, details string) {
+	actorId := actor.Id
+	if err := controller.auditService.Record(&actorId, action, targetType, targetId, details); err != nil {
+		log.Printf("Failed to record %s action of user %d in the audit log: %s", action, actor.Id, err.Error())
+	}
+}
+
+func (controller *AdminControllerProvider) DeleteUser(c *gin.Context) {
+	actor, ok := controller.authorize(c, entity.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	target, ok := controller.getTargetUser(c, actor)
+	if !ok {
+		return
+	}
+
+	user, err := controller.usersService.Delete(strconv.Itoa(target.Id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	controller.record(c, actor, service.AuditActionDeleteUser, "user", target.Id, target.Login)
+
+	user.AdminView = true
+	c.JSON(http.StatusOK, user)
+}
+
+func (controller *AdminControllerProvider) SuspendUser(c *gin.Context) {
+	controller.setSuspended(c, true)
+}
+
+func (controller *AdminControllerProvider) UnsuspendUser(c *gin.Context) {
+	controller.setSuspended(c, false)
+}
+
+func (controller *AdminControllerProvider) setSuspended(c *gin.Context, suspended bool) {
+	actor, ok := controller.authorize(c, entity.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	target, ok := controller.getTargetUser(c, actor)
+	if !ok {
+		return
+	}
+
+	user, err := controller.usersService.SetSuspended(strconv.Itoa(target.Id), suspended)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	action := service.AuditActionSuspendUser
+	if !suspended {
+		action = service.AuditActionUnsuspendUser
+	}
+	controller.record(c, actor, action, "user", target.Id, target.Login)
+
+	user.AdminView = true
+	c.JSON(http.StatusOK, user)
+}
+
+func (controller *AdminControllerProvider) SetUserRole(c *gin.Context) {
+	actor, ok := controller.authorize(c, entity.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	target, ok := controller.getTargetUser(c, actor)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Role string `json:"role"`
+	}
+	if err := c.BindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if !entity.IsValidRole(body.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "invalid role",
+		})
+		return
+	}
+
+	user, err := controller.usersService.SetRole(strconv.Itoa(target.Id), body.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	controller.record(c, actor, service.AuditActionChangeRole, "user", target.Id, target.Role+" -> "+body.Role)
+
+	user.AdminView = true
+	c.JSON(http.StatusOK, user)
+}
+
+func (controller *AdminControllerProvider) UnpublishArticle(c *gin.Context) {
+	actor, ok := controller.authorize(c, entity.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	id, ok := parseTargetId(c, "article")
+	if !ok {
+		return
+	}
+
+	article, err := controller.articlesService.Unpublish(strconv.Itoa(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	controller.record(c, actor, service.AuditActionUnpublishArticle, "article", article.Id, article.Title)
+
+	c.JSON(http.StatusOK, article)
+}
+
+func (controller *AdminControllerProvider) DeleteArticle(c *gin.Context) {
+	actor, ok := controller.authorize(c, entity.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	id, ok := parseTargetId(c, "article")
+	if !ok {
+		return
+	}
+
+	article, err := controller.articlesService.Delete(strconv.Itoa(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	controller.record(c, actor, service.AuditActionDeleteArticle, "article", article.Id, article.Title)
+
+	c.JSON(http.StatusOK, article)
+}
+
+func (controller *AdminControllerProvider) GetAuditLog(c *gin.Context) {
+	if _, ok := controller.authorize(c, entity.RoleAdmin); !ok {
+		return
+	}
+
+	params, err := pagination.ParseParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	entries, cursors, err := controller.auditService.GetAll(params)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, pagination.Page{Items: entries, Cursors: cursors})
+}
@@ -6,6 +6,8 @@ import (
 
 	"github.com/danielblagy/blog-webapp-server/auth"
 	"github.com/danielblagy/blog-webapp-server/entity"
+	"github.com/danielblagy/blog-webapp-server/markdown"
+	"github.com/danielblagy/blog-webapp-server/pagination"
 	"github.com/danielblagy/blog-webapp-server/service"
 	"github.com/gin-gonic/gin"
 )
@@ -13,6 +15,7 @@ import (
 type ArticlesController interface {
 	GetAll(c *gin.Context)
 	GetById(c *gin.Context)
+	GetBySlug(c *gin.Context)
 	Create(c *gin.Context)
 	Update(c *gin.Context)
 	Delete(c *gin.Context)
@@ -20,21 +23,56 @@ type ArticlesController interface {
 	Unsave(c *gin.Context)
 	GetSaves(c *gin.Context)
 	IsSaved(c *gin.Context)
+	UpdateSave(c *gin.Context)
 	ForYou(c *gin.Context)
 }
 
 type ArticlesControllerProvider struct {
-	service service.ArticlesService
+	service       service.ArticlesService
+	usersService  service.UsersService
+	mediaService  service.MediaService
+	seriesService service.SeriesService
 }
 
-func CreateArticlesController(service service.ArticlesService) ArticlesController {
+func CreateArticlesController(service service.ArticlesService, usersService service.UsersService, mediaService service.MediaService, seriesService service.SeriesService) ArticlesController {
 	return &ArticlesControllerProvider{
-		service: service,
+		service:       service,
+		usersService:  usersService,
+		mediaService:  mediaService,
+		seriesService: seriesService,
 	}
 }
 
+// only users with a verified email can publish or schedule articles, sends out a response if the user can't
+func (controller *ArticlesControllerProvider) checkCanPublish(c *gin.Context, userId string) bool {
+	user, err := controller.usersService.GetAccount(userId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return false
+	}
+
+	if !user.EmailVerified {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "email must be verified to publish articles",
+		})
+		return false
+	}
+
+	return true
+}
+
 func (controller *ArticlesControllerProvider) GetAll(c *gin.Context) {
-	articles, err := controller.service.GetAll()
+	params, err := pagination.ParseParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	articles, cursors, err := controller.service.GetAll(c.Query("tag"), params, auth.GetPrincipal(c))
 
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
@@ -43,19 +81,60 @@ func (controller *ArticlesControllerProvider) GetAll(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, articles)
+	c.JSON(http.StatusOK, pagination.Page{Items: articles, Cursors: cursors})
 }
 
 func (controller *ArticlesControllerProvider) GetById(c *gin.Context) {
-	claims, ok := auth.SilentlyCheckForAuthorization(c, "accessToken", "ACCESS_SECRET")
-	userId := "-1"
-	if ok {
-		userId = claims.Id
+	principal := auth.GetPrincipal(c)
+
+	// private articles are hidden from anonymous users and other users
+	article, err := controller.service.GetById(c.Param("id"), principal)
+
+	if err != nil {
+		if err.Error() == "article is private" {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
 	}
 
-	// if user is unauthorized, userId will be '-1' (used in the service to hide private articles)
+	controller.respondWithArticle(c, article, principal)
+}
 
-	article, err := controller.service.GetById(c.Param("id"), userId)
+// The permalink of the article, /@login/slug. Links with a previous slug of the article
+// are redirected to the current one with 301 Moved Permanently.
+func (controller *ArticlesControllerProvider) GetBySlug(c *gin.Context) {
+	principal := auth.GetPrincipal(c)
+
+	author, err := controller.usersService.GetByLogin(c.Param("login"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "user was not found",
+		})
+		return
+	}
+
+	slug := c.Param("slug")
+
+	article, err := controller.service.GetBySlug(author.Id, slug, principal)
+	if err != nil && err.Error() == "article was not found" {
+		article, err = controller.service.GetByPreviousSlug(author.Id, slug, principal)
+		if err == nil {
+			location := "/@" + author.Login + "/" + article.Slug
+			if c.Request.URL.RawQuery != "" {
+				location += "?" + c.Request.URL.RawQuery
+			}
+
+			c.Redirect(http.StatusMovedPermanently, location)
+			return
+		}
+	}
 
 	if err != nil {
 		if err.Error() == "article is private" {
@@ -71,18 +150,40 @@ func (controller *ArticlesControllerProvider) GetById(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, article)
+	controller.respondWithArticle(c, article, principal)
 }
 
-func (controller *ArticlesControllerProvider) Create(c *gin.Context) {
-	claims, ok := auth.CheckForAuthorization(c, "accessToken", "ACCESS_SECRET")
-	if !ok {
+// sends out the article as json, or its html or content if 'format' query parameter is set
+func (controller *ArticlesControllerProvider) respondWithArticle(c *gin.Context, article entity.Article, principal auth.Principal) {
+	// previous and next articles in the series the article is in
+	series, err := controller.seriesService.GetNavigation(article.Id, principal)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
 		return
 	}
+	article.Series = series
+
+	// 'format' query parameter switches the response from json to just the rendered html or the raw content
+	switch c.Query("format") {
+	case "html":
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(article.Html))
+	case "raw":
+		contentType := "text/plain; charset=utf-8"
+		if article.Format == markdown.Markdown {
+			contentType = "text/markdown; charset=utf-8"
+		}
+		c.Data(http.StatusOK, contentType, []byte(article.Content))
+	default:
+		c.JSON(http.StatusOK, article)
+	}
+}
 
-	// if a token is provided and valid, run update logic
+func (controller *ArticlesControllerProvider) Create(c *gin.Context) {
+	principal := auth.GetPrincipal(c)
 
-	userId := claims.Id
+	userId := strconv.Itoa(principal.UserId)
 
 	var newArticle entity.Article
 	if err := c.BindJSON(&newArticle); err != nil {
@@ -95,20 +196,51 @@ func (controller *ArticlesControllerProvider) Create(c *gin.Context) {
 	// TODO: multiple options: 	1) leave it at that
 	//							2) let client set article's author_id field and check if it matches the one in the accessToken
 	//							3) use EditableArticleData
-	newArticle.AuthorId, _ = strconv.Atoi(userId)
+	newArticle.AuthorId = principal.UserId
 
-	_, err := controller.service.GetByTitle(strconv.Itoa(newArticle.AuthorId), newArticle.Title)
-	if err == nil {
-		c.JSON(http.StatusConflict, gin.H{
-			"message": "user already has article with this title",
+	tags, err := service.NormalizeTags(newArticle.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
 		})
 		return
 	}
+	newArticle.Tags = tags
+
+	if err := controller.mediaService.CheckOwner(principal.UserId, newArticle.MediaIds); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if newArticle.Format == "" {
+		newArticle.Format = markdown.Plain
+	}
+
+	if !markdown.IsValidFormat(newArticle.Format) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "invalid article format",
+		})
+		return
+	}
+
+	if (newArticle.Published || newArticle.PublishAt != nil) && !controller.checkCanPublish(c, userId) {
+		return
+	}
 
 	// TODO : test title validation
 
+	// titles are unique for the author, the database rejects duplicates
 	createdArticle, err := controller.service.Create(newArticle)
 	if err != nil {
+		if err.Error() == "user already has article with this title" {
+			c.JSON(http.StatusConflict, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"message": err.Error(),
 		})
@@ -119,17 +251,12 @@ func (controller *ArticlesControllerProvider) Create(c *gin.Context) {
 }
 
 func (controller *ArticlesControllerProvider) Update(c *gin.Context) {
-	claims, ok := auth.CheckForAuthorization(c, "accessToken", "ACCESS_SECRET")
-	if !ok {
-		return
-	}
-
-	// if a token is provided and valid, run update logic
+	principal := auth.GetPrincipal(c)
 
-	userId := claims.Id
+	userId := strconv.Itoa(principal.UserId)
 	articleId := c.Param("id")
 
-	article, err := controller.service.GetById(articleId, userId)
+	article, err := controller.service.GetById(articleId, principal)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"message": err.Error(),
@@ -138,8 +265,7 @@ func (controller *ArticlesControllerProvider) Update(c *gin.Context) {
 	}
 
 	// ensure the user owns the article
-	userIdInt, _ := strconv.Atoi(userId)
-	if userIdInt != article.AuthorId {
+	if principal.UserId != article.AuthorId {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"message": "access denied",
 		})
@@ -154,8 +280,47 @@ func (controller *ArticlesControllerProvider) Update(c *gin.Context) {
 		return
 	}
 
+	if updatedData.Tags != nil {
+		tags, err := service.NormalizeTags(updatedData.Tags)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+		updatedData.Tags = tags
+	}
+
+	if updatedData.MediaIds != nil {
+		if err := controller.mediaService.CheckOwner(principal.UserId, updatedData.MediaIds); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+
+	if updatedData.Format != "" && !markdown.IsValidFormat(updatedData.Format) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "invalid article format",
+		})
+		return
+	}
+
+	// articles published before the email became unverified stay published
+	if ((updatedData.Published && !article.Published) || updatedData.PublishAt.Value != nil) && !controller.checkCanPublish(c, userId) {
+		return
+	}
+
 	updatedArticle, err := controller.service.Update(articleId, updatedData)
 	if err != nil {
+		if err.Error() == "user already has article with this title" {
+			c.JSON(http.StatusConflict, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+
 		c.JSON(http.StatusNotFound, gin.H{
 			"message": err.Error(),
 		})
@@ -166,17 +331,11 @@ func (controller *ArticlesControllerProvider) Update(c *gin.Context) {
 }
 
 func (controller *ArticlesControllerProvider) Delete(c *gin.Context) {
-	claims, ok := auth.CheckForAuthorization(c, "accessToken", "ACCESS_SECRET")
-	if !ok {
-		return
-	}
+	principal := auth.GetPrincipal(c)
 
-	// if a token is provided and valid, run update logic
-
-	userId := claims.Id
 	articleId := c.Param("id")
 
-	article, err := controller.service.GetById(articleId, userId)
+	article, err := controller.service.GetById(articleId, principal)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"message": err.Error(),
@@ -185,8 +344,7 @@ func (controller *ArticlesControllerProvider) Delete(c *gin.Context) {
 	}
 
 	// ensure the user owns the article
-	userIdInt, _ := strconv.Atoi(userId)
-	if userIdInt != article.AuthorId {
+	if principal.UserId != article.AuthorId {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"message": "access denied",
 		})
@@ -206,18 +364,13 @@ func (controller *ArticlesControllerProvider) Delete(c *gin.Context) {
 }
 
 func (controller *ArticlesControllerProvider) Save(c *gin.Context) {
-	claims, ok := auth.CheckForAuthorization(c, "accessToken", "ACCESS_SECRET")
-	if !ok {
-		return
-	}
+	principal := auth.GetPrincipal(c)
 
-	// if a token is provided and valid, run 'me' logic
-
-	userId := claims.Id
+	userId := strconv.Itoa(principal.UserId)
 
 	// check if the article to save exists
 	articleToSave := c.Param("id")
-	article, err := controller.service.GetById(articleToSave, userId)
+	article, err := controller.service.GetById(articleToSave, principal)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"message": "article to save was not found",
@@ -236,18 +389,13 @@ func (controller *ArticlesControllerProvider) Save(c *gin.Context) {
 }
 
 func (controller *ArticlesControllerProvider) Unsave(c *gin.Context) {
-	claims, ok := auth.CheckForAuthorization(c, "accessToken", "ACCESS_SECRET")
-	if !ok {
-		return
-	}
-
-	// if a token is provided and valid, run 'me' logic
+	principal := auth.GetPrincipal(c)
 
-	userId := claims.Id
+	userId := strconv.Itoa(principal.UserId)
 
 	// check if the article to unsave exists
 	articleToUnsave := c.Param("id")
-	article, err := controller.service.GetById(articleToUnsave, userId)
+	article, err := controller.service.GetById(articleToUnsave, principal)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"message": "article to unsave was not found",
@@ -265,17 +413,17 @@ func (controller *ArticlesControllerProvider) Unsave(c *gin.Context) {
 	c.JSON(http.StatusOK, article)
 }
 
+// 'unread' query parameter set to true returns only the articles that are not marked as read
 func (controller *ArticlesControllerProvider) GetSaves(c *gin.Context) {
-	claims, ok := auth.CheckForAuthorization(c, "accessToken", "ACCESS_SECRET")
-	if !ok {
+	params, err := pagination.ParseParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
 		return
 	}
 
-	// if a token is provided and valid, run 'saves' logic
-
-	userId := claims.Id
-
-	articles, err := controller.service.GetSaves(userId)
+	articles, cursors, err := controller.service.GetSaves(auth.GetPrincipal(c), c.Query("unread") == "true", params)
 
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
@@ -284,18 +432,11 @@ func (controller *ArticlesControllerProvider) GetSaves(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, articles)
+	c.JSON(http.StatusOK, pagination.Page{Items: articles, Cursors: cursors})
 }
 
 func (controller *ArticlesControllerProvider) IsSaved(c *gin.Context) {
-	claims, ok := auth.CheckForAuthorization(c, "accessToken", "ACCESS_SECRET")
-	if !ok {
-		return
-	}
-
-	// if a token is provided and valid, run 'saves' logic
-
-	userId := claims.Id
+	userId := strconv.Itoa(auth.GetPrincipal(c).UserId)
 
 	articleToCheck := c.Param("id")
 	isSaved, err := controller.service.IsSaved(userId, articleToCheck)
@@ -310,17 +451,53 @@ func (controller *ArticlesControllerProvider) IsSaved(c *gin.Context) {
 	c.JSON(http.StatusOK, isSaved)
 }
 
-func (controller *ArticlesControllerProvider) ForYou(c *gin.Context) {
-	claims, ok := auth.CheckForAuthorization(c, "accessToken", "ACCESS_SECRET")
-	if !ok {
+// sets the note and the read state of a saved article
+func (controller *ArticlesControllerProvider) UpdateSave(c *gin.Context) {
+	userId := strconv.Itoa(auth.GetPrincipal(c).UserId)
+
+	var updatedData entity.EditableSaveData
+	if err := c.BindJSON(&updatedData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
 		return
 	}
 
-	// if a token is provided and valid, run 'saves' logic
+	if updatedData.Note != nil && !service.IsValidSaveNote(*updatedData.Note) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "note is too long",
+		})
+		return
+	}
 
-	userId := claims.Id
+	save, err := controller.service.UpdateSave(userId, c.Param("id"), updatedData)
+	if err != nil {
+		if err.Error() == "article is not saved" {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, save)
+}
+
+func (controller *ArticlesControllerProvider) ForYou(c *gin.Context) {
+	params, err := pagination.ParseParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
 
-	articles, err := controller.service.ForYou(userId)
+	articles, cursors, err := controller.service.ForYou(auth.GetPrincipal(c), params)
 
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
@@ -329,5 +506,5 @@ func (controller *ArticlesControllerProvider) ForYou(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, articles)
+	c.JSON(http.StatusOK, pagination.Page{Items: articles, Cursors: cursors})
 }
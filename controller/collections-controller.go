@@ -0,0 +1,403 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/danielblagy/blog-webapp-server/auth"
+	"github.com/danielblagy/blog-webapp-server/entity"
+	"github.com/danielblagy/blog-webapp-server/service"
+	"github.com/gin-gonic/gin"
+)
+
+type CollectionsController interface {
+	GetById(c *gin.Context)
+	GetByUser(c *gin.Context)
+	GetFollowing(c *gin.Context)
+	Create(c *gin.Context)
+	Update(c *gin.Context)
+	Delete(c *gin.Context)
+	AddArticle(c *gin.Context)
+	RemoveArticle(c *gin.Context)
+	Reorder(c *gin.Context)
+	Follow(c *gin.Context)
+	Unfollow(c *gin.Context)
+}
+
+type CollectionsControllerProvider struct {
+	service         service.CollectionsService
+	articlesService service.ArticlesService
+}
+
+func CreateCollectionsController(service service.CollectionsService, articlesService service.ArticlesService) CollectionsController {
+	return &CollectionsControllerProvider{
+		service:         service,
+		articlesService: articlesService,
+	}
+}
+
+// sends out a response if the id in the path is not a number
+func parseCollectionId(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "invalid collection id",
+		})
+		return 0, false
+	}
+
+	return id, true
+}
+
+// sends out a response if the collection can't be viewed
+func (controller *CollectionsControllerProvider) getCollection(c *gin.Context, principal auth.Principal) (entity.Collection, bool) {
+	id, ok := parseCollectionId(c)
+	if !ok {
+		return entity.Collection{}, false
+	}
+
+	collection, err := controller.service.GetById(id, principal)
+	if err != nil {
+		if err.Error() == "collection is private" {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"message": err.Error(),
+			})
+			return collection, false
+		}
+
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return collection, false
+	}
+
+	return collection, true
+}
+
+// sends out a response if the collection doesn't exist or doesn't belong to the user
+func (controller *CollectionsControllerProvider) getOwnCollection(c *gin.Context, principal auth.Principal) (entity.Collection, bool) {
+	collection, ok := controller.getCollection(c, principal)
+	if !ok {
+		return collection, false
+	}
+
+	// ensure the user owns the collection
+	if principal.UserId != collection.OwnerId {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"message": "access denied",
+		})
+		return collection, false
+	}
+
+	return collection, true
+}
+
+// public collections can be viewed by anyone with the link, notes and read states are only sent to the owner
+func (controller *CollectionsControllerProvider) GetById(c *gin.Context) {
+	collection, ok := controller.getCollection(c, auth.GetPrincipal(c))
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, collection)
+}
+
+func (controller *CollectionsControllerProvider) GetByUser(c *gin.Context) {
+	collections, err := controller.service.GetByOwner(c.Param("id"), auth.GetPrincipal(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, collections)
+}
+
+// returns the collections the authorized user follows
+func (controller *CollectionsControllerProvider) GetFollowing(c *gin.Context) {
+	collections, err := controller.service.GetFollowing(auth.GetPrincipal(c).UserId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, collections)
+}
+
+func (controller *CollectionsControllerProvider) Create(c *gin.Context) {
+	var newCollection entity.Collection
+	if err := c.BindJSON(&newCollection); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if !service.IsValidCollectionData(newCollection.Name, newCollection.Description) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "invalid collection data",
+		})
+		return
+	}
+
+	newCollection.Id = 0
+	newCollection.OwnerId = auth.GetPrincipal(c).UserId
+
+	createdCollection, err := controller.service.Create(newCollection)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, createdCollection)
+}
+
+func (controller *CollectionsControllerProvider) Update(c *gin.Context) {
+	collection, ok := controller.getOwnCollection(c, auth.GetPrincipal(c))
+	if !ok {
+		return
+	}
+
+	var updatedData entity.EditableCollectionData
+	if err := c.BindJSON(&updatedData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	name, description := collection.Name, collection.Description
+	if updatedData.Name != "" {
+		name = updatedData.Name
+	}
+	if updatedData.Description != nil {
+		description = *updatedData.Description
+	}
+
+	if !service.IsValidCollectionData(name, description) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "invalid collection data",
+		})
+		return
+	}
+
+	updatedCollection, err := controller.service.Update(collection.Id, updatedData)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, updatedCollection)
+}
+
+// the articles of the collection stay saved
+func (controller *CollectionsControllerProvider) Delete(c *gin.Context) {
+	collection, ok := controller.getOwnCollection(c, auth.GetPrincipal(c))
+	if !ok {
+		return
+	}
+
+	deletedCollection, err := controller.service.Delete(collection.Id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, deletedCollection)
+}
+
+// adds an article to the end of the collection, the article is saved if it isn't yet
+func (controller *CollectionsControllerProvider) AddArticle(c *gin.Context) {
+	principal := auth.GetPrincipal(c)
+
+	ownCollection, ok := controller.getOwnCollection(c, principal)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		ArticleId int `json:"article_id"`
+	}
+	if err := c.BindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	userId := strconv.Itoa(principal.UserId)
+	articleId := strconv.Itoa(body.ArticleId)
+
+	if _, err := controller.articlesService.GetById(articleId, principal); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "article was not found",
+		})
+		return
+	}
+
+	isSaved, err := controller.articlesService.IsSaved(userId, articleId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if !isSaved {
+		if err := controller.articlesService.Save(userId, articleId); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+
+	collection, err := controller.service.AddArticle(ownCollection.Id, body.ArticleId)
+	if err != nil {
+		if err.Error() == "article is already in the collection" {
+			c.JSON(http.StatusConflict, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+
+		if err.Error() == "collection is full" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, collection)
+}
+
+// the article stays saved
+func (controller *CollectionsControllerProvider) RemoveArticle(c *gin.Context) {
+	ownCollection, ok := controller.getOwnCollection(c, auth.GetPrincipal(c))
+	if !ok {
+		return
+	}
+
+	articleId, err := strconv.Atoi(c.Param("articleId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "invalid article id",
+		})
+		return
+	}
+
+	collection, err := controller.service.RemoveArticle(ownCollection.Id, articleId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, collection)
+}
+
+// the body has all of the article ids of the collection in the new order
+func (controller *CollectionsControllerProvider) Reorder(c *gin.Context) {
+	ownCollection, ok := controller.getOwnCollection(c, auth.GetPrincipal(c))
+	if !ok {
+		return
+	}
+
+	var body struct {
+		ArticleIds []int `json:"article_ids"`
+	}
+	if err := c.BindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	collection, err := controller.service.Reorder(ownCollection.Id, body.ArticleIds)
+	if err != nil {
+		if err.Error() == "article ids must match the articles of the collection" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, collection)
+}
+
+func (controller *CollectionsControllerProvider) Follow(c *gin.Context) {
+	id, ok := parseCollectionId(c)
+	if !ok {
+		return
+	}
+
+	principal := auth.GetPrincipal(c)
+
+	if err := controller.service.Follow(id, principal.UserId); err != nil {
+		if err.Error() == "collection is private" {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+
+		if err.Error() == "can't follow own collection" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	collection, ok := controller.getCollection(c, principal)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, collection)
+}
+
+func (controller *CollectionsControllerProvider) Unfollow(c *gin.Context) {
+	id, ok := parseCollectionId(c)
+	if !ok {
+		return
+	}
+
+	if err := controller.service.Unfollow(id, auth.GetPrincipal(c).UserId); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "collection was unfollowed",
+	})
+}
@@ -0,0 +1,232 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/danielblagy/blog-webapp-server/auth"
+	"github.com/danielblagy/blog-webapp-server/entity"
+	"github.com/danielblagy/blog-webapp-server/service"
+	"github.com/gin-gonic/gin"
+)
+
+type CommentsController interface {
+	GetAll(c *gin.Context)
+	Create(c *gin.Context)
+	Update(c *gin.Context)
+	Delete(c *gin.Context)
+}
+
+type CommentsControllerProvider struct {
+	service         service.CommentsService
+	articlesService service.ArticlesService
+}
+
+func CreateCommentsController(service service.CommentsService, articlesService service.ArticlesService) CommentsController {
+	return &CommentsControllerProvider{
+		service:         service,
+		articlesService: articlesService,
+	}
+}
+
+// sends out a response if the article is not accessible by the user
+func (controller *CommentsControllerProvider) getArticle(c *gin.Context, viewer auth.Principal) (entity.Article, bool) {
+	article, err := controller.articlesService.GetById(c.Param("id"), viewer)
+	if err != nil {
+		if err.Error() == "article is private" {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"message": err.Error(),
+			})
+			return article, false
+		}
+
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return article, false
+	}
+
+	return article, true
+}
+
+// sends out a response if the comment id in the path is not a number
+func parseCommentId(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("commentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "invalid comment id",
+		})
+		return 0, false
+	}
+
+	return id, true
+}
+
+// sends out a response if the comment doesn't exist or doesn't belong to the article
+func (controller *CommentsControllerProvider) getComment(c *gin.Context, commentId int, article entity.Article) (entity.Comment, bool) {
+	comment, err := controller.service.GetById(commentId)
+	if err != nil || comment.ArticleId != article.Id {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "comment was not found",
+		})
+		return comment, false
+	}
+
+	return comment, true
+}
+
+func (controller *CommentsControllerProvider) GetAll(c *gin.Context) {
+	// comments of private articles are hidden from anonymous users and other users
+	comments, err := controller.service.GetByArticle(c.Param("id"), auth.GetPrincipal(c))
+
+	if err != nil {
+		if err.Error() == "article is private" {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, comments)
+}
+
+func (controller *CommentsControllerProvider) Create(c *gin.Context) {
+	principal := auth.GetPrincipal(c)
+
+	article, ok := controller.getArticle(c, principal)
+	if !ok {
+		return
+	}
+
+	var newComment entity.Comment
+	if err := c.BindJSON(&newComment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if newComment.Body == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "invalid comment data",
+		})
+		return
+	}
+
+	// a reply must be in the same article as the comment it replies to
+	if newComment.ParentId != nil {
+		if _, ok := controller.getComment(c, *newComment.ParentId, article); !ok {
+			return
+		}
+	}
+
+	newComment.Id = 0
+	newComment.ArticleId = article.Id
+	newComment.AuthorId = principal.UserId
+
+	createdComment, err := controller.service.Create(newComment)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, createdComment)
+}
+
+func (controller *CommentsControllerProvider) Update(c *gin.Context) {
+	principal := auth.GetPrincipal(c)
+
+	article, ok := controller.getArticle(c, principal)
+	if !ok {
+		return
+	}
+
+	commentId, ok := parseCommentId(c)
+	if !ok {
+		return
+	}
+
+	comment, ok := controller.getComment(c, commentId, article)
+	if !ok {
+		return
+	}
+
+	// ensure the user owns the comment
+	if principal.UserId != comment.AuthorId {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"message": "access denied",
+		})
+		return
+	}
+
+	var updatedData entity.EditableCommentData
+	if err := c.BindJSON(&updatedData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if updatedData.Body == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "invalid comment data",
+		})
+		return
+	}
+
+	updatedComment, err := controller.service.Update(commentId, updatedData)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, updatedComment)
+}
+
+func (controller *CommentsControllerProvider) Delete(c *gin.Context) {
+	principal := auth.GetPrincipal(c)
+
+	article, ok := controller.getArticle(c, principal)
+	if !ok {
+		return
+	}
+
+	commentId, ok := parseCommentId(c)
+	if !ok {
+		return
+	}
+
+	comment, ok := controller.getComment(c, commentId, article)
+	if !ok {
+		return
+	}
+
+	// comments can be deleted by their authors and by the author of the article
+	if principal.UserId != comment.AuthorId && principal.UserId != article.AuthorId {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"message": "access denied",
+		})
+		return
+	}
+
+	deletedComment, err := controller.service.Delete(commentId)
+
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, deletedComment)
+}
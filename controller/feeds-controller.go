@@ -0,0 +1,210 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danielblagy/blog-webapp-server/auth"
+	"github.com/danielblagy/blog-webapp-server/entity"
+	"github.com/danielblagy/blog-webapp-server/feed"
+	"github.com/danielblagy/blog-webapp-server/pagination"
+	"github.com/danielblagy/blog-webapp-server/service"
+	"github.com/gin-gonic/gin"
+)
+
+const defaultFeedItemLimit = 20
+
+type FeedsController interface {
+	SiteFeed(c *gin.Context)
+	UserFeed(c *gin.Context)
+}
+
+type FeedsControllerProvider struct {
+	articlesService service.ArticlesService
+	usersService    service.UsersService
+}
+
+func CreateFeedsController(articlesService service.ArticlesService, usersService service.UsersService) FeedsController {
+	return &FeedsControllerProvider{
+		articlesService: articlesService,
+		usersService:    usersService,
+	}
+}
+
+// SITE_URL env variable if it's set, the url the request was sent to otherwise
+func siteUrl(c *gin.Context) string {
+	if url := os.Getenv("SITE_URL"); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// FEED_ITEM_LIMIT env variable (20 if not set), can be lowered or raised up to pagination.MaxLimit with 'limit' query parameter
+func feedItemLimit(c *gin.Context) (int, error) {
+	limit := defaultFeedItemLimit
+	if configuredLimit, err := strconv.Atoi(os.Getenv("FEED_ITEM_LIMIT")); err == nil && configuredLimit > 0 {
+		limit = configuredLimit
+	}
+
+	if c.Query("limit") == "" {
+		return limit, nil
+	}
+
+	return pagination.ParseLimit(c)
+}
+
+// feed format is the extension of the route, e.g. 'rss' for /feeds/articles.rss
+func feedFormat(c *gin.Context) string {
+	return strings.TrimPrefix(path.Ext(c.FullPath()), ".")
+}
+
+func articleFeedItem(baseUrl string, article entity.Article) feed.Item {
+	link := fmt.Sprintf("%s/articles/%d", baseUrl, article.Id)
+	return feed.Item{
+		Id:      link,
+		Title:   article.Title,
+		Link:    link,
+		Content: article.Content,
+		Html:    article.Html,
+		Author: feed.Author{
+			Name: article.Author.FullName,
+			Link: fmt.Sprintf("%s/users/%d", baseUrl, article.Author.Id),
+		},
+		Tags:      article.Tags,
+		Published: article.CreatedAt,
+		Updated:   article.UpdatedAt,
+	}
+}
+
+// renders the feed, responding with 304 Not Modified if the client already has the latest version
+func writeFeed(c *gin.Context, f feed.Feed, format string) {
+	body, err := f.Render(format)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	hash := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(hash[:16]) + `"`
+	c.Header("ETag", etag)
+
+	lastModified := f.Updated()
+	if !lastModified.IsZero() {
+		c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" {
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				c.Status(http.StatusNotModified)
+				return
+			}
+		}
+	} else if ifModifiedSince, err := http.ParseTime(c.GetHeader("If-Modified-Since")); err == nil && !lastModified.IsZero() {
+		// http dates have second precision
+		if !lastModified.Truncate(time.Second).After(ifModifiedSince) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+
+	c.Data(http.StatusOK, feed.ContentType(format), body)
+}
+
+// the newest published articles of all users, optionally with the tag from 'tag' query parameter
+func (controller *FeedsControllerProvider) SiteFeed(c *gin.Context) {
+	limit, err := feedItemLimit(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	articles, _, err := controller.articlesService.GetAll(c.Query("tag"), pagination.Params{Limit: limit}, auth.Principal{})
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	baseUrl := siteUrl(c)
+	f := feed.Feed{
+		Title:       "Blog",
+		Description: "The newest articles",
+		Link:        baseUrl + "/articles",
+		FeedLink:    baseUrl + c.Request.URL.RequestURI(),
+		Items:       []feed.Item{},
+	}
+
+	if tag := service.NormalizeTag(c.Query("tag")); tag != "" {
+		f.Title = "Blog: " + tag
+		f.Description = "The newest articles tagged " + tag
+		f.Link = baseUrl + "/tags/" + tag
+	}
+
+	for _, article := range articles {
+		f.Items = append(f.Items, articleFeedItem(baseUrl, article))
+	}
+
+	writeFeed(c, f, feedFormat(c))
+}
+
+// the newest published articles of the user
+func (controller *FeedsControllerProvider) UserFeed(c *gin.Context) {
+	limit, err := feedItemLimit(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	user, err := controller.usersService.GetById(c.Param("id"), false)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	articles := user.Articles
+	sort.Slice(articles, func(i, j int) bool {
+		return articles[i].CreatedAt.After(articles[j].CreatedAt)
+	})
+	if len(articles) > limit {
+		articles = articles[:limit]
+	}
+
+	baseUrl := siteUrl(c)
+	f := feed.Feed{
+		Title:       user.FullName,
+		Description: "The newest articles by " + user.FullName + " (" + user.Login + ")",
+		Link:        fmt.Sprintf("%s/users/%d", baseUrl, user.Id),
+		FeedLink:    baseUrl + c.Request.URL.RequestURI(),
+		Items:       []feed.Item{},
+	}
+
+	for _, article := range articles {
+		f.Items = append(f.Items, articleFeedItem(baseUrl, article))
+	}
+
+	writeFeed(c, f, feedFormat(c))
+}
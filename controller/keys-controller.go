@@ -0,0 +1,25 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/danielblagy/blog-webapp-server/auth"
+	"github.com/gin-gonic/gin"
+)
+
+type KeysController interface {
+	GetJSONWebKeySet(c *gin.Context)
+}
+
+type KeysControllerProvider struct{}
+
+func CreateKeysController() KeysController {
+	return &KeysControllerProvider{}
+}
+
+// public keys for other services to verify access tokens with, they should refetch the set
+// when a token has an unknown kid
+func (controller *KeysControllerProvider) GetJSONWebKeySet(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, auth.GetJSONWebKeySet())
+}
@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/danielblagy/blog-webapp-server/auth"
+	"github.com/danielblagy/blog-webapp-server/pagination"
+	"github.com/danielblagy/blog-webapp-server/service"
+	"github.com/gin-gonic/gin"
+)
+
+type MediaController interface {
+	GetAll(c *gin.Context)
+	GetById(c *gin.Context)
+	Upload(c *gin.Context)
+	Delete(c *gin.Context)
+}
+
+type MediaControllerProvider struct {
+	service service.MediaService
+	maxSize int64
+}
+
+func CreateMediaController(service service.MediaService, maxSize int64) MediaController {
+	return &MediaControllerProvider{
+		service: service,
+		maxSize: maxSize,
+	}
+}
+
+// the signed in user's uploads
+func (controller *MediaControllerProvider) GetAll(c *gin.Context) {
+	params, err := pagination.ParseParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	media, cursors, err := controller.service.GetByOwner(auth.GetPrincipal(c).UserId, params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, pagination.Page{Items: media, Cursors: cursors})
+}
+
+// sends out a response if the id in the path is not a number
+func parseMediaId(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "invalid media id",
+		})
+		return 0, false
+	}
+
+	return id, true
+}
+
+// the owner's media, or media used in a published article
+func (controller *MediaControllerProvider) GetById(c *gin.Context) {
+	id, ok := parseMediaId(c)
+	if !ok {
+		return
+	}
+
+	media, err := controller.service.GetById(id, auth.GetPrincipal(c))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, media)
+}
+
+// the file is sent in 'file' field of a multipart form
+func (controller *MediaControllerProvider) Upload(c *gin.Context) {
+	// the form may be a little larger than the file itself
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, controller.maxSize+maxMultipartOverhead)
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "file is missing or the form is too large",
+		})
+		return
+	}
+	defer file.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(file, controller.maxSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if int64(len(data)) > controller.maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"message": "file is too large",
+		})
+		return
+	}
+
+	media, err := controller.service.Upload(auth.GetPrincipal(c).UserId, header.Filename, data)
+	if err != nil {
+		switch err.Error() {
+		case "unsupported media type", "unsupported image format", "invalid image":
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{
+				"message": err.Error(),
+			})
+		case "image dimensions are too large":
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"message": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": err.Error(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, media)
+}
+
+// the media is removed from the articles it's used in
+func (controller *MediaControllerProvider) Delete(c *gin.Context) {
+	id, ok := parseMediaId(c)
+	if !ok {
+		return
+	}
+
+	principal := auth.GetPrincipal(c)
+
+	media, err := controller.service.GetById(id, principal)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// ensure the user owns the media
+	if principal.UserId != media.OwnerId {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"message": "access denied",
+		})
+		return
+	}
+
+	deletedMedia, err := controller.service.Delete(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, deletedMedia)
+}
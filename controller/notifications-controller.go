@@ -0,0 +1,240 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/danielblagy/blog-webapp-server/auth"
+	"github.com/danielblagy/blog-webapp-server/pagination"
+	"github.com/danielblagy/blog-webapp-server/service"
+	"github.com/gin-gonic/gin"
+)
+
+// Streams check the database this often as well, to deliver notifications created by other
+// server instances, and to keep idle connections open through proxies
+const notificationsStreamPollInterval = 15 * time.Second
+
+type NotificationsController interface {
+	GetAll(c *gin.Context)
+	CountUnread(c *gin.Context)
+	MarkRead(c *gin.Context)
+	MarkAllRead(c *gin.Context)
+	GetPreferences(c *gin.Context)
+	SetPreferences(c *gin.Context)
+	Stream(c *gin.Context)
+}
+
+type NotificationsControllerProvider struct {
+	service               service.NotificationsService
+	usersService          service.UsersService
+	sessionsService       service.SessionsService
+	personalTokensService service.PersonalTokensService
+}
+
+func CreateNotificationsController(
+	service service.NotificationsService,
+	usersService service.UsersService,
+	sessionsService service.SessionsService,
+	personalTokensService service.PersonalTokensService,
+) NotificationsController {
+	return &NotificationsControllerProvider{
+		service:               service,
+		usersService:          usersService,
+		sessionsService:       sessionsService,
+		personalTokensService: personalTokensService,
+	}
+}
+
+// Streams outlive the credentials they were opened with, so the credentials are checked again while streaming:
+// the access token or the personal access token must not be expired, the session or the token must not be revoked,
+// and the user must not be suspended or deleted
+func (controller *NotificationsControllerProvider) isStillSignedIn(principal auth.Principal) bool {
+	if !principal.ExpiresAt.IsZero() && time.Now().After(principal.ExpiresAt) {
+		return false
+	}
+
+	if principal.SessionId != 0 && !controller.sessionsService.IsActive(principal.SessionId) {
+		return false
+	}
+
+	if principal.TokenId != 0 && !controller.personalTokensService.IsActive(principal.TokenId) {
+		return false
+	}
+
+	user, err := controller.usersService.GetAccount(strconv.Itoa(principal.UserId))
+	return err == nil && !user.Suspended
+}
+
+// 'unread' query parameter set to true returns only unread notifications
+func (controller *NotificationsControllerProvider) GetAll(c *gin.Context) {
+	params, err := pagination.ParseParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	notifications, cursors, err := controller.service.GetAll(auth.GetPrincipal(c).UserId, c.Query("unread") == "true", params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, pagination.Page{Items: notifications, Cursors: cursors})
+}
+
+func (controller *NotificationsControllerProvider) CountUnread(c *gin.Context) {
+	count, err := controller.service.CountUnread(auth.GetPrincipal(c).UserId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"count": count,
+	})
+}
+
+func (controller *NotificationsControllerProvider) MarkRead(c *gin.Context) {
+	if err := controller.service.MarkRead(auth.GetPrincipal(c).UserId, c.Param("id")); err != nil {
+		if err.Error() == "notification not found" {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "notification has been marked as read",
+	})
+}
+
+func (controller *NotificationsControllerProvider) MarkAllRead(c *gin.Context) {
+	if err := controller.service.MarkAllRead(auth.GetPrincipal(c).UserId); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "all notifications have been marked as read",
+	})
+}
+
+func (controller *NotificationsControllerProvider) GetPreferences(c *gin.Context) {
+	preferences, err := controller.service.GetPreferences(auth.GetPrincipal(c).UserId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, preferences)
+}
+
+func (controller *NotificationsControllerProvider) SetPreferences(c *gin.Context) {
+	var preferences map[string]bool
+	if err := c.BindJSON(&preferences); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	updated, err := controller.service.SetPreferences(auth.GetPrincipal(c).UserId, preferences)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// Server-Sent Events stream of new notifications, every event has the notification's id,
+// so reconnecting clients (sending Last-Event-ID header) receive the notifications they missed
+func (controller *NotificationsControllerProvider) Stream(c *gin.Context) {
+	principal := auth.GetPrincipal(c)
+	userId := principal.UserId
+
+	lastId, err := strconv.Atoi(c.GetHeader("Last-Event-ID"))
+	if err != nil {
+		// a new stream starts with the notifications created after it's opened
+		lastId, err = controller.service.GetLastId(userId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+
+	signals, stop := controller.service.Listen(userId)
+	defer stop()
+
+	ticker := time.NewTicker(notificationsStreamPollInterval)
+	defer ticker.Stop()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // disables response buffering in nginx
+	c.Status(http.StatusOK)
+
+	first := true
+	c.Stream(func(w io.Writer) bool {
+		if first {
+			first = false
+			fmt.Fprintf(w, "retry: %d\n\n", notificationsStreamPollInterval.Milliseconds())
+		} else {
+			select {
+			case <-c.Request.Context().Done():
+				return false
+			case <-signals:
+			case <-ticker.C:
+				if !controller.isStillSignedIn(principal) {
+					return false
+				}
+				fmt.Fprint(w, ": ping\n\n")
+			}
+		}
+
+		notifications, err := controller.service.GetAfter(userId, lastId)
+		if err != nil {
+			log.Printf("Failed to get notifications for the stream: %s", err.Error())
+			return true
+		}
+
+		for _, notification := range notifications {
+			data, err := json.Marshal(notification)
+			if err != nil {
+				log.Printf("Failed to encode notification %d: %s", notification.Id, err.Error())
+				continue
+			}
+
+			fmt.Fprintf(w, "id: %d\nevent: notification\ndata: %s\n\n", notification.Id, data)
+			lastId = notification.Id
+		}
+
+		return true
+	})
+}
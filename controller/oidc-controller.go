@@ -0,0 +1,406 @@
+package controller
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danielblagy/blog-webapp-server/auth"
+	"github.com/danielblagy/blog-webapp-server/entity"
+	"github.com/danielblagy/blog-webapp-server/oidc"
+	"github.com/danielblagy/blog-webapp-server/service"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	oidcStateCookie   = "oidcState"
+	oidcStateDuration = time.Minute * 10
+)
+
+type OidcController interface {
+	GetProviders(c *gin.Context)
+	SignIn(c *gin.Context)
+	Link(c *gin.Context)
+	Callback(c *gin.Context)
+	GetIdentities(c *gin.Context)
+	Unlink(c *gin.Context)
+}
+
+type OidcControllerProvider struct {
+	providers         map[string]*oidc.Provider
+	usersService      service.UsersService
+	identitiesService service.IdentitiesService
+	sessionsService   service.SessionsService
+	twoFactorService  service.TwoFactorService
+}
+
+func CreateOidcController(providers map[string]*oidc.Provider, usersService service.UsersService, identitiesService service.IdentitiesService, sessionsService service.SessionsService, twoFactorService service.TwoFactorService) OidcController {
+	return &OidcControllerProvider{
+		providers:         providers,
+		usersService:      usersService,
+		identitiesService: identitiesService,
+		sessionsService:   sessionsService,
+		twoFactorService:  twoFactorService,
+	}
+}
+
+// the redirect url registered at the providers is {API_URL}/auth/oidc/{provider}/callback,
+// API_URL env variable defaults to the url of the request
+func oidcRedirectUrl(c *gin.Context, provider *oidc.Provider) string {
+	base := strings.TrimSuffix(os.Getenv("API_URL"), "/")
+	if base == "" {
+		scheme := "http"
+		if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		base = scheme + "://" + c.Request.Host
+	}
+	return base + "/auth/oidc/" + provider.Name + "/callback"
+}
+
+// sends out a response if the provider isn't configured
+func (controller *OidcControllerProvider) getProvider(c *gin.Context) (*oidc.Provider, bool) {
+	provider, ok := controller.providers[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "provider was not found",
+		})
+	}
+	return provider, ok
+}
+
+// redirects to the provider's sign in page, linkUserId is 0 when signing in
+func (controller *OidcControllerProvider) redirectToProvider(c *gin.Context, provider *oidc.Provider, linkUserId int) {
+	state := auth.OidcStateClaims{Provider: provider.Name, LinkUserId: linkUserId}
+	for _, value := range []*string{&state.State, &state.Nonce, &state.CodeVerifier} {
+		random, err := oidc.RandomString()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+		*value = random
+	}
+
+	authUrl, err := provider.AuthCodeURL(oidcRedirectUrl(c, provider), state.State, state.Nonce, state.CodeVerifier)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	stateToken, err := auth.GenerateOidcStateToken(state, time.Now().Add(oidcStateDuration))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.SetCookie(oidcStateCookie, stateToken, int(oidcStateDuration.Seconds()), "/auth/oidc", "", false, true)
+	c.Redirect(http.StatusFound, authUrl)
+}
+
+// login for a new user based on the identity, a number is appended if it's taken
+func (controller *OidcControllerProvider) availableLogin(claims oidc.Claims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base = strings.Split(claims.Email, "@")[0]
+	}
+
+	base = strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '.' || r == '_' || r == '-' {
+			return r
+		}
+		return -1
+	}, strings.ToLower(base))
+	if len(base) > 90 {
+		base = base[:90]
+	}
+	if base == "" {
+		base = "user"
+	}
+
+	login := base
+	for i := 0; i < 10; i++ {
+		if _, err := controller.usersService.GetByLogin(login); err != nil {
+			return login, nil
+		}
+
+		n, err := rand.Int(rand.Reader, big.NewInt(100000))
+		if err != nil {
+			return "", err
+		}
+		login = fmt.Sprintf("%s%d", base, n.Int64())
+	}
+
+	return "", fmt.Errorf("couldn't find an available login for '%s'", base)
+}
+
+// Returns the user to link a new identity to. A user with the same email is used if both the provider
+// and the user have verified it, otherwise a new user without a password is created.
+// Sends out a response on failure.
+func (controller *OidcControllerProvider) findOrCreateUser(c *gin.Context, claims oidc.Claims) (entity.User, bool) {
+	newUser := entity.User{Role: entity.RoleUser}
+
+	if claims.Email != "" && claims.EmailVerified {
+		if email, err := service.NormalizeEmail(claims.Email); err == nil {
+			user, err := controller.usersService.GetByEmail(email)
+			if err == nil {
+				if !user.EmailVerified {
+					c.JSON(http.StatusConflict, gin.H{
+						"message": "a user with this email exists, sign in to link the provider",
+					})
+					return user, false
+				}
+				return user, true
+			}
+
+			newUser.Email = &email
+			newUser.EmailVerified = true
+		}
+	}
+
+	login, err := controller.availableLogin(claims)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return newUser, false
+	}
+
+	newUser.Login = login
+	newUser.FullName = claims.Name
+	if newUser.FullName == "" {
+		newUser.FullName = login
+	}
+
+	createdUser, err := controller.usersService.Create(newUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return createdUser, false
+	}
+
+	return createdUser, true
+}
+
+// links the identity to the user who started linking, sends out the identity
+func (controller *OidcControllerProvider) link(c *gin.Context, userId int, provider *oidc.Provider, claims oidc.Claims) {
+	if identity, err := controller.identitiesService.GetBySubject(provider.Name, claims.Subject); err == nil {
+		if identity.UserId != userId {
+			c.JSON(http.StatusConflict, gin.H{
+				"message": "this account is linked to another user",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, identity)
+		return
+	}
+
+	identities, err := controller.identitiesService.GetByUser(strconv.Itoa(userId))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	for _, identity := range identities {
+		if identity.Provider == provider.Name {
+			c.JSON(http.StatusConflict, gin.H{
+				"message": "another account of this provider is already linked",
+			})
+			return
+		}
+	}
+
+	identity, err := controller.identitiesService.Create(entity.Identity{
+		UserId:   userId,
+		Provider: provider.Name,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, identity)
+}
+
+func (controller *OidcControllerProvider) GetProviders(c *gin.Context) {
+	names := []string{}
+	for name := range controller.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	c.JSON(http.StatusOK, names)
+}
+
+func (controller *OidcControllerProvider) SignIn(c *gin.Context) {
+	provider, ok := controller.getProvider(c)
+	if !ok {
+		return
+	}
+
+	controller.redirectToProvider(c, provider, 0)
+}
+
+// links the provider to the signed in user
+func (controller *OidcControllerProvider) Link(c *gin.Context) {
+	principal := auth.GetPrincipal(c)
+
+	provider, ok := controller.getProvider(c)
+	if !ok {
+		return
+	}
+
+	controller.redirectToProvider(c, provider, principal.UserId)
+}
+
+// The provider redirects here after the user signs in. Signs in the user linked to the identity,
+// creating the user on the first sign in, or links the identity if linking was started.
+func (controller *OidcControllerProvider) Callback(c *gin.Context) {
+	provider, ok := controller.getProvider(c)
+	if !ok {
+		return
+	}
+
+	if providerError := c.Query("error"); providerError != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "provider returned an error: " + providerError,
+		})
+		return
+	}
+
+	stateToken, err := c.Cookie(oidcStateCookie)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "sign in state is missing",
+		})
+		return
+	}
+
+	// the state is single-use
+	c.SetCookie(oidcStateCookie, "", -1, "/auth/oidc", "", false, true)
+
+	state, err := auth.ParseOidcStateToken(stateToken)
+	if err != nil || state.Provider != provider.Name || subtle.ConstantTimeCompare([]byte(state.State), []byte(c.Query("state"))) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "invalid or expired sign in state",
+		})
+		return
+	}
+
+	claims, err := provider.Exchange(c.Query("code"), oidcRedirectUrl(c, provider), state.CodeVerifier, state.Nonce)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if state.LinkUserId != 0 {
+		controller.link(c, state.LinkUserId, provider, claims)
+		return
+	}
+
+	if identity, err := controller.identitiesService.GetBySubject(provider.Name, claims.Subject); err == nil {
+		user, err := controller.usersService.GetAccount(strconv.Itoa(identity.UserId))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "user doesn't exist",
+			})
+			return
+		}
+
+		completeSignIn(c, controller.sessionsService, controller.twoFactorService, user)
+		return
+	}
+
+	user, ok := controller.findOrCreateUser(c, claims)
+	if !ok {
+		return
+	}
+
+	if _, err := controller.identitiesService.Create(entity.Identity{
+		UserId:   user.Id,
+		Provider: provider.Name,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	completeSignIn(c, controller.sessionsService, controller.twoFactorService, user)
+}
+
+func (controller *OidcControllerProvider) GetIdentities(c *gin.Context) {
+	principal := auth.GetPrincipal(c)
+
+	identities, err := controller.identitiesService.GetByUser(strconv.Itoa(principal.UserId))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, identities)
+}
+
+// the user must keep at least one way to sign in: a password or another linked identity
+func (controller *OidcControllerProvider) Unlink(c *gin.Context) {
+	userId := strconv.Itoa(auth.GetPrincipal(c).UserId)
+
+	user, err := controller.usersService.GetAccount(userId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	identities, err := controller.identitiesService.GetByUser(userId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if user.Password == "" && len(identities) <= 1 {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "can't unlink the only sign in method, set a password first",
+		})
+		return
+	}
+
+	identity, err := controller.identitiesService.Delete(userId, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, identity)
+}
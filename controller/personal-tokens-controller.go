@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/danielblagy/blog-webapp-server/auth"
+	"github.com/danielblagy/blog-webapp-server/entity"
+	"github.com/danielblagy/blog-webapp-server/service"
+	"github.com/gin-gonic/gin"
+)
+
+type PersonalTokensController interface {
+	GetAll(c *gin.Context)
+	Create(c *gin.Context)
+	Revoke(c *gin.Context)
+}
+
+type PersonalTokensControllerProvider struct {
+	service service.PersonalTokensService
+}
+
+func CreatePersonalTokensController(service service.PersonalTokensService) PersonalTokensController {
+	return &PersonalTokensControllerProvider{
+		service: service,
+	}
+}
+
+func (controller *PersonalTokensControllerProvider) GetAll(c *gin.Context) {
+	principal := auth.GetPrincipal(c)
+
+	tokens, err := controller.service.GetByUser(strconv.Itoa(principal.UserId))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// the token string is only included in this response
+func (controller *PersonalTokensControllerProvider) Create(c *gin.Context) {
+	principal := auth.GetPrincipal(c)
+
+	var body struct {
+		Name      string     `json:"name"`
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	if err := c.BindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if body.Name == "" || len(body.Name) > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "invalid token name",
+		})
+		return
+	}
+
+	if len(body.Scopes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "at least one scope is required",
+		})
+		return
+	}
+
+	// the admin scope only works for moderators and admins, the role is checked on every request
+	for _, scope := range body.Scopes {
+		if !auth.IsValidScope(scope) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "invalid scope '" + scope + "'",
+			})
+			return
+		}
+	}
+
+	if body.ExpiresAt != nil && !body.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "expiration time must be in the future",
+		})
+		return
+	}
+
+	token, tokenString, err := controller.service.Create(strconv.Itoa(principal.UserId), body.Name, body.Scopes, body.ExpiresAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, struct {
+		entity.PersonalAccessToken
+		Token string `json:"token"`
+	}{token, tokenString})
+}
+
+func (controller *PersonalTokensControllerProvider) Revoke(c *gin.Context) {
+	principal := auth.GetPrincipal(c)
+
+	token, err := controller.service.Revoke(strconv.Itoa(principal.UserId), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, token)
+}
@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/danielblagy/blog-webapp-server/auth"
+	"github.com/danielblagy/blog-webapp-server/entity"
+	"github.com/danielblagy/blog-webapp-server/pagination"
+	"github.com/danielblagy/blog-webapp-server/service"
+	"github.com/gin-gonic/gin"
+)
+
+type ReactionsController interface {
+	GetTypes(c *gin.Context)
+	GetAll(c *gin.Context)
+	Add(c *gin.Context)
+	Remove(c *gin.Context)
+}
+
+type ReactionsControllerProvider struct {
+	service         service.ReactionsService
+	articlesService service.ArticlesService
+}
+
+func CreateReactionsController(service service.ReactionsService, articlesService service.ArticlesService) ReactionsController {
+	return &ReactionsControllerProvider{
+		service:         service,
+		articlesService: articlesService,
+	}
+}
+
+// sends out a response if the article is not accessible by the user
+func (controller *ReactionsControllerProvider) getArticle(c *gin.Context, viewer auth.Principal) (entity.Article, bool) {
+	article, err := controller.articlesService.GetById(c.Param("id"), viewer)
+	if err != nil {
+		if err.Error() == "article is private" {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"message": err.Error(),
+			})
+			return article, false
+		}
+
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return article, false
+	}
+
+	return article, true
+}
+
+func (controller *ReactionsControllerProvider) GetTypes(c *gin.Context) {
+	c.JSON(http.StatusOK, controller.service.GetTypes())
+}
+
+// 'type' query parameter filters reactions by type
+func (controller *ReactionsControllerProvider) GetAll(c *gin.Context) {
+	article, ok := controller.getArticle(c, auth.GetPrincipal(c))
+	if !ok {
+		return
+	}
+
+	params, err := pagination.ParseParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	reactions, cursors, err := controller.service.GetByArticle(strconv.Itoa(article.Id), c.Query("type"), params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, pagination.Page{Items: reactions, Cursors: cursors})
+}
+
+// responds with the article with updated reaction counts
+func (controller *ReactionsControllerProvider) Add(c *gin.Context) {
+	principal := auth.GetPrincipal(c)
+
+	article, ok := controller.getArticle(c, principal)
+	if !ok {
+		return
+	}
+
+	if err := controller.service.Add(strconv.Itoa(principal.UserId), strconv.Itoa(article.Id), c.Param("type")); err != nil {
+		if err.Error() == "invalid reaction type" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	controller.respondWithArticle(c, principal)
+}
+
+// responds with the article with updated reaction counts
+func (controller *ReactionsControllerProvider) Remove(c *gin.Context) {
+	principal := auth.GetPrincipal(c)
+
+	article, ok := controller.getArticle(c, principal)
+	if !ok {
+		return
+	}
+
+	if err := controller.service.Remove(strconv.Itoa(principal.UserId), strconv.Itoa(article.Id), c.Param("type")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	controller.respondWithArticle(c, principal)
+}
+
+func (controller *ReactionsControllerProvider) respondWithArticle(c *gin.Context, viewer auth.Principal) {
+	article, err := controller.articlesService.GetById(c.Param("id"), viewer)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, article)
+}
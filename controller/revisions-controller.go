@@ -0,0 +1,214 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/danielblagy/blog-webapp-server/auth"
+	"github.com/danielblagy/blog-webapp-server/entity"
+	"github.com/danielblagy/blog-webapp-server/pagination"
+	"github.com/danielblagy/blog-webapp-server/service"
+	"github.com/gin-gonic/gin"
+)
+
+type RevisionsController interface {
+	GetAll(c *gin.Context)
+	GetById(c *gin.Context)
+	Diff(c *gin.Context)
+	Restore(c *gin.Context)
+}
+
+type RevisionsControllerProvider struct {
+	service         service.RevisionsService
+	articlesService service.ArticlesService
+}
+
+func CreateRevisionsController(service service.RevisionsService, articlesService service.ArticlesService) RevisionsController {
+	return &RevisionsControllerProvider{
+		service:         service,
+		articlesService: articlesService,
+	}
+}
+
+// only the author has access to the article's history,
+// sends out a response if the user is not authorized or doesn't own the article
+func (controller *RevisionsControllerProvider) getOwnedArticle(c *gin.Context) (entity.Article, bool) {
+	principal := auth.GetPrincipal(c)
+
+	article, err := controller.articlesService.GetById(c.Param("id"), principal)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return article, false
+	}
+
+	if principal.UserId != article.AuthorId {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"message": "access denied",
+		})
+		return article, false
+	}
+
+	return article, true
+}
+
+// sends out a response if the revision id is not a number
+func parseRevisionId(c *gin.Context, value string) (int, bool) {
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "invalid revision id",
+		})
+		return 0, false
+	}
+
+	return id, true
+}
+
+func (controller *RevisionsControllerProvider) GetAll(c *gin.Context) {
+	article, ok := controller.getOwnedArticle(c)
+	if !ok {
+		return
+	}
+
+	params, err := pagination.ParseParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	revisions, cursors, err := controller.service.GetByArticle(strconv.Itoa(article.Id), params)
+
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, pagination.Page{Items: revisions, Cursors: cursors})
+}
+
+func (controller *RevisionsControllerProvider) GetById(c *gin.Context) {
+	article, ok := controller.getOwnedArticle(c)
+	if !ok {
+		return
+	}
+
+	revisionId, ok := parseRevisionId(c, c.Param("revisionId"))
+	if !ok {
+		return
+	}
+
+	revision, err := controller.service.GetById(article.Id, revisionId)
+
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, revision)
+}
+
+// compares revision 'from' with revision 'to', or with the current version of the article if 'to' is not provided
+func (controller *RevisionsControllerProvider) Diff(c *gin.Context) {
+	article, ok := controller.getOwnedArticle(c)
+	if !ok {
+		return
+	}
+
+	if c.Query("from") == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "revision 'from' is required",
+		})
+		return
+	}
+
+	fromId, ok := parseRevisionId(c, c.Query("from"))
+	if !ok {
+		return
+	}
+
+	from, err := controller.service.GetById(article.Id, fromId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "revision 'from' was not found",
+		})
+		return
+	}
+
+	to := entity.ArticleRevision{
+		ArticleId: article.Id,
+		Title:     article.Title,
+		Content:   article.Content,
+		Format:    article.Format,
+		Published: article.Published,
+	}
+
+	if c.Query("to") != "" {
+		toId, ok := parseRevisionId(c, c.Query("to"))
+		if !ok {
+			return
+		}
+
+		to, err = controller.service.GetById(article.Id, toId)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "revision 'to' was not found",
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, controller.service.Diff(from, to))
+}
+
+// replaces the title and the content of the article with the ones from the revision,
+// the current version is kept in the history, so restoring can be undone
+func (controller *RevisionsControllerProvider) Restore(c *gin.Context) {
+	article, ok := controller.getOwnedArticle(c)
+	if !ok {
+		return
+	}
+
+	revisionId, ok := parseRevisionId(c, c.Param("revisionId"))
+	if !ok {
+		return
+	}
+
+	revision, err := controller.service.GetById(article.Id, revisionId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	restoredArticle, err := controller.articlesService.Update(strconv.Itoa(article.Id), entity.EditableArticleData{
+		Title:     revision.Title,
+		Content:   revision.Content,
+		Format:    revision.Format,
+		Published: article.Published,
+	})
+	if err != nil {
+		// another article of the user got the title meanwhile
+		if err.Error() == "user already has article with this title" {
+			c.JSON(http.StatusConflict, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, restoredArticle)
+}
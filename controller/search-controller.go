@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/danielblagy/blog-webapp-server/auth"
+	"github.com/danielblagy/blog-webapp-server/entity"
+	"github.com/danielblagy/blog-webapp-server/pagination"
+	"github.com/danielblagy/blog-webapp-server/service"
+	"github.com/gin-gonic/gin"
+)
+
+type SearchController interface {
+	Search(c *gin.Context)
+}
+
+type SearchControllerProvider struct {
+	articlesService service.ArticlesService
+	usersService    service.UsersService
+}
+
+func CreateSearchController(articlesService service.ArticlesService, usersService service.UsersService) SearchController {
+	return &SearchControllerProvider{
+		articlesService: articlesService,
+		usersService:    usersService,
+	}
+}
+
+func (controller *SearchControllerProvider) Search(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "search query must not be empty",
+		})
+		return
+	}
+
+	limit, err := pagination.ParseLimit(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// anonymous users only get published articles
+	viewer := auth.GetPrincipal(c)
+
+	results := entity.SearchResults{
+		Articles: []entity.ArticleSearchResult{},
+		Users:    []entity.UserSearchResult{},
+	}
+
+	searchType := c.Query("type")
+
+	if searchType == "" || searchType == "articles" {
+		results.Articles, err = controller.articlesService.Search(query, viewer, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+
+	if searchType == "" || searchType == "users" {
+		results.Users, err = controller.usersService.Search(query, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, results)
+}
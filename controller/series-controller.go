@@ -0,0 +1,304 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/danielblagy/blog-webapp-server/auth"
+	"github.com/danielblagy/blog-webapp-server/entity"
+	"github.com/danielblagy/blog-webapp-server/service"
+	"github.com/gin-gonic/gin"
+)
+
+type SeriesController interface {
+	GetById(c *gin.Context)
+	GetByUser(c *gin.Context)
+	Create(c *gin.Context)
+	Update(c *gin.Context)
+	Delete(c *gin.Context)
+	AddArticle(c *gin.Context)
+	RemoveArticle(c *gin.Context)
+	Reorder(c *gin.Context)
+}
+
+type SeriesControllerProvider struct {
+	service         service.SeriesService
+	articlesService service.ArticlesService
+}
+
+func CreateSeriesController(service service.SeriesService, articlesService service.ArticlesService) SeriesController {
+	return &SeriesControllerProvider{
+		service:         service,
+		articlesService: articlesService,
+	}
+}
+
+// sends out a response if the id in the path is not a number
+func parseSeriesId(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "invalid series id",
+		})
+		return 0, false
+	}
+
+	return id, true
+}
+
+// sends out a response if the series doesn't exist or doesn't belong to the user
+func (controller *SeriesControllerProvider) getOwnSeries(c *gin.Context, principal auth.Principal) (entity.Series, bool) {
+	id, ok := parseSeriesId(c)
+	if !ok {
+		return entity.Series{}, false
+	}
+
+	series, err := controller.service.GetById(id, principal)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return series, false
+	}
+
+	// ensure the user owns the series
+	if principal.UserId != series.OwnerId {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"message": "access denied",
+		})
+		return series, false
+	}
+
+	return series, true
+}
+
+// drafts in the series are hidden from everyone except the owner
+func (controller *SeriesControllerProvider) GetById(c *gin.Context) {
+	id, ok := parseSeriesId(c)
+	if !ok {
+		return
+	}
+
+	series, err := controller.service.GetById(id, auth.GetPrincipal(c))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, series)
+}
+
+func (controller *SeriesControllerProvider) GetByUser(c *gin.Context) {
+	series, err := controller.service.GetByOwner(c.Param("id"), auth.GetPrincipal(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, series)
+}
+
+func (controller *SeriesControllerProvider) Create(c *gin.Context) {
+	var newSeries entity.Series
+	if err := c.BindJSON(&newSeries); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if !service.IsValidSeriesData(newSeries.Title, newSeries.Description) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "invalid series data",
+		})
+		return
+	}
+
+	newSeries.Id = 0
+	newSeries.OwnerId = auth.GetPrincipal(c).UserId
+
+	createdSeries, err := controller.service.Create(newSeries)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, createdSeries)
+}
+
+func (controller *SeriesControllerProvider) Update(c *gin.Context) {
+	series, ok := controller.getOwnSeries(c, auth.GetPrincipal(c))
+	if !ok {
+		return
+	}
+
+	var updatedData entity.EditableSeriesData
+	if err := c.BindJSON(&updatedData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	title, description := series.Title, series.Description
+	if updatedData.Title != "" {
+		title = updatedData.Title
+	}
+	if updatedData.Description != nil {
+		description = *updatedData.Description
+	}
+
+	if !service.IsValidSeriesData(title, description) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "invalid series data",
+		})
+		return
+	}
+
+	updatedSeries, err := controller.service.Update(series.Id, updatedData)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, updatedSeries)
+}
+
+// the articles of the series are not deleted
+func (controller *SeriesControllerProvider) Delete(c *gin.Context) {
+	series, ok := controller.getOwnSeries(c, auth.GetPrincipal(c))
+	if !ok {
+		return
+	}
+
+	deletedSeries, err := controller.service.Delete(series.Id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, deletedSeries)
+}
+
+// adds one of the owner's articles to the end of the series
+func (controller *SeriesControllerProvider) AddArticle(c *gin.Context) {
+	principal := auth.GetPrincipal(c)
+
+	ownSeries, ok := controller.getOwnSeries(c, principal)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		ArticleId int `json:"article_id"`
+	}
+	if err := c.BindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	article, err := controller.articlesService.GetById(strconv.Itoa(body.ArticleId), principal)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "article was not found",
+		})
+		return
+	}
+
+	// only the owner's own articles can be in the series
+	if article.AuthorId != principal.UserId {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"message": "access denied",
+		})
+		return
+	}
+
+	series, err := controller.service.AddArticle(ownSeries.Id, article.Id)
+	if err != nil {
+		if err.Error() == "article is already in a series" {
+			c.JSON(http.StatusConflict, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, series)
+}
+
+func (controller *SeriesControllerProvider) RemoveArticle(c *gin.Context) {
+	ownSeries, ok := controller.getOwnSeries(c, auth.GetPrincipal(c))
+	if !ok {
+		return
+	}
+
+	articleId, err := strconv.Atoi(c.Param("articleId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "invalid article id",
+		})
+		return
+	}
+
+	series, err := controller.service.RemoveArticle(ownSeries.Id, articleId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, series)
+}
+
+// the body has all of the article ids of the series in the new order
+func (controller *SeriesControllerProvider) Reorder(c *gin.Context) {
+	ownSeries, ok := controller.getOwnSeries(c, auth.GetPrincipal(c))
+	if !ok {
+		return
+	}
+
+	var body struct {
+		ArticleIds []int `json:"article_ids"`
+	}
+	if err := c.BindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	series, err := controller.service.Reorder(ownSeries.Id, body.ArticleIds)
+	if err != nil {
+		if err.Error() == "article ids must match the articles of the series" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, series)
+}
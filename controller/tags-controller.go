@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/danielblagy/blog-webapp-server/auth"
+	"github.com/danielblagy/blog-webapp-server/pagination"
+	"github.com/danielblagy/blog-webapp-server/service"
+	"github.com/gin-gonic/gin"
+)
+
+type TagsController interface {
+	GetAll(c *gin.Context)
+	GetArticles(c *gin.Context)
+}
+
+type TagsControllerProvider struct {
+	service         service.TagsService
+	articlesService service.ArticlesService
+}
+
+func CreateTagsController(service service.TagsService, articlesService service.ArticlesService) TagsController {
+	return &TagsControllerProvider{
+		service:         service,
+		articlesService: articlesService,
+	}
+}
+
+func (controller *TagsControllerProvider) GetAll(c *gin.Context) {
+	tags, err := controller.service.GetAll()
+
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+func (controller *TagsControllerProvider) GetArticles(c *gin.Context) {
+	tag := service.NormalizeTag(c.Param("name"))
+	if tag == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "invalid tag",
+		})
+		return
+	}
+
+	params, err := pagination.ParseParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	articles, cursors, err := controller.articlesService.GetAll(tag, params, auth.GetPrincipal(c))
+
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, pagination.Page{Items: articles, Cursors: cursors})
+}
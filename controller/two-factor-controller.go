@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/danielblagy/blog-webapp-server/auth"
+	"github.com/danielblagy/blog-webapp-server/service"
+	"github.com/gin-gonic/gin"
+)
+
+type TwoFactorController interface {
+	GetStatus(c *gin.Context)
+	Enroll(c *gin.Context)
+	Confirm(c *gin.Context)
+	Disable(c *gin.Context)
+	RegenerateRecoveryCodes(c *gin.Context)
+}
+
+type TwoFactorControllerProvider struct {
+	service      service.TwoFactorService
+	usersService service.UsersService
+}
+
+func CreateTwoFactorController(service service.TwoFactorService, usersService service.UsersService) TwoFactorController {
+	return &TwoFactorControllerProvider{
+		service:      service,
+		usersService: usersService,
+	}
+}
+
+// reads the code from the request body, sends out a response on failure
+func bindTwoFactorCode(c *gin.Context) (string, bool) {
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := c.BindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return "", false
+	}
+
+	if body.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "code is not provided",
+		})
+		return "", false
+	}
+
+	return body.Code, true
+}
+
+func (controller *TwoFactorControllerProvider) GetStatus(c *gin.Context) {
+	principal := auth.GetPrincipal(c)
+
+	status, err := controller.service.GetStatus(strconv.Itoa(principal.UserId))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+func (controller *TwoFactorControllerProvider) Enroll(c *gin.Context) {
+	principal := auth.GetPrincipal(c)
+
+	user, err := controller.usersService.GetAccount(strconv.Itoa(principal.UserId))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	enrollment, err := controller.service.Enroll(user)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+func (controller *TwoFactorControllerProvider) Confirm(c *gin.Context) {
+	principal := auth.GetPrincipal(c)
+
+	code, ok := bindTwoFactorCode(c)
+	if !ok {
+		return
+	}
+
+	recoveryCodes, err := controller.service.Confirm(strconv.Itoa(principal.UserId), code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recovery_codes": recoveryCodes,
+	})
+}
+
+func (controller *TwoFactorControllerProvider) Disable(c *gin.Context) {
+	principal := auth.GetPrincipal(c)
+
+	code, ok := bindTwoFactorCode(c)
+	if !ok {
+		return
+	}
+
+	if err := controller.service.Disable(strconv.Itoa(principal.UserId), code); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "two factor authentication is disabled",
+	})
+}
+
+// replaces all recovery codes, used and unused
+func (controller *TwoFactorControllerProvider) RegenerateRecoveryCodes(c *gin.Context) {
+	principal := auth.GetPrincipal(c)
+
+	code, ok := bindTwoFactorCode(c)
+	if !ok {
+		return
+	}
+
+	recoveryCodes, err := controller.service.RegenerateRecoveryCodes(strconv.Itoa(principal.UserId), code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recovery_codes": recoveryCodes,
+	})
+}
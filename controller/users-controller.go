@@ -1,11 +1,15 @@
 package controller
 
 import (
+	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/danielblagy/blog-webapp-server/auth"
 	"github.com/danielblagy/blog-webapp-server/entity"
+	"github.com/danielblagy/blog-webapp-server/pagination"
+	"github.com/danielblagy/blog-webapp-server/ratelimit"
 	"github.com/danielblagy/blog-webapp-server/service"
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
@@ -14,10 +18,12 @@ import (
 type UsersController interface {
 	GetAll(c *gin.Context)
 	GetById(c *gin.Context)
+	GetByLogin(c *gin.Context)
 	Create(c *gin.Context)
 	Update(c *gin.Context)
 	Delete(c *gin.Context)
 	SignIn(c *gin.Context)
+	SignInTwoFactor(c *gin.Context)
 	Refresh(c *gin.Context)
 	Me(c *gin.Context)
 	Follow(c *gin.Context)
@@ -25,20 +31,137 @@ type UsersController interface {
 	GetFollowers(c *gin.Context)
 	GetFollowing(c *gin.Context)
 	IsFollowed(c *gin.Context)
+	SignOut(c *gin.Context)
+	GetSessions(c *gin.Context)
+	RevokeSession(c *gin.Context)
+	RequestEmailVerification(c *gin.Context)
+	VerifyEmail(c *gin.Context)
+	RequestPasswordReset(c *gin.Context)
+	ResetPassword(c *gin.Context)
+	SetAvatar(c *gin.Context)
+	RemoveAvatar(c *gin.Context)
+	SetHeader(c *gin.Context)
+	RemoveHeader(c *gin.Context)
 }
 
 type UsersControllerProvider struct {
-	service service.UsersService
+	service          service.UsersService
+	sessionsService  service.SessionsService
+	accountService   service.AccountService
+	twoFactorService service.TwoFactorService
+	limiter          *ratelimit.Limiter
 }
 
-func CreateUsersController(service service.UsersService) UsersController {
+func CreateUsersController(service service.UsersService, sessionsService service.SessionsService, accountService service.AccountService, twoFactorService service.TwoFactorService, limiter *ratelimit.Limiter) UsersController {
 	return &UsersControllerProvider{
-		service: service,
+		service:          service,
+		sessionsService:  sessionsService,
+		accountService:   accountService,
+		twoFactorService: twoFactorService,
+		limiter:          limiter,
 	}
 }
 
+var (
+	// sign in attempts for one login from all addresses
+	signInAccountLimit = ratelimit.Limit{Name: "signin-account", Burst: 10, Period: time.Minute * 15}
+
+	// after 5 wrong passwords in a row the account is locked for a minute, 2 minutes after the next one, and so on
+	signInLockout = ratelimit.Lockout{Name: "signin-lockout", Threshold: 5, Duration: time.Minute, MaxDuration: time.Hour, Window: time.Hour}
+)
+
+const (
+	maxProfileImageSize  = 10 << 20
+	maxMultipartOverhead = 1 << 20
+)
+
+// normalizes the email and checks that no other user has it, sends out a response on failure
+func (controller *UsersControllerProvider) checkEmail(c *gin.Context, email string, userId int) (string, bool) {
+	normalized, err := service.NormalizeEmail(email)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return "", false
+	}
+
+	if user, err := controller.service.GetByEmail(normalized); err == nil && user.Id != userId {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "this email is taken",
+		})
+		return "", false
+	}
+
+	return normalized, true
+}
+
+// the user can request the verification again, so failing to send it is only logged
+func (controller *UsersControllerProvider) sendEmailVerification(user entity.User) {
+	if err := controller.accountService.SendEmailVerification(user); err != nil {
+		log.Printf("Failed to send verification email to user %d: %s", user.Id, err.Error())
+	}
+}
+
+// starts a new session for the user and sends out the tokens
+func startSession(c *gin.Context, sessionsService service.SessionsService, user entity.User) {
+	session, refreshToken, err := sessionsService.Start(user.Id, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	auth.CreateTokenPair(c, strconv.Itoa(user.Id), user.Role, session.Id, refreshToken)
+}
+
+// Signs in the user after the password or the external identity is verified. With 2FA the tokens
+// are issued only after the code is sent to users/signin/2fa with the challenge token.
+func completeSignIn(c *gin.Context, sessionsService service.SessionsService, twoFactorService service.TwoFactorService, user entity.User) {
+	if user.Suspended {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "user is suspended",
+		})
+		return
+	}
+
+	twoFactorEnabled, err := twoFactorService.IsEnabled(strconv.Itoa(user.Id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if twoFactorEnabled {
+		challengeToken, err := twoFactorService.StartChallenge(user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"two_factor_required": true,
+			"challenge_token":     challengeToken,
+		})
+		return
+	}
+
+	startSession(c, sessionsService, user)
+}
+
 func (controller *UsersControllerProvider) GetAll(c *gin.Context) {
-	users, err := controller.service.GetAll()
+	params, err := pagination.ParseParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	users, cursors, err := controller.service.GetAll(params)
 
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
@@ -47,18 +170,16 @@ func (controller *UsersControllerProvider) GetAll(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"users": users,
-	})
+	c.JSON(http.StatusOK, pagination.Page{Items: users, Cursors: cursors})
 }
 
+// role and suspended are only shown to admins
 func (controller *UsersControllerProvider) GetById(c *gin.Context) {
 	UserToGetId := c.Param("id")
+	principal := auth.GetPrincipal(c)
 	hasAccessToPrivateArticles := false
-	claims, authorizedUser := auth.SilentlyCheckForAuthorization(c, "accessToken", "ACCESS_SECRET")
-	if authorizedUser {
-		AuthorizedUserId := claims.Id
-		hasAccessToPrivateArticles = AuthorizedUserId == UserToGetId
+	if !principal.IsAnonymous() {
+		hasAccessToPrivateArticles = strconv.Itoa(principal.UserId) == UserToGetId
 	}
 
 	user, err := controller.service.GetById(UserToGetId, hasAccessToPrivateArticles)
@@ -70,6 +191,32 @@ func (controller *UsersControllerProvider) GetById(c *gin.Context) {
 		return
 	}
 
+	user.AdminView = principal.Role == entity.RoleAdmin
+	c.JSON(http.StatusOK, user)
+}
+
+// the permalink of the user's profile, /@login
+func (controller *UsersControllerProvider) GetByLogin(c *gin.Context) {
+	user, err := controller.service.GetByLogin(c.Param("login"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "user was not found",
+		})
+		return
+	}
+
+	principal := auth.GetPrincipal(c)
+	hasAccessToPrivateArticles := principal.UserId == user.Id
+
+	user, err = controller.service.GetById(strconv.Itoa(user.Id), hasAccessToPrivateArticles)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	user.AdminView = principal.Role == entity.RoleAdmin
 	c.JSON(http.StatusOK, user)
 }
 
@@ -84,13 +231,37 @@ func (controller *UsersControllerProvider) Create(c *gin.Context) {
 
 	// TODO: test user data validation
 
-	if newUser.Login == "" || newUser.Password == "" || newUser.FullName == "" {
+	if newUser.Login == "" || newUser.Password == "" || newUser.FullName == "" || newUser.Email == nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"message": "invalid user data",
 		})
 		return
 	}
 
+	email, ok := controller.checkEmail(c, *newUser.Email, 0)
+	if !ok {
+		return
+	}
+	newUser.Email = &email
+
+	profile := entity.EditableUserData{Bio: &newUser.Bio, Website: &newUser.Website, Links: newUser.Links, Location: &newUser.Location}
+	if err := service.NormalizeProfile(&profile); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	newUser.Links = profile.Links
+
+	// profile images are uploaded separately
+	newUser.Avatar = nil
+	newUser.Header = nil
+
+	// roles can only be granted by admins, emails are verified by following the link sent to them
+	newUser.Role = entity.RoleUser
+	newUser.Suspended = false
+	newUser.EmailVerified = false
+
 	_, err := controller.service.GetByLogin(newUser.Login)
 	if err == nil {
 		c.JSON(http.StatusConflict, gin.H{
@@ -107,18 +278,14 @@ func (controller *UsersControllerProvider) Create(c *gin.Context) {
 		return
 	}
 
+	controller.sendEmailVerification(createdUser)
+
+	createdUser.Self = true
 	c.JSON(http.StatusCreated, createdUser)
 }
 
 func (controller *UsersControllerProvider) Update(c *gin.Context) {
-	claims, ok := auth.CheckForAuthorization(c, "accessToken", "ACCESS_SECRET")
-	if !ok {
-		return
-	}
-
-	// if a token is provided and valid, run update logic
-
-	userId := claims.Id
+	userId := strconv.Itoa(auth.GetPrincipal(c).UserId)
 
 	var updatedData entity.EditableUserData
 	if err := c.BindJSON(&updatedData); err != nil {
@@ -128,6 +295,22 @@ func (controller *UsersControllerProvider) Update(c *gin.Context) {
 		return
 	}
 
+	if err := service.NormalizeProfile(&updatedData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if updatedData.Email != "" {
+		userIdInt, _ := strconv.Atoi(userId)
+		email, ok := controller.checkEmail(c, updatedData.Email, userIdInt)
+		if !ok {
+			return
+		}
+		updatedData.Email = email
+	}
+
 	updatedUser, err := controller.service.Update(userId, updatedData)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
@@ -136,19 +319,18 @@ func (controller *UsersControllerProvider) Update(c *gin.Context) {
 		return
 	}
 
+	// a changed email has to be verified again
+	if updatedData.Email != "" && !updatedUser.EmailVerified {
+		controller.sendEmailVerification(updatedUser)
+	}
+
+	updatedUser.Self = true
 	c.JSON(http.StatusOK, updatedUser)
 }
 
-// TODO: check if client is an authorized administrator
+// deletes the signed in user, admins can delete other users via /admin/users/:id
 func (controller *UsersControllerProvider) Delete(c *gin.Context) {
-	claims, ok := auth.CheckForAuthorization(c, "accessToken", "ACCESS_SECRET")
-	if !ok {
-		return
-	}
-
-	// if a token is provided and valid, run delete logic
-
-	userId := claims.Id
+	userId := strconv.Itoa(auth.GetPrincipal(c).UserId)
 
 	user, err := controller.service.Delete(userId)
 
@@ -159,6 +341,15 @@ func (controller *UsersControllerProvider) Delete(c *gin.Context) {
 		return
 	}
 
+	if err := controller.sessionsService.RevokeAll(userId); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	auth.ClearTokens(c)
+
 	c.JSON(http.StatusOK, user)
 }
 
@@ -171,6 +362,10 @@ func (controller *UsersControllerProvider) SignIn(c *gin.Context) {
 		return
 	}
 
+	if !controller.limiter.Allow(c, signInAccountLimit, claimedUser.Login) || !controller.limiter.CheckLockout(c, signInLockout, claimedUser.Login) {
+		return
+	}
+
 	user, err := controller.service.GetByLogin(claimedUser.Login)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
@@ -179,41 +374,166 @@ func (controller *UsersControllerProvider) SignIn(c *gin.Context) {
 		return
 	}
 
+	// users signed up with an OpenID Connect provider have no password until they reset it
+	if user.Password == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"message": "user has no password, sign in with a linked provider",
+		})
+		return
+	}
+
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(claimedUser.Password)); err != nil {
+		controller.limiter.AddFailure(signInLockout, user.Login)
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"message": err.Error(),
 		})
 		return
 	}
 
-	auth.CreateTokenPair(c, strconv.Itoa(user.Id))
+	controller.limiter.ResetFailures(signInLockout, user.Login)
+
+	completeSignIn(c, controller.sessionsService, controller.twoFactorService, user)
 }
 
+// second step of signing in with 2FA, accepts a TOTP code or a recovery code
+func (controller *UsersControllerProvider) SignInTwoFactor(c *gin.Context) {
+	var body struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+	}
+	if err := c.BindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	userId, err := controller.twoFactorService.CompleteChallenge(body.ChallengeToken, body.Code)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// the user could have been suspended since entering the password
+	user, err := controller.service.GetAccount(userId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "user doesn't exist",
+		})
+		return
+	}
+
+	if user.Suspended {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "user is suspended",
+		})
+		return
+	}
+
+	startSession(c, controller.sessionsService, user)
+}
+
+// Exchanges the refresh token for a new token pair, the used refresh token becomes invalid.
+// Reusing a refresh token revokes its session, so a stolen token stops working
+// as soon as either the thief or the user refreshes after the other one did.
 func (controller *UsersControllerProvider) Refresh(c *gin.Context) {
-	claims, ok := auth.CheckForAuthorization(c, "refreshToken", "REFRESH_SECRET")
-	if !ok {
+	refreshToken, err := c.Cookie("refreshToken")
+	if err != nil || refreshToken == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"message": "refresh token is not provided",
+		})
 		return
 	}
 
-	// if a token is provided and valid, run update logic
+	// the token is checked before rotating it, so refreshing doesn't use up the token of a suspended user
+	session, err := controller.sessionsService.GetByRefreshToken(refreshToken)
+	if err != nil {
+		auth.ClearTokens(c)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// role could have changed since the tokens were issued
+	user, err := controller.service.GetAccount(strconv.Itoa(session.UserId))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"message": "user doesn't exist",
+		})
+		return
+	}
+
+	if user.Suspended {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "user is suspended",
+		})
+		return
+	}
 
-	userId := claims.Id
+	session, newRefreshToken, err := controller.sessionsService.Refresh(refreshToken, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		auth.ClearTokens(c)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
 
-	auth.CreateTokenPair(c, userId)
+	auth.CreateTokenPair(c, strconv.Itoa(user.Id), user.Role, session.Id, newRefreshToken)
 }
 
-func (controller *UsersControllerProvider) Me(c *gin.Context) {
-	claims, ok := auth.CheckForAuthorization(c, "accessToken", "ACCESS_SECRET")
-	if !ok {
+// revokes the current session, works with an expired access token as long as the refresh token is valid
+func (controller *UsersControllerProvider) SignOut(c *gin.Context) {
+	var session entity.Session
+	found := false
+
+	if refreshToken, err := c.Cookie("refreshToken"); err == nil && refreshToken != "" {
+		if tokenSession, err := controller.sessionsService.GetByRefreshToken(refreshToken); err == nil {
+			session = tokenSession
+			found = true
+		}
+	}
+
+	if !found {
+		// personal access tokens have no session
+		if principal := auth.GetPrincipal(c); principal.SessionId != 0 {
+			session.Id = principal.SessionId
+			session.UserId = principal.UserId
+			found = true
+		}
+	}
+
+	if !found {
+		auth.ClearTokens(c)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"message": "not signed in",
+		})
 		return
 	}
 
-	// if a token is provided and valid, run 'me' logic
+	if _, err := controller.sessionsService.Revoke(strconv.Itoa(session.UserId), strconv.Itoa(session.Id)); err != nil {
+		// the session is revoked already
+		auth.ClearTokens(c)
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
 
-	userId := claims.Id
+	auth.ClearTokens(c)
 
-	user, err := controller.service.GetById(userId, true)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "signed out",
+	})
+}
 
+func (controller *UsersControllerProvider) GetSessions(c *gin.Context) {
+	principal := auth.GetPrincipal(c)
+
+	sessions, err := controller.sessionsService.GetActive(strconv.Itoa(principal.UserId))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"message": err.Error(),
@@ -221,18 +541,51 @@ func (controller *UsersControllerProvider) Me(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].Id == principal.SessionId
+	}
+
+	c.JSON(http.StatusOK, sessions)
 }
 
-func (controller *UsersControllerProvider) Follow(c *gin.Context) {
-	claims, ok := auth.CheckForAuthorization(c, "accessToken", "ACCESS_SECRET")
-	if !ok {
+// signs out one of the user's sessions, e.g. on a lost device.
+// Its refresh token stops working right away, issued access tokens stay valid until they expire.
+func (controller *UsersControllerProvider) RevokeSession(c *gin.Context) {
+	principal := auth.GetPrincipal(c)
+
+	session, err := controller.sessionsService.Revoke(strconv.Itoa(principal.UserId), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
 		return
 	}
 
-	// if a token is provided and valid, run 'me' logic
+	if session.Id == principal.SessionId {
+		auth.ClearTokens(c)
+	}
+
+	c.JSON(http.StatusOK, session)
+}
 
-	userId := claims.Id
+func (controller *UsersControllerProvider) Me(c *gin.Context) {
+	userId := strconv.Itoa(auth.GetPrincipal(c).UserId)
+
+	user, err := controller.service.GetById(userId, true)
+
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	user.Self = true
+	c.JSON(http.StatusOK, user)
+}
+
+func (controller *UsersControllerProvider) Follow(c *gin.Context) {
+	userId := strconv.Itoa(auth.GetPrincipal(c).UserId)
 
 	user, err := controller.service.GetById(userId, true)
 
@@ -264,14 +617,7 @@ func (controller *UsersControllerProvider) Follow(c *gin.Context) {
 }
 
 func (controller *UsersControllerProvider) Unfollow(c *gin.Context) {
-	claims, ok := auth.CheckForAuthorization(c, "accessToken", "ACCESS_SECRET")
-	if !ok {
-		return
-	}
-
-	// if a token is provided and valid, run 'me' logic
-
-	userId := claims.Id
+	userId := strconv.Itoa(auth.GetPrincipal(c).UserId)
 
 	user, err := controller.service.GetById(userId, true)
 
@@ -305,7 +651,15 @@ func (controller *UsersControllerProvider) Unfollow(c *gin.Context) {
 func (controller *UsersControllerProvider) GetFollowers(c *gin.Context) {
 	user := c.Param("id")
 
-	followers, err := controller.service.GetFollowers(user)
+	params, err := pagination.ParseParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	followers, cursors, err := controller.service.GetFollowers(user, params)
 
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
@@ -314,33 +668,34 @@ func (controller *UsersControllerProvider) GetFollowers(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, followers)
+	c.JSON(http.StatusOK, pagination.Page{Items: followers, Cursors: cursors})
 }
 
 func (controller *UsersControllerProvider) GetFollowing(c *gin.Context) {
 	user := c.Param("id")
 
-	following, err := controller.service.GetFollowing(user)
-
+	params, err := pagination.ParseParams(c)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
+		c.JSON(http.StatusBadRequest, gin.H{
 			"message": err.Error(),
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, following)
-}
+	following, cursors, err := controller.service.GetFollowing(user, params)
 
-func (controller *UsersControllerProvider) IsFollowed(c *gin.Context) {
-	claims, ok := auth.CheckForAuthorization(c, "accessToken", "ACCESS_SECRET")
-	if !ok {
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
 		return
 	}
 
-	// if a token is provided and valid, run logic
+	c.JSON(http.StatusOK, pagination.Page{Items: following, Cursors: cursors})
+}
 
-	userId := claims.Id
+func (controller *UsersControllerProvider) IsFollowed(c *gin.Context) {
+	userId := strconv.Itoa(auth.GetPrincipal(c).UserId)
 
 	userToCheck := c.Param("id")
 	isFollowed, err := controller.service.IsFollowed(userId, userToCheck)
@@ -354,3 +709,205 @@ func (controller *UsersControllerProvider) IsFollowed(c *gin.Context) {
 
 	c.JSON(http.StatusOK, isFollowed)
 }
+
+// sends the verification link to the user's email again
+func (controller *UsersControllerProvider) RequestEmailVerification(c *gin.Context) {
+	principal := auth.GetPrincipal(c)
+
+	user, err := controller.service.GetAccount(strconv.Itoa(principal.UserId))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if user.Email == nil || user.EmailVerified {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "there is no email to verify",
+		})
+		return
+	}
+
+	if err := controller.accountService.SendEmailVerification(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "verification email is sent",
+	})
+}
+
+func (controller *UsersControllerProvider) VerifyEmail(c *gin.Context) {
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := c.BindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	user, err := controller.accountService.VerifyEmail(body.Token)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// always succeeds for a valid email, so it can't be used to find out whether a user with the email exists
+func (controller *UsersControllerProvider) RequestPasswordReset(c *gin.Context) {
+	var body struct {
+		Email string `json:"email"`
+	}
+	if err := c.BindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	email, err := service.NormalizeEmail(body.Email)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err := controller.accountService.SendPasswordReset(email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "if a user with this email exists, a password reset link is sent to it",
+	})
+}
+
+// sets the new password and signs the user out of all sessions
+func (controller *UsersControllerProvider) ResetPassword(c *gin.Context) {
+	var body struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := c.BindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if body.Password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "invalid password",
+		})
+		return
+	}
+
+	user, err := controller.accountService.ResetPassword(body.Token, body.Password)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err := controller.sessionsService.RevokeAll(strconv.Itoa(user.Id)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	auth.ClearTokens(c)
+
+	c.JSON(http.StatusOK, user)
+}
+
+func (controller *UsersControllerProvider) SetAvatar(c *gin.Context) {
+	controller.setProfileImage(c, entity.ProfileImageAvatar)
+}
+
+func (controller *UsersControllerProvider) RemoveAvatar(c *gin.Context) {
+	controller.removeProfileImage(c, entity.ProfileImageAvatar)
+}
+
+func (controller *UsersControllerProvider) SetHeader(c *gin.Context) {
+	controller.setProfileImage(c, entity.ProfileImageHeader)
+}
+
+func (controller *UsersControllerProvider) RemoveHeader(c *gin.Context) {
+	controller.removeProfileImage(c, entity.ProfileImageHeader)
+}
+
+// the image is sent in 'image' field of a multipart form
+func (controller *UsersControllerProvider) setProfileImage(c *gin.Context, kind string) {
+	userId := strconv.Itoa(auth.GetPrincipal(c).UserId)
+
+	// the form may be a little larger than the image itself
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxProfileImageSize+maxMultipartOverhead)
+
+	file, header, err := c.Request.FormFile("image")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "image is missing or the form is too large",
+		})
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxProfileImageSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"message": "image is too large",
+		})
+		return
+	}
+
+	user, err := controller.service.SetProfileImage(userId, kind, file)
+	if err != nil {
+		switch err.Error() {
+		case "unsupported image format", "invalid image":
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{
+				"message": err.Error(),
+			})
+		case "image dimensions are too large":
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"message": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": err.Error(),
+			})
+		}
+		return
+	}
+
+	user.Self = true
+	c.JSON(http.StatusOK, user)
+}
+
+func (controller *UsersControllerProvider) removeProfileImage(c *gin.Context, kind string) {
+	userId := strconv.Itoa(auth.GetPrincipal(c).UserId)
+
+	user, err := controller.service.RemoveProfileImage(userId, kind)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	user.Self = true
+	c.JSON(http.StatusOK, user)
+}
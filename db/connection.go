@@ -34,9 +34,62 @@ func SetUpConnection() (*gorm.DB, error) {
 
 	// make migrations to the db (will be done only once, if the entities have never been created before)
 	database.AutoMigrate(&entity.User{})
+
+	// titles are unique for the author, duplicates left from before the constraint get their id appended,
+	// the renamed articles are logged so their authors can be told
+	if database.Migrator().HasTable(&entity.Article{}) {
+		var renamed []int
+		database.Raw(`update articles set title = left(title, 290) || ' (' || id || ')' where id in (
+			select id from (select id, row_number() over (partition by author_id, title order by id) as n from articles) numbered where n > 1
+		) returning id`).Scan(&renamed)
+		if len(renamed) > 0 {
+			log.Printf("Renamed %d articles with duplicate titles, article ids: %v", len(renamed), renamed)
+		}
+	}
 	database.AutoMigrate(&entity.Article{})
+	// articles published before first publishing was recorded count as first published when created
+	database.Exec("update articles set published_at = created_at where published = true and published_at is null")
 	database.AutoMigrate(&entity.Follower{})
 	database.AutoMigrate(&entity.Save{})
+	database.AutoMigrate(&entity.Tag{})
+	database.AutoMigrate(&entity.ArticleTag{})
+	database.AutoMigrate(&entity.Comment{})
+	database.AutoMigrate(&entity.ArticleRevision{})
+	database.AutoMigrate(&entity.AuditLogEntry{})
+	database.AutoMigrate(&entity.Session{})
+	database.AutoMigrate(&entity.RefreshToken{})
+	database.AutoMigrate(&entity.AccountToken{})
+	database.AutoMigrate(&entity.TwoFactor{})
+	database.AutoMigrate(&entity.RecoveryCode{})
+	database.AutoMigrate(&entity.Identity{})
+	database.AutoMigrate(&entity.PersonalAccessToken{})
+	database.AutoMigrate(&entity.RateLimitBucket{})
+	database.AutoMigrate(&entity.RateLimitFailures{})
+	database.AutoMigrate(&entity.Reaction{})
+	database.AutoMigrate(&entity.Notification{})
+	database.AutoMigrate(&entity.NotificationPreference{})
+	database.AutoMigrate(&entity.Media{})
+	database.AutoMigrate(&entity.ArticleMedia{})
+	database.AutoMigrate(&entity.Series{})
+	database.AutoMigrate(&entity.SeriesEntry{})
+	database.AutoMigrate(&entity.ArticleSlug{})
+	database.AutoMigrate(&entity.Collection{})
+	database.AutoMigrate(&entity.CollectionItem{})
+	database.AutoMigrate(&entity.CollectionFollower{})
+
+	// articles created before slugs existed have empty slugs until service.GenerateMissingSlugs runs
+	database.Exec("create unique index if not exists idx_articles_author_slug on articles (author_id, slug) where slug <> ''")
+
+	// full-text search columns, postgres keeps generated columns up to date on every insert and update
+	database.Exec(`alter table articles add column if not exists search_vector tsvector generated always as (
+		setweight(to_tsvector('simple', coalesce(title, '')), 'A') || setweight(to_tsvector('simple', coalesce(content, '')), 'B')
+	) stored`)
+	database.Exec("create index if not exists idx_articles_search_vector on articles using gin (search_vector)")
+
+	database.Exec(`alter table users add column if not exists search_vector tsvector generated always as (
+		setweight(to_tsvector('simple', coalesce(login, '')), 'A') || setweight(to_tsvector('simple', coalesce(full_name, '')), 'A')
+	) stored`)
+	database.Exec("create index if not exists idx_users_search_vector on users using gin (search_vector)")
 
 	return database, dbConnectionError
 }
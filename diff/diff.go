@@ -0,0 +1,120 @@
+package diff
+
+import "strings"
+
+type Operation string
+
+// the largest lcs table, in cells, a diff is computed with (about 8 MB),
+// changed parts that are larger are shown as all of a deleted and all of b inserted
+const maxTableSize = 1000000
+
+const (
+	Equal  Operation = "equal"
+	Insert Operation = "insert"
+	Delete Operation = "delete"
+)
+
+type Line struct {
+	Operation Operation `json:"op"`
+	Text      string    `json:"text"`
+}
+
+// Returns a line-level diff that turns a into b, based on the longest common subsequence of lines.
+// If the changed part is too large to compare, it's replaced as a whole.
+func Lines(a string, b string) []Line {
+	aLines := splitLines(a)
+	bLines := splitLines(b)
+
+	// common prefix and suffix don't need to go through the lcs table
+	prefix := 0
+	for prefix < len(aLines) && prefix < len(bLines) && aLines[prefix] == bLines[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(aLines)-prefix && suffix < len(bLines)-prefix &&
+		aLines[len(aLines)-1-suffix] == bLines[len(bLines)-1-suffix] {
+		suffix++
+	}
+
+	lines := []Line{}
+	for _, text := range aLines[:prefix] {
+		lines = append(lines, Line{Operation: Equal, Text: text})
+	}
+
+	lines = append(lines, diffMiddle(aLines[prefix:len(aLines)-suffix], bLines[prefix:len(bLines)-suffix])...)
+
+	for _, text := range aLines[len(aLines)-suffix:] {
+		lines = append(lines, Line{Operation: Equal, Text: text})
+	}
+
+	return lines
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return []string{}
+	}
+	return strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+}
+
+func diffMiddle(a []string, b []string) []Line {
+	if len(a) > 0 && len(b) > maxTableSize/len(a) {
+		return replace(a, b)
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	lines := []Line{}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if a[i] == b[j] {
+			lines = append(lines, Line{Operation: Equal, Text: a[i]})
+			i++
+			j++
+		} else if lcs[i+1][j] >= lcs[i][j+1] {
+			lines = append(lines, Line{Operation: Delete, Text: a[i]})
+			i++
+		} else {
+			lines = append(lines, Line{Operation: Insert, Text: b[j]})
+			j++
+		}
+	}
+
+	for ; i < len(a); i++ {
+		lines = append(lines, Line{Operation: Delete, Text: a[i]})
+	}
+
+	for ; j < len(b); j++ {
+		lines = append(lines, Line{Operation: Insert, Text: b[j]})
+	}
+
+	return lines
+}
+
+func replace(a []string, b []string) []Line {
+	lines := make([]Line, 0, len(a)+len(b))
+	for _, text := range a {
+		lines = append(lines, Line{Operation: Delete, Text: text})
+	}
+	for _, text := range b {
+		lines = append(lines, Line{Operation: Insert, Text: text})
+	}
+	return lines
+}
@@ -0,0 +1,64 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want []Line
+	}{
+		{"both empty", "", "", []Line{}},
+		{"equal", "a\nb", "a\nb", []Line{{Equal, "a"}, {Equal, "b"}}},
+		{"from empty", "", "a\nb", []Line{{Insert, "a"}, {Insert, "b"}}},
+		{"to empty", "a\nb", "", []Line{{Delete, "a"}, {Delete, "b"}}},
+		{"inserted line", "a\nc", "a\nb\nc", []Line{{Equal, "a"}, {Insert, "b"}, {Equal, "c"}}},
+		{"deleted line", "a\nb\nc", "a\nc", []Line{{Equal, "a"}, {Delete, "b"}, {Equal, "c"}}},
+		{"changed line", "a\nb\nc", "a\nx\nc", []Line{{Equal, "a"}, {Delete, "b"}, {Insert, "x"}, {Equal, "c"}}},
+		{
+			"common lines in the middle", "a\nb\nc\nd", "x\nb\nc\ny",
+			[]Line{{Delete, "a"}, {Insert, "x"}, {Equal, "b"}, {Equal, "c"}, {Delete, "d"}, {Insert, "y"}},
+		},
+		{"windows line endings", "a\r\nb", "a\nb", []Line{{Equal, "a"}, {Equal, "b"}}},
+	}
+
+	for _, test := range tests {
+		if got := Lines(test.a, test.b); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: Lines() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+// changed parts that are too large to compare are replaced as a whole, the common prefix and suffix are kept
+func TestLinesTooLarge(t *testing.T) {
+	a := make([]string, 2000)
+	b := make([]string, 2000)
+	for i := range a {
+		a[i] = "a" + strings.Repeat("x", i%7)
+		b[i] = "b" + strings.Repeat("x", i%7)
+	}
+
+	lines := Lines("first\n"+strings.Join(a, "\n")+"\nlast", "first\n"+strings.Join(b, "\n")+"\nlast")
+	if len(lines) != 4002 {
+		t.Fatalf("len(Lines()) = %d, want 4002", len(lines))
+	}
+
+	if lines[0] != (Line{Equal, "first"}) || lines[len(lines)-1] != (Line{Equal, "last"}) {
+		t.Errorf("the common prefix and suffix are not kept: %v, %v", lines[0], lines[len(lines)-1])
+	}
+
+	for i, line := range lines[1 : len(lines)-1] {
+		want := Delete
+		if i >= len(a) {
+			want = Insert
+		}
+		if line.Operation != want {
+			t.Fatalf("line %d is %s, want %s", i+1, line.Operation, want)
+		}
+	}
+}
@@ -1,21 +1,69 @@
 package entity
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Article struct {
-	Id        int       `json:"id" gorm:"primaryKey"`
-	AuthorId  int       `json:"author_id" gorm:"not null"`
-	Title     string    `json:"title" gorm:"type:varchar(300);not null"`
-	Content   string    `json:"content" gorm:"type:text;not null"`
-	Published bool      `json:"published" gorm:"not null"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Author    User      `json:"author" gorm:"-"`
-	Saves     int       `json:"saves" gorm:"-"`
+	Id          int               `json:"id" gorm:"primaryKey"`
+	AuthorId    int               `json:"author_id" gorm:"not null;uniqueIndex:idx_author_title"`
+	Title       string            `json:"title" gorm:"type:varchar(300);not null;uniqueIndex:idx_author_title"`
+	Slug        string            `json:"slug" gorm:"type:varchar(100);not null;default:''"` // unique for the author, made from the title
+	Content     string            `json:"content" gorm:"type:text;not null"`
+	Format      string            `json:"format" gorm:"type:varchar(20);not null;default:plain"` // 'plain' or 'markdown'
+	Published   bool              `json:"published" gorm:"not null"`
+	PublishAt   *time.Time        `json:"publish_at" gorm:"index"` // when the scheduler will publish the article, nil if not scheduled
+	PublishedAt *time.Time        `json:"-"`                       // when the article was first published, followers are only notified then
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	Author      User              `json:"author" gorm:"-"`
+	Saves       int               `json:"saves" gorm:"-"`
+	Reactions   map[string]int    `json:"reactions" gorm:"-"`              // counts of every reaction type
+	MyReactions []string          `json:"my_reactions,omitempty" gorm:"-"` // reaction types of the signed in user
+	Tags        []string          `json:"tags" gorm:"-"`
+	MediaIds    []int             `json:"media_ids" gorm:"-"` // uploaded media used in the article, owned by the author
+	Media       []Media           `json:"media" gorm:"-"`
+	Html        string            `json:"html" gorm:"-"`             // content rendered to sanitized html
+	Series      *SeriesNavigation `json:"series,omitempty" gorm:"-"` // set only for a single article, nil if it's not in a series
 }
 
 type EditableArticleData struct {
-	Title     string `json:"title"`
-	Content   string `json:"content"`
-	Published bool   `json:"published"`
+	Title     string       `json:"title"`
+	Content   string       `json:"content"`
+	Format    string       `json:"format"` // format is left as is if not provided
+	Published bool         `json:"published"`
+	Tags      []string     `json:"tags"`       // tags are left as is if not provided
+	MediaIds  []int        `json:"media_ids"`  // media are left as is if not provided
+	PublishAt OptionalTime `json:"publish_at"` // schedules publishing, null cancels the schedule, it's left as is if not provided
+}
+
+// timestamp that tells a field that wasn't provided apart from an explicit null
+type OptionalTime struct {
+	Set   bool       // the field was provided
+	Value *time.Time // nil if the field was null
+}
+
+func (t *OptionalTime) UnmarshalJSON(data []byte) error {
+	t.Set = true
+	if string(data) == "null" {
+		t.Value = nil
+		return nil
+	}
+
+	var value time.Time
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	t.Value = &value
+	return nil
+}
+
+// a previous slug of an article, old links are redirected to the current one
+type ArticleSlug struct {
+	Id        int       `json:"id" gorm:"primaryKey"`
+	ArticleId int       `json:"article_id" gorm:"not null;index"`
+	AuthorId  int       `json:"author_id" gorm:"not null;uniqueIndex:idx_author_slug"`
+	Slug      string    `json:"slug" gorm:"type:varchar(100);not null;uniqueIndex:idx_author_slug"`
+	CreatedAt time.Time `json:"created_at"`
 }
@@ -0,0 +1,14 @@
+package entity
+
+import "time"
+
+// record of an administrative action, e.g. a role change
+type AuditLogEntry struct {
+	Id         int       `json:"id" gorm:"primaryKey"`
+	ActorId    *int      `json:"actor_id"` // nil for actions done by the server itself, e.g. bootstrapping the first admin
+	Action     string    `json:"action" gorm:"type:varchar(50);not null;index"`
+	TargetType string    `json:"target_type" gorm:"type:varchar(20);not null"` // 'user' or 'article'
+	TargetId   int       `json:"target_id" gorm:"not null"`
+	Details    string    `json:"details" gorm:"type:text;not null"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,36 @@
+package entity
+
+import "time"
+
+// a named, ordered list of the user's saved articles, public collections can be viewed and followed by anyone
+type Collection struct {
+	Id          int            `json:"id" gorm:"primaryKey"`
+	OwnerId     int            `json:"owner_id" gorm:"not null;index"`
+	Name        string         `json:"name" gorm:"type:varchar(100);not null"`
+	Description string         `json:"description" gorm:"type:text;not null;default:''"`
+	Public      bool           `json:"public" gorm:"not null;default:false"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	Owner       User           `json:"owner" gorm:"-"`
+	Followers   int            `json:"followers" gorm:"-"`
+	Following   bool           `json:"following" gorm:"-"` // whether the signed in user follows the collection
+	Size        int            `json:"size" gorm:"-"`      // count of the articles visible to the viewer
+	Articles    []SavedArticle `json:"articles" gorm:"-"`  // in the collection order, only for a single collection
+}
+
+type EditableCollectionData struct {
+	Name        string  `json:"name"`
+	Description *string `json:"description"` // description is left as is if not provided
+	Public      *bool   `json:"public"`      // visibility is left as is if not provided
+}
+
+type CollectionItem struct {
+	CollectionId int `json:"collection_id" gorm:"not null;uniqueIndex:idx_collection_article"`
+	ArticleId    int `json:"article_id" gorm:"not null;uniqueIndex:idx_collection_article;index"`
+	Position     int `json:"position" gorm:"not null"`
+}
+
+type CollectionFollower struct {
+	CollectionId int `json:"collection_id" gorm:"not null;uniqueIndex:idx_collection_user"`
+	UserId       int `json:"user_id" gorm:"not null;uniqueIndex:idx_collection_user;index"`
+}
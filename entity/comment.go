@@ -0,0 +1,19 @@
+package entity
+
+import "time"
+
+type Comment struct {
+	Id        int       `json:"id" gorm:"primaryKey"`
+	ArticleId int       `json:"article_id" gorm:"not null;index"`
+	AuthorId  int       `json:"author_id" gorm:"not null"`
+	ParentId  *int      `json:"parent_id"` // nil for top-level comments
+	Body      string    `json:"body" gorm:"type:text;not null"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Author    User      `json:"author" gorm:"-"`
+	Replies   []Comment `json:"replies" gorm:"-"`
+}
+
+type EditableCommentData struct {
+	Body string `json:"body"`
+}
@@ -0,0 +1,13 @@
+package entity
+
+import "time"
+
+// account at an external OpenID Connect provider linked to the user
+type Identity struct {
+	Id        int       `json:"id" gorm:"primaryKey"`
+	UserId    int       `json:"user_id" gorm:"not null;uniqueIndex:idx_identity_user_provider"`
+	Provider  string    `json:"provider" gorm:"type:varchar(50);not null;uniqueIndex:idx_identity_subject;uniqueIndex:idx_identity_user_provider"`
+	Subject   string    `json:"-" gorm:"type:varchar(255);not null;uniqueIndex:idx_identity_subject"` // user id at the provider
+	Email     string    `json:"email" gorm:"type:varchar(320)"`
+	CreatedAt time.Time `json:"created_at"`
+}
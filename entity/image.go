@@ -0,0 +1,68 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// profile images
+const (
+	ProfileImageAvatar = "avatar"
+	ProfileImageHeader = "header"
+)
+
+// Storage keys of an image's sizes by size name, e.g. "small" -> "avatars/12/5f0c-small.jpg".
+// It's sent to clients with the urls of the sizes instead of the keys.
+type ImageSet map[string]string
+
+var mediaUrlResolver = func(key string) string { return key }
+
+// sets the function that turns storage keys into urls
+func SetMediaUrlResolver(resolver func(key string) string) {
+	mediaUrlResolver = resolver
+}
+
+func (set ImageSet) MarshalJSON() ([]byte, error) {
+	if set == nil {
+		return []byte("null"), nil
+	}
+
+	urls := map[string]string{}
+	for size, key := range set {
+		urls[size] = mediaUrlResolver(key)
+	}
+	return json.Marshal(urls)
+}
+
+// kept as a json object with the keys
+func (set ImageSet) Value() (driver.Value, error) {
+	if len(set) == 0 {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(map[string]string(set))
+	return string(data), err
+}
+
+func (set *ImageSet) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*set = nil
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return errors.New("invalid image set value")
+	}
+
+	var keys map[string]string
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return err
+	}
+	*set = keys
+	return nil
+}
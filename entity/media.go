@@ -0,0 +1,46 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// processing states of uploaded images
+const (
+	MediaPending = "pending"
+	MediaReady   = "ready"
+	MediaFailed  = "failed"
+)
+
+// an uploaded file, e.g. an image used in articles
+type Media struct {
+	Id          int       `json:"id" gorm:"primaryKey"`
+	OwnerId     int       `json:"owner_id" gorm:"not null;index"`
+	Key         string    `json:"-" gorm:"type:varchar(300);not null;uniqueIndex"` // storage key
+	ContentType string    `json:"content_type" gorm:"type:varchar(100);not null"`
+	Size        int64     `json:"size" gorm:"not null"`
+	Filename    string    `json:"filename" gorm:"type:varchar(255);not null"` // the name of the uploaded file
+	Width       int       `json:"width" gorm:"not null;default:0"`
+	Height      int       `json:"height" gorm:"not null;default:0"`
+	Blurhash    string    `json:"blurhash" gorm:"type:varchar(100);not null;default:''"`
+	Variants    ImageSet  `json:"variants" gorm:"type:text"` // resized copies by size name
+	Status      string    `json:"status" gorm:"type:varchar(20);not null;default:'ready';index"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	ProcessingUntil    *time.Time `json:"-"` // set while a worker is processing the media
+	ProcessingAttempts int        `json:"-" gorm:"not null;default:0"`
+}
+
+func (m Media) MarshalJSON() ([]byte, error) {
+	type media Media // prevent recursion
+	return json.Marshal(struct {
+		media
+		Url string `json:"url"`
+	}{media(m), mediaUrlResolver(m.Key)})
+}
+
+type ArticleMedia struct {
+	ArticleId int `json:"article_id" gorm:"not null;uniqueIndex:idx_article_media"`
+	MediaId   int `json:"media_id" gorm:"not null;uniqueIndex:idx_article_media;index"`
+	Position  int `json:"position" gorm:"not null"`
+}
@@ -0,0 +1,22 @@
+package entity
+
+import "time"
+
+type Notification struct {
+	Id           int       `json:"id" gorm:"primaryKey"`
+	UserId       int       `json:"user_id" gorm:"not null;index"` // the recipient
+	Type         string    `json:"type" gorm:"type:varchar(30);not null"`
+	ActorId      int       `json:"actor_id" gorm:"not null;index"`
+	ArticleId    *int      `json:"article_id" gorm:"index"` // nil for notifications not about an article, e.g. a new follower
+	Read         bool      `json:"read" gorm:"not null;default:false"`
+	CreatedAt    time.Time `json:"created_at"`
+	Actor        User      `json:"actor" gorm:"-"`
+	ArticleTitle string    `json:"article_title,omitempty" gorm:"-"`
+}
+
+// notifications of every type are enabled unless the user disables them
+type NotificationPreference struct {
+	UserId  int    `json:"user_id" gorm:"not null;uniqueIndex:idx_user_type"`
+	Type    string `json:"type" gorm:"type:varchar(30);not null;uniqueIndex:idx_user_type"`
+	Enabled bool   `json:"enabled" gorm:"not null"`
+}
@@ -0,0 +1,43 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"time"
+)
+
+// token for scripts and automation, sent in the Authorization: Bearer header
+type PersonalAccessToken struct {
+	Id         int        `json:"id" gorm:"primaryKey"`
+	UserId     int        `json:"user_id" gorm:"not null;index"`
+	Name       string     `json:"name" gorm:"type:varchar(100);not null"`
+	Prefix     string     `json:"prefix" gorm:"type:varchar(20);not null"`        // start of the token to tell tokens apart
+	TokenHash  string     `json:"-" gorm:"type:varchar(64);uniqueIndex;not null"` // sha256, the token itself is only shown once
+	Scopes     Scopes     `json:"scopes" gorm:"type:text;not null"`
+	ExpiresAt  *time.Time `json:"expires_at"` // nil if the token doesn't expire
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// stored as a space separated list
+type Scopes []string
+
+func (scopes Scopes) Value() (driver.Value, error) {
+	return strings.Join(scopes, " "), nil
+}
+
+func (scopes *Scopes) Scan(value interface{}) error {
+	switch data := value.(type) {
+	case string:
+		*scopes = strings.Fields(data)
+	case []byte:
+		*scopes = strings.Fields(string(data))
+	case nil:
+		*scopes = Scopes{}
+	default:
+		return errors.New("invalid scopes")
+	}
+	return nil
+}
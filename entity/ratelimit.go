@@ -0,0 +1,20 @@
+package entity
+
+import "time"
+
+// Token bucket of one rate limit key, used when rate limits are shared by several instances
+type RateLimitBucket struct {
+	Key       string    `gorm:"type:varchar(255);primaryKey"`
+	Tokens    float64   `gorm:"not null"`
+	UpdatedAt time.Time `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null;index"` // the bucket is full again by then and can be deleted
+}
+
+// Failed attempts of one lockout key, e.g. wrong passwords for an account
+type RateLimitFailures struct {
+	Key         string    `gorm:"type:varchar(255);primaryKey"`
+	Count       int       `gorm:"not null"`
+	LockedUntil time.Time `gorm:"not null"`
+	UpdatedAt   time.Time `gorm:"not null"`
+	ExpiresAt   time.Time `gorm:"not null;index"`
+}
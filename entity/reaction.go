@@ -0,0 +1,13 @@
+package entity
+
+import "time"
+
+// a user can react to an article with each reaction type once
+type Reaction struct {
+	Id        int       `json:"id" gorm:"primaryKey"`
+	UserId    int       `json:"user_id" gorm:"not null;uniqueIndex:idx_user_article_type"`
+	ArticleId int       `json:"article_id" gorm:"not null;uniqueIndex:idx_user_article_type;index"`
+	Type      string    `json:"type" gorm:"type:varchar(30);not null;uniqueIndex:idx_user_article_type"`
+	CreatedAt time.Time `json:"created_at"`
+	User      User      `json:"user" gorm:"-"`
+}
@@ -0,0 +1,25 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/danielblagy/blog-webapp-server/diff"
+)
+
+// version of the article as it was before it was updated
+type ArticleRevision struct {
+	Id        int       `json:"id" gorm:"primaryKey"`
+	ArticleId int       `json:"article_id" gorm:"not null;index"`
+	Title     string    `json:"title" gorm:"type:varchar(300);not null"`
+	Content   string    `json:"content" gorm:"type:text;not null"`
+	Format    string    `json:"format" gorm:"type:varchar(20);not null;default:plain"`
+	Published bool      `json:"published" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"` // when this version was replaced by an update
+}
+
+type RevisionDiff struct {
+	From    int         `json:"from"`
+	To      int         `json:"to"` // 0 if compared to the current version of the article
+	Title   []diff.Line `json:"title"`
+	Content []diff.Line `json:"content"`
+}
@@ -1,6 +1,20 @@
 package entity
 
 type Save struct {
-	UserId    int `json:"user_id" gorm:"not null;uniqueIndex:idx_user_article"`
-	ArticleId int `json:"article_id" gorm:"not null;uniqueIndex:idx_user_article"`
+	UserId    int    `json:"user_id" gorm:"not null;uniqueIndex:idx_user_article"`
+	ArticleId int    `json:"article_id" gorm:"not null;uniqueIndex:idx_user_article"`
+	Note      string `json:"note" gorm:"type:text;not null;default:''"` // personal note, only the user sees it
+	Read      bool   `json:"read" gorm:"not null;default:false"`
+}
+
+type EditableSaveData struct {
+	Note *string `json:"note"` // note is left as is if not provided
+	Read *bool   `json:"read"` // read state is left as is if not provided
+}
+
+// a saved article with the note and the read state of the user who saved it, they are omitted for other users
+type SavedArticle struct {
+	Article
+	Note *string `json:"note,omitempty"`
+	Read *bool   `json:"read,omitempty"`
 }
@@ -0,0 +1,20 @@
+package entity
+
+type ArticleSearchResult struct {
+	Article        Article `json:"article"`
+	Rank           float64 `json:"rank"`
+	TitleHighlight string  `json:"title_highlight"` // title with matches wrapped in <mark> tags, the rest is html-escaped
+	Snippet        string  `json:"snippet"`         // fragments of the content around matches, highlighted the same way
+}
+
+type UserSearchResult struct {
+	User              User    `json:"user"`
+	Rank              float64 `json:"rank"`
+	LoginHighlight    string  `json:"login_highlight"`
+	FullNameHighlight string  `json:"fullname_highlight"`
+}
+
+type SearchResults struct {
+	Articles []ArticleSearchResult `json:"articles"`
+	Users    []UserSearchResult    `json:"users"`
+}
@@ -0,0 +1,45 @@
+package entity
+
+import "time"
+
+// an ordered group of articles of one author, e.g. parts of a tutorial
+type Series struct {
+	Id          int             `json:"id" gorm:"primaryKey"`
+	OwnerId     int             `json:"owner_id" gorm:"not null;index"`
+	Title       string          `json:"title" gorm:"type:varchar(300);not null"`
+	Description string          `json:"description" gorm:"type:text;not null;default:''"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	Owner       User            `json:"owner" gorm:"-"`
+	Articles    []SeriesArticle `json:"articles" gorm:"-"` // in the series order, drafts only for the owner
+}
+
+type EditableSeriesData struct {
+	Title       string  `json:"title"`
+	Description *string `json:"description"` // description is left as is if not provided
+}
+
+// an article can be in one series
+type SeriesEntry struct {
+	SeriesId  int `json:"series_id" gorm:"not null;index"`
+	ArticleId int `json:"article_id" gorm:"not null;uniqueIndex"`
+	Position  int `json:"position" gorm:"not null"`
+}
+
+// an article in a series listing, without the content
+type SeriesArticle struct {
+	Id        int       `json:"id"`
+	Title     string    `json:"title"`
+	Published bool      `json:"published"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// the place of an article in its series, counting only the articles visible to the viewer
+type SeriesNavigation struct {
+	Id       int            `json:"id"`
+	Title    string         `json:"title"`
+	Position int            `json:"position"` // starts from 1
+	Total    int            `json:"total"`
+	Previous *SeriesArticle `json:"previous"`
+	Next     *SeriesArticle `json:"next"`
+}
@@ -0,0 +1,25 @@
+package entity
+
+import "time"
+
+// Signed in device. Every refresh replaces the session's refresh token with a new one,
+// all tokens issued for a session form one family that's revoked as a whole.
+type Session struct {
+	Id         int        `json:"id" gorm:"primaryKey"`
+	UserId     int        `json:"user_id" gorm:"not null;index"`
+	UserAgent  string     `json:"user_agent" gorm:"type:text;not null"`
+	Ip         string     `json:"ip" gorm:"type:varchar(45);not null"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"-"`
+	Current    bool       `json:"current" gorm:"-"` // true for the session the request was made with
+}
+
+type RefreshToken struct {
+	Id        int    `gorm:"primaryKey"`
+	SessionId int    `gorm:"not null;index"`
+	TokenHash string `gorm:"type:varchar(64);uniqueIndex;not null"` // sha256, the token itself is never stored
+	Used      bool   `gorm:"not null;default:false"`
+	CreatedAt time.Time
+}
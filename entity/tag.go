@@ -0,0 +1,17 @@
+package entity
+
+type Tag struct {
+	Id   int    `json:"id" gorm:"primaryKey"`
+	Name string `json:"name" gorm:"type:varchar(50);uniqueIndex;not null"`
+}
+
+type ArticleTag struct {
+	ArticleId int `json:"article_id" gorm:"not null;uniqueIndex:idx_article_tag"`
+	TagId     int `json:"tag_id" gorm:"not null;uniqueIndex:idx_article_tag"`
+}
+
+// tag name with the number of published articles it's assigned to
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
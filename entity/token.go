@@ -0,0 +1,16 @@
+package entity
+
+import "time"
+
+// Record of an issued email verification, password reset or two factor challenge token, makes the token single-use.
+// The token itself is a signed JWT, only its id is stored.
+type AccountToken struct {
+	Id        int       `gorm:"primaryKey"`
+	TokenId   string    `gorm:"type:varchar(64);uniqueIndex;not null"`
+	UserId    int       `gorm:"not null;index"`
+	Purpose   string    `gorm:"type:varchar(20);not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	Attempts  int `gorm:"not null;default:0"` // attempts to complete a two factor challenge
+	CreatedAt time.Time
+}
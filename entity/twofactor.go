@@ -0,0 +1,21 @@
+package entity
+
+import "time"
+
+// TOTP settings of the user, the secret is pending until it's confirmed with a code
+type TwoFactor struct {
+	UserId      int    `gorm:"primaryKey;autoIncrement:false"`
+	Secret      string `gorm:"type:varchar(64);not null"`
+	Enabled     bool   `gorm:"not null;default:false"`
+	LastCounter int64  `gorm:"not null;default:0"` // counter of the last accepted code, codes can't be reused
+	EnabledAt   *time.Time
+	CreatedAt   time.Time
+}
+
+// single-use code that can be used instead of a TOTP code, e.g. when the phone is lost
+type RecoveryCode struct {
+	Id       int    `gorm:"primaryKey"`
+	UserId   int    `gorm:"not null;index"`
+	CodeHash string `gorm:"type:varchar(64);not null"` // sha256
+	UsedAt   *time.Time
+}
@@ -3,13 +3,25 @@ package entity
 import "encoding/json"
 
 type User struct {
-	Id        int       `json:"id" gorm:"primaryKey"`
-	Login     string    `json:"login" gorm:"type:varchar(100);uniqueIndex;not null"`
-	FullName  string    `json:"fullname" gorm:"type:varchar(300);not null"`
-	Password  string    `json:"password,omitempty" gorm:"type:text;not null"`
-	Articles  []Article `json:"articles" gorm:"foreignKey:AuthorId"`
-	Followers int       `json:"followers" gorm:"-"`
-	Following int       `json:"following" gorm:"-"`
+	Id            int       `json:"id" gorm:"primaryKey"`
+	Login         string    `json:"login" gorm:"type:varchar(100);uniqueIndex;not null"`
+	FullName      string    `json:"fullname" gorm:"type:varchar(300);not null"`
+	Password      string    `json:"password,omitempty" gorm:"type:text;not null"`
+	Email         *string   `json:"email,omitempty" gorm:"type:varchar(320);uniqueIndex"` // lowercased, null for users signed up before emails were required
+	EmailVerified bool      `json:"email_verified" gorm:"not null;default:false"`
+	Role          string    `json:"role" gorm:"type:varchar(20);not null;default:user"`
+	Suspended     bool      `json:"suspended" gorm:"not null;default:false"`
+	Bio           string    `json:"bio" gorm:"type:varchar(500);not null;default:''"`
+	Website       string    `json:"website" gorm:"type:varchar(300);not null;default:''"`
+	Links         []string  `json:"links" gorm:"serializer:json;type:text;not null;default:'[]'"` // social profile urls
+	Location      string    `json:"location" gorm:"type:varchar(100);not null;default:''"`
+	Avatar        ImageSet  `json:"avatar" gorm:"type:text"` // null if the user has no avatar
+	Header        ImageSet  `json:"header" gorm:"type:text"` // null if the user has no header image
+	Articles      []Article `json:"articles" gorm:"foreignKey:AuthorId"`
+	Followers     int       `json:"followers" gorm:"-"`
+	Following     int       `json:"following" gorm:"-"`
+	Self          bool      `json:"-" gorm:"-"` // set when the data is sent to the user themselves, the email is only shown then
+	AdminView     bool      `json:"-" gorm:"-"` // set when the data is sent to an admin, role and suspended are only shown then and to the user themselves
 }
 
 // remove sensitive imformation from user data in server responses
@@ -17,10 +29,50 @@ func (u User) MarshalJSON() ([]byte, error) {
 	type user User // prevent recursion
 	x := user(u)
 	x.Password = "" // we set omitempty in User type, and here we make password empty, so the password propery will be ommited
+	if !x.Self {
+		x.Email = nil
+	}
+
+	if !x.Self && !x.AdminView {
+		// the outer fields hide the embedded ones, and are omitted being empty
+		return json.Marshal(struct {
+			user
+			Role      string `json:"role,omitempty"`
+			Suspended bool   `json:"suspended,omitempty"`
+		}{user: x})
+	}
 	return json.Marshal(x)
 }
 
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// each role has all the permissions of the roles before it
+var roleLevels = map[string]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+func IsValidRole(role string) bool {
+	_, ok := roleLevels[role]
+	return ok
+}
+
+// true if role has at least the permissions of the required role
+func HasRole(role string, required string) bool {
+	return IsValidRole(role) && roleLevels[role] >= roleLevels[required]
+}
+
 type EditableUserData struct {
-	FullName string `json:"fullname"`
-	Password string `json:"password"`
+	FullName string   `json:"fullname"`
+	Password string   `json:"password"`
+	Email    string   `json:"email"` // changing the email makes it unverified
+	Bio      *string  `json:"bio"`   // profile fields are left as is if not provided, empty values clear them
+	Website  *string  `json:"website"`
+	Links    []string `json:"links"`
+	Location *string  `json:"location"`
 }
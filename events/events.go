@@ -0,0 +1,57 @@
+package events
+
+import (
+	"log"
+	"sync"
+)
+
+// event types, notification types use the same names
+const (
+	TypeFollow  = "follow"  // ActorId started following UserId
+	TypeSave    = "save"    // ActorId saved ArticleId
+	TypePublish = "publish" // ActorId published ArticleId
+)
+
+var Types = []string{TypeFollow, TypeSave, TypePublish}
+
+type Event struct {
+	Type      string
+	ActorId   int // the user who caused the event
+	UserId    int // the user the event is about, 0 if there's none
+	ArticleId int // the article the event is about, 0 if there's none
+}
+
+type Handler func(event Event) error
+
+// In-process event bus, services publish to it and don't need to know who handles the events.
+// Handlers run in their own goroutines, so publishing never blocks a request,
+// their errors are logged.
+type Bus struct {
+	mutex    sync.RWMutex
+	handlers map[string][]Handler
+}
+
+func CreateBus() *Bus {
+	return &Bus{handlers: map[string][]Handler{}}
+}
+
+func (bus *Bus) Subscribe(eventType string, handler Handler) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	bus.handlers[eventType] = append(bus.handlers[eventType], handler)
+}
+
+func (bus *Bus) Publish(event Event) {
+	bus.mutex.RLock()
+	handlers := bus.handlers[event.Type]
+	bus.mutex.RUnlock()
+
+	for _, handler := range handlers {
+		go func(handler Handler) {
+			if err := handler(event); err != nil {
+				log.Printf("Failed to handle '%s' event: %s", event.Type, err.Error())
+			}
+		}(handler)
+	}
+}
@@ -0,0 +1,303 @@
+package feed
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"time"
+)
+
+const (
+	RSS  = "rss"
+	Atom = "atom"
+	JSON = "json"
+)
+
+var contentTypes = map[string]string{
+	RSS:  "application/rss+xml; charset=utf-8",
+	Atom: "application/atom+xml; charset=utf-8",
+	JSON: "application/feed+json; charset=utf-8",
+}
+
+type Author struct {
+	Name string
+	Link string
+}
+
+type Item struct {
+	Id        string // globally unique and permanent, usually the link
+	Title     string
+	Link      string
+	Content   string // plain text content
+	Html      string // content rendered to html, takes precedence over Content if not empty
+	Author    Author
+	Tags      []string
+	Published time.Time
+	Updated   time.Time
+}
+
+type Feed struct {
+	Title       string
+	Description string
+	Link        string // html page the feed is about
+	FeedLink    string // the feed itself
+	Items       []Item
+}
+
+// the time the newest item was updated at, zero if there are no items
+func (feed Feed) Updated() time.Time {
+	updated := time.Time{}
+	for _, item := range feed.Items {
+		if item.Updated.After(updated) {
+			updated = item.Updated
+		}
+	}
+	return updated
+}
+
+func ContentType(format string) string {
+	return contentTypes[format]
+}
+
+// Renders the feed in one of the formats: RSS, Atom, JSON (JSON Feed)
+func (feed Feed) Render(format string) ([]byte, error) {
+	switch format {
+	case RSS:
+		return feed.rss()
+	case Atom:
+		return feed.atom()
+	case JSON:
+		return feed.json()
+	}
+	return nil, errors.New("unknown feed format")
+}
+
+func (item Item) description() string {
+	if item.Html != "" {
+		return item.Html
+	}
+	return item.Content
+}
+
+// RSS 2.0
+
+type rssDocument struct {
+	XMLName   xml.Name   `xml:"rss"`
+	Version   string     `xml:"version,attr"`
+	AtomXmlns string     `xml:"xmlns:atom,attr"`
+	DcXmlns   string     `xml:"xmlns:dc,attr"`
+	Channel   rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string      `xml:"title"`
+	Link          string      `xml:"link"`
+	Description   string      `xml:"description"`
+	AtomLink      rssAtomLink `xml:"atom:link"`
+	LastBuildDate string      `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem   `xml:"item"`
+}
+
+type rssAtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Guid        rssGuid  `xml:"guid"`
+	Creator     string   `xml:"dc:creator"`
+	PubDate     string   `xml:"pubDate"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+}
+
+type rssGuid struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+func (feed Feed) rss() ([]byte, error) {
+	channel := rssChannel{
+		Title:       feed.Title,
+		Link:        feed.Link,
+		Description: feed.Description,
+		AtomLink:    rssAtomLink{Href: feed.FeedLink, Rel: "self", Type: "application/rss+xml"},
+		Items:       []rssItem{},
+	}
+
+	if updated := feed.Updated(); !updated.IsZero() {
+		channel.LastBuildDate = updated.Format(time.RFC1123Z)
+	}
+
+	for _, item := range feed.Items {
+		channel.Items = append(channel.Items, rssItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			Guid:        rssGuid{IsPermaLink: item.Id == item.Link, Value: item.Id},
+			Creator:     item.Author.Name,
+			PubDate:     item.Published.Format(time.RFC1123Z),
+			Categories:  item.Tags,
+			Description: item.description(),
+		})
+	}
+
+	return marshalXML(rssDocument{
+		Version:   "2.0",
+		AtomXmlns: "http://www.w3.org/2005/Atom",
+		DcXmlns:   "http://purl.org/dc/elements/1.1/",
+		Channel:   channel,
+	})
+}
+
+// Atom 1.0
+
+type atomDocument struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	Id      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	Id         string         `xml:"id"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     atomAuthor     `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomContent    `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+	Uri  string `xml:"uri,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+func (feed Feed) atom() ([]byte, error) {
+	updated := feed.Updated()
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+
+	document := atomDocument{
+		Title:   feed.Title,
+		Id:      feed.FeedLink,
+		Updated: updated.Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: feed.Link, Rel: "alternate", Type: "text/html"},
+			{Href: feed.FeedLink, Rel: "self", Type: "application/atom+xml"},
+		},
+		Entries: []atomEntry{},
+	}
+
+	for _, item := range feed.Items {
+		categories := []atomCategory{}
+		for _, tag := range item.Tags {
+			categories = append(categories, atomCategory{Term: tag})
+		}
+
+		document.Entries = append(document.Entries, atomEntry{
+			Title:      item.Title,
+			Id:         item.Id,
+			Link:       atomLink{Href: item.Link, Rel: "alternate"},
+			Published:  item.Published.Format(time.RFC3339),
+			Updated:    item.Updated.Format(time.RFC3339),
+			Author:     atomAuthor{Name: item.Author.Name, Uri: item.Author.Link},
+			Categories: categories,
+			Content:    item.atomContent(),
+		})
+	}
+
+	return marshalXML(document)
+}
+
+func (item Item) atomContent() atomContent {
+	if item.Html != "" {
+		return atomContent{Type: "html", Value: item.Html}
+	}
+	return atomContent{Type: "text", Value: item.Content}
+}
+
+func marshalXML(document interface{}) ([]byte, error) {
+	data, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// JSON Feed 1.1
+
+type jsonDocument struct {
+	Version     string     `json:"version"`
+	Title       string     `json:"title"`
+	HomePageUrl string     `json:"home_page_url"`
+	FeedUrl     string     `json:"feed_url"`
+	Description string     `json:"description,omitempty"`
+	Items       []jsonItem `json:"items"`
+}
+
+type jsonItem struct {
+	Id            string       `json:"id"`
+	Url           string       `json:"url"`
+	Title         string       `json:"title"`
+	ContentHtml   string       `json:"content_html,omitempty"`
+	ContentText   string       `json:"content_text,omitempty"`
+	DatePublished string       `json:"date_published"`
+	DateModified  string       `json:"date_modified"`
+	Authors       []jsonAuthor `json:"authors"`
+	Tags          []string     `json:"tags,omitempty"`
+}
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+	Url  string `json:"url,omitempty"`
+}
+
+func (feed Feed) json() ([]byte, error) {
+	document := jsonDocument{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       feed.Title,
+		HomePageUrl: feed.Link,
+		FeedUrl:     feed.FeedLink,
+		Description: feed.Description,
+		Items:       []jsonItem{},
+	}
+
+	for _, item := range feed.Items {
+		document.Items = append(document.Items, jsonItem{
+			Id:            item.Id,
+			Url:           item.Link,
+			Title:         item.Title,
+			ContentHtml:   item.Html,
+			ContentText:   item.Content,
+			DatePublished: item.Published.Format(time.RFC3339),
+			DateModified:  item.Updated.Format(time.RFC3339),
+			Authors:       []jsonAuthor{{Name: item.Author.Name, Url: item.Author.Link}},
+			Tags:          item.Tags,
+		})
+	}
+
+	return json.MarshalIndent(document, "", "  ")
+}
@@ -0,0 +1,109 @@
+package imaging
+
+import (
+	"image"
+	"math"
+	"strings"
+)
+
+// components of the blurhash, more horizontal ones since most images are landscape
+const (
+	blurhashX = 4
+	blurhashY = 3
+)
+
+const base83Characters = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// Encodes a small blurred placeholder of the image that clients can show while it loads.
+// The image is scaled down first, the hash doesn't need more detail.
+// https://github.com/woltapp/blurhash/blob/master/Algorithm.md
+func Blurhash(img *image.RGBA) string {
+	small := Fit(img, 64, 64)
+	bounds := small.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	factors := make([][3]float64, 0, blurhashX*blurhashY)
+	for j := 0; j < blurhashY; j++ {
+		for i := 0; i < blurhashX; i++ {
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1
+			}
+
+			var factor [3]float64
+			for y := 0; y < height; y++ {
+				for x := 0; x < width; x++ {
+					basis := math.Cos(math.Pi*float64(i)*float64(x)/float64(width)) *
+						math.Cos(math.Pi*float64(j)*float64(y)/float64(height))
+					pixel := small.Pix[small.PixOffset(x, y):]
+					for channel := 0; channel < 3; channel++ {
+						factor[channel] += basis * srgbToLinear(pixel[channel])
+					}
+				}
+			}
+
+			for channel := range factor {
+				factor[channel] *= normalisation / float64(width*height)
+			}
+			factors = append(factors, factor)
+		}
+	}
+
+	var hash strings.Builder
+	hash.WriteString(base83((blurhashX-1)+(blurhashY-1)*9, 1))
+
+	dc, ac := factors[0], factors[1:]
+
+	maximum := 0.0
+	for _, factor := range ac {
+		for _, value := range factor {
+			maximum = math.Max(maximum, math.Abs(value))
+		}
+	}
+
+	quantisedMaximum := int(math.Max(0, math.Min(82, math.Floor(maximum*166-0.5))))
+	maximum = float64(quantisedMaximum+1) / 166
+	hash.WriteString(base83(quantisedMaximum, 1))
+
+	hash.WriteString(base83(linearToSrgb(dc[0])<<16+linearToSrgb(dc[1])<<8+linearToSrgb(dc[2]), 4))
+
+	for _, factor := range ac {
+		value := 0
+		for _, component := range factor {
+			quantised := int(math.Max(0, math.Min(18, math.Floor(signPow(component/maximum, 0.5)*9+9.5))))
+			value = value*19 + quantised
+		}
+		hash.WriteString(base83(value, 2))
+	}
+
+	return hash.String()
+}
+
+func base83(value int, length int) string {
+	encoded := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		encoded[i] = base83Characters[value%83]
+		value /= 83
+	}
+	return string(encoded)
+}
+
+func srgbToLinear(value uint8) float64 {
+	v := float64(value) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSrgb(value float64) int {
+	v := math.Max(0, math.Min(1, value))
+	if v <= 0.0031308 {
+		return int(math.Round(v * 12.92 * 255))
+	}
+	return int(math.Round((1.055*math.Pow(v, 1/2.4) - 0.055) * 255))
+}
+
+func signPow(value float64, exponent float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(value), exponent), value)
+}
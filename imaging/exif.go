@@ -0,0 +1,124 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+)
+
+const orientationTag = 0x0112
+
+var exifHeader = []byte("Exif\x00\x00")
+
+// Reads the EXIF orientation (1 to 8) of a JPEG, 1 (as stored) if it's missing or invalid.
+// https://www.cipa.jp/std/documents/e/DC-008-2012_E.pdf
+func Orientation(data []byte) int {
+	orientation := 1
+
+	walkJPEG(data, func(marker byte, segment []byte) bool {
+		if marker != 0xE1 || !bytes.HasPrefix(segment, exifHeader) {
+			return true
+		}
+
+		if value := tiffOrientation(segment[len(exifHeader):]); value >= 1 && value <= 8 {
+			orientation = value
+		}
+		return false
+	})
+
+	return orientation
+}
+
+// looks for the orientation tag in the first IFD of TIFF data
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	offset := int(order.Uint32(tiff[4:8]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 0
+	}
+
+	count := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+
+		// a SHORT value is stored in the first 2 bytes of the value field
+		if order.Uint16(tiff[entry:]) == orientationTag && order.Uint16(tiff[entry+2:]) == 3 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+
+	return 0
+}
+
+// an EXIF segment body with only the orientation tag
+func orientationExif(orientation int) []byte {
+	segment := append([]byte{}, exifHeader...)
+	segment = append(segment, 'M', 'M', 0, 42, 0, 0, 0, 8)
+	segment = append(segment, 0, 1)
+	segment = append(segment, orientationTag>>8, orientationTag&0xFF, 0, 3, 0, 0, 0, 1, 0, byte(orientation), 0, 0)
+	return append(segment, 0, 0, 0, 0)
+}
+
+// Rotates and flips the image so it's displayed upright without the orientation tag.
+// Orientations 5 to 8 swap the width and the height.
+func orient(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	dstWidth, dstHeight := width, height
+	if orientation >= 5 {
+		dstWidth, dstHeight = height, width
+	}
+
+	// the source pixel of each destination pixel
+	source := func(x int, y int) (int, int) {
+		switch orientation {
+		case 2:
+			return width - 1 - x, y
+		case 3:
+			return width - 1 - x, height - 1 - y
+		case 4:
+			return x, height - 1 - y
+		case 5:
+			return y, x
+		case 6:
+			return y, height - 1 - x
+		case 7:
+			return width - 1 - y, height - 1 - x
+		default:
+			return width - 1 - y, x
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for y := 0; y < dstHeight; y++ {
+		for x := 0; x < dstWidth; x++ {
+			srcX, srcY := source(x, y)
+			from := src.PixOffset(bounds.Min.X+srcX, bounds.Min.Y+srcY)
+			to := dst.PixOffset(x, y)
+			copy(dst.Pix[to:to+4], src.Pix[from:from+4])
+		}
+	}
+
+	return dst
+}
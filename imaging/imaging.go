@@ -0,0 +1,240 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"io/ioutil"
+	"math"
+
+	// formats accepted by Decode, WebP is registered in webp_cgo.go or webp_nocgo.go
+	_ "image/gif"
+	_ "image/png"
+)
+
+// larger images are rejected before they are decoded, so a small file can't take up gigabytes of memory
+const MaxPixels = 50 * 1000 * 1000
+
+const (
+	jpegQuality = 85
+	webpQuality = 80
+)
+
+// formats as reported by image.Decode
+var supportedFormats = map[string]bool{"jpeg": true, "png": true, "gif": true, "webp": true}
+
+// Reads the header first to check the format and the dimensions. The image is rotated
+// according to its EXIF orientation, and transparent areas become white,
+// since the results are meant to be encoded as JPEG.
+func Decode(reader io.Reader) (*image.RGBA, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := checkConfig(data)
+	if err != nil {
+		return nil, err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("invalid image")
+	}
+
+	flat := flatten(img)
+	if format == "jpeg" {
+		flat = orient(flat, Orientation(data))
+	}
+
+	return flat, nil
+}
+
+// Reads the width and the height from the header as the image is displayed, i.e. after its EXIF
+// orientation is applied.
+func Dimensions(data []byte) (int, int, error) {
+	if isWebP(data) {
+		width, height, err := webpDimensions(data)
+		if err == nil && width*height > MaxPixels {
+			return 0, 0, errors.New("image dimensions are too large")
+		}
+		return width, height, err
+	}
+
+	if _, err := checkConfig(data); err != nil {
+		return 0, 0, err
+	}
+
+	config, _, _ := image.DecodeConfig(bytes.NewReader(data))
+	if Orientation(data) >= 5 {
+		return config.Height, config.Width, nil
+	}
+	return config.Width, config.Height, nil
+}
+
+// returns the format if it's supported and the image isn't too large to decode
+func checkConfig(data []byte) (string, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || !supportedFormats[format] {
+		return "", errors.New("unsupported image format")
+	}
+
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > MaxPixels {
+		return "", errors.New("image dimensions are too large")
+	}
+
+	return format, nil
+}
+
+// Scales the image to cover width x height and crops the center, e.g. for square avatars
+func Fill(img *image.RGBA, width int, height int) *image.RGBA {
+	bounds := img.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+
+	// the largest centered area with the target aspect ratio
+	cropWidth, cropHeight := srcWidth, srcWidth*height/width
+	if cropHeight > srcHeight {
+		cropWidth, cropHeight = srcHeight*width/height, srcHeight
+	}
+	if cropWidth < 1 {
+		cropWidth = 1
+	}
+	if cropHeight < 1 {
+		cropHeight = 1
+	}
+
+	x0 := bounds.Min.X + (srcWidth-cropWidth)/2
+	y0 := bounds.Min.Y + (srcHeight-cropHeight)/2
+	crop := img.SubImage(image.Rect(x0, y0, x0+cropWidth, y0+cropHeight)).(*image.RGBA)
+
+	return resize(crop, width, height)
+}
+
+// Scales the image down to fit in maxWidth x maxHeight keeping the aspect ratio,
+// smaller images keep their size
+func Fit(img *image.RGBA, maxWidth int, maxHeight int) *image.RGBA {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	if width > maxWidth {
+		width, height = maxWidth, height*maxWidth/width
+	}
+	if height > maxHeight {
+		width, height = width*maxHeight/height, maxHeight
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+
+	return resize(img, width, height)
+}
+
+func EncodeJPEG(writer io.Writer, img image.Image) error {
+	return jpeg.Encode(writer, img, &jpeg.Options{Quality: jpegQuality})
+}
+
+// draws the image onto a white background
+func flatten(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	flat := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, bounds.Min, draw.Over)
+	return flat
+}
+
+type contribution struct {
+	index  int
+	weight float32
+}
+
+// Source pixels covered by each destination pixel with the covered fraction as the weight.
+// Averaging the covered area (a box filter) keeps downscaled photos smooth.
+func contributions(srcSize int, dstSize int) [][]contribution {
+	scale := float64(srcSize) / float64(dstSize)
+	result := make([][]contribution, dstSize)
+
+	for i := range result {
+		start := float64(i) * scale
+		end := start + scale
+
+		total := 0.0
+		weights := []float64{}
+		for j := int(start); j < srcSize && float64(j) < end; j++ {
+			weight := math.Min(end, float64(j+1)) - math.Max(start, float64(j))
+			if weight > 0 {
+				result[i] = append(result[i], contribution{index: j})
+				weights = append(weights, weight)
+				total += weight
+			}
+		}
+
+		for k := range result[i] {
+			result[i][k].weight = float32(weights[k] / total)
+		}
+	}
+
+	return result
+}
+
+// Resizes each source row horizontally when it's first needed, then combines the rows vertically.
+// Only the rows used by the current destination row are kept, so photos of tens of megapixels
+// don't need a full size intermediate image.
+func resize(src *image.RGBA, width int, height int) *image.RGBA {
+	bounds := src.Bounds()
+
+	columns := contributions(bounds.Dx(), width)
+	rows := contributions(bounds.Dy(), height)
+
+	resizedRows := map[int][]float32{}
+	resizedRow := func(y int) []float32 {
+		if row, ok := resizedRows[y]; ok {
+			return row
+		}
+
+		row := make([]float32, width*4)
+		line := src.Pix[src.PixOffset(bounds.Min.X, bounds.Min.Y+y):]
+		for x, weights := range columns {
+			out := row[x*4 : x*4+4]
+			for _, c := range weights {
+				in := line[c.index*4 : c.index*4+4]
+				for channel := 0; channel < 4; channel++ {
+					out[channel] += float32(in[channel]) * c.weight
+				}
+			}
+		}
+
+		resizedRows[y] = row
+		return row
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y, weights := range rows {
+		for cached := range resizedRows {
+			if cached < weights[0].index {
+				delete(resizedRows, cached)
+			}
+		}
+
+		out := dst.Pix[y*dst.Stride : y*dst.Stride+width*4]
+		sum := make([]float32, width*4)
+		for _, c := range weights {
+			row := resizedRow(c.index)
+			for i := range sum {
+				sum[i] += row[i] * c.weight
+			}
+		}
+
+		for i := range out {
+			out[i] = uint8(math.Min(255, math.Max(0, math.Round(float64(sum[i])))))
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,197 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	pngSignature = []byte("\x89PNG\r\n\x1a\n")
+	iccHeader    = []byte("ICC_PROFILE\x00")
+)
+
+// PNG chunks that may carry camera data, locations, dates or comments
+var pngMetadataChunks = map[string]bool{"eXIf": true, "tEXt": true, "zTXt": true, "iTXt": true, "tIME": true}
+
+// WebP chunks with EXIF and XMP metadata, and the VP8X flags announcing them
+var (
+	webpMetadataChunks = map[string]bool{"EXIF": true, "XMP ": true}
+	webpMetadataFlags  = byte(0x08 | 0x04)
+)
+
+// Removes EXIF (including GPS), XMP and comments from an image without re-encoding it.
+// A JPEG keeps its orientation, so it's still displayed upright, and its color profile.
+// GIFs are returned as they are.
+func StripMetadata(data []byte, contentType string) ([]byte, error) {
+	switch contentType {
+	case "image/jpeg":
+		return stripJPEG(data)
+	case "image/png":
+		return stripPNG(data)
+	case "image/webp":
+		return stripWebP(data)
+	default:
+		return data, nil
+	}
+}
+
+// Calls visit with each marker and segment body (without the length) before the image data,
+// stops early if visit returns false. Returns the offset of the start of scan marker.
+func walkJPEG(data []byte, visit func(marker byte, segment []byte) bool) (int, error) {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 0, errors.New("invalid image")
+	}
+
+	offset := 2
+	for {
+		if offset >= len(data) || data[offset] != 0xFF {
+			return 0, errors.New("invalid image")
+		}
+
+		// markers may be padded with any number of 0xFF bytes
+		start := offset
+		for offset < len(data) && data[offset] == 0xFF {
+			offset++
+		}
+		if offset >= len(data) {
+			return 0, errors.New("invalid image")
+		}
+
+		marker := data[offset]
+		offset++
+
+		if marker == 0xDA {
+			return start, nil
+		}
+
+		// standalone markers have no length
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			continue
+		}
+		if marker == 0xD9 || offset+2 > len(data) {
+			return 0, errors.New("invalid image")
+		}
+
+		length := int(binary.BigEndian.Uint16(data[offset:]))
+		if length < 2 || offset+length > len(data) {
+			return 0, errors.New("invalid image")
+		}
+
+		if !visit(marker, data[offset+2:offset+length]) {
+			return start, nil
+		}
+		offset += length
+	}
+}
+
+func stripJPEG(data []byte) ([]byte, error) {
+	orientation := Orientation(data)
+
+	var stripped bytes.Buffer
+	stripped.Write(data[:2])
+
+	if orientation != 1 {
+		writeJPEGSegment(&stripped, 0xE1, orientationExif(orientation))
+	}
+
+	scan, err := walkJPEG(data, func(marker byte, segment []byte) bool {
+		// APP0 is JFIF, APP14 has the Adobe color transform, APP2 may be a color profile,
+		// the other application segments and comments are metadata
+		isApp := marker >= 0xE1 && marker <= 0xEF && marker != 0xEE
+		isProfile := marker == 0xE2 && bytes.HasPrefix(segment, iccHeader)
+		if (isApp && !isProfile) || marker == 0xFE {
+			return true
+		}
+
+		writeJPEGSegment(&stripped, marker, segment)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stripped.Write(data[scan:])
+	return stripped.Bytes(), nil
+}
+
+func writeJPEGSegment(buffer *bytes.Buffer, marker byte, segment []byte) {
+	buffer.Write([]byte{0xFF, marker})
+	binary.Write(buffer, binary.BigEndian, uint16(len(segment)+2))
+	buffer.Write(segment)
+}
+
+// chunks are length, type, data and CRC, the file ends with IEND
+func stripPNG(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, errors.New("invalid image")
+	}
+
+	var stripped bytes.Buffer
+	stripped.Write(pngSignature)
+
+	offset := len(pngSignature)
+	for {
+		if offset+12 > len(data) {
+			return nil, errors.New("invalid image")
+		}
+
+		length := int(binary.BigEndian.Uint32(data[offset:]))
+		chunkType := string(data[offset+4 : offset+8])
+		end := offset + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, errors.New("invalid image")
+		}
+
+		if !pngMetadataChunks[chunkType] {
+			stripped.Write(data[offset:end])
+		}
+		offset = end
+
+		if chunkType == "IEND" {
+			return stripped.Bytes(), nil
+		}
+	}
+}
+
+// a RIFF container of chunks padded to an even size
+// https://developers.google.com/speed/webp/docs/riff_container
+func stripWebP(data []byte) ([]byte, error) {
+	if !isWebP(data) {
+		return nil, errors.New("invalid image")
+	}
+
+	riffEnd := 8 + int(binary.LittleEndian.Uint32(data[4:8]))
+	if riffEnd > len(data) {
+		return nil, errors.New("invalid image")
+	}
+
+	var stripped bytes.Buffer
+	stripped.Write(data[:12])
+
+	offset := 12
+	for offset+8 <= riffEnd {
+		fourCC := string(data[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(data[offset+4:]))
+		end := offset + 8 + size + size%2
+		if size < 0 || offset+8+size > riffEnd {
+			return nil, errors.New("invalid image")
+		}
+		if end > riffEnd {
+			end = riffEnd
+		}
+
+		chunk := append([]byte{}, data[offset:end]...)
+		if fourCC == "VP8X" && size > 0 {
+			chunk[8] &^= webpMetadataFlags
+		}
+		if !webpMetadataChunks[fourCC] {
+			stripped.Write(chunk)
+		}
+		offset = end
+	}
+
+	result := stripped.Bytes()
+	binary.LittleEndian.PutUint32(result[4:8], uint32(len(result)-8))
+	return result, nil
+}
@@ -0,0 +1,47 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+func isWebP(data []byte) bool {
+	return len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP"
+}
+
+// Reads the canvas size from the first chunk: VP8X for extended files, otherwise the header
+// of the lossy (VP8) or lossless (VP8L) bitstream.
+// https://developers.google.com/speed/webp/docs/riff_container
+func webpDimensions(data []byte) (int, int, error) {
+	if len(data) < 20 {
+		return 0, 0, errors.New("invalid image")
+	}
+
+	chunk := data[20:]
+	switch string(data[12:16]) {
+	case "VP8X":
+		if len(chunk) < 10 {
+			return 0, 0, errors.New("invalid image")
+		}
+		width := int(chunk[4]) | int(chunk[5])<<8 | int(chunk[6])<<16
+		height := int(chunk[7]) | int(chunk[8])<<8 | int(chunk[9])<<16
+		return width + 1, height + 1, nil
+	case "VP8 ":
+		// a 3 byte frame tag and a start code before the 14 bit sizes
+		if len(chunk) < 10 || chunk[3] != 0x9d || chunk[4] != 0x01 || chunk[5] != 0x2a {
+			return 0, 0, errors.New("invalid image")
+		}
+		width := int(binary.LittleEndian.Uint16(chunk[6:]) & 0x3fff)
+		height := int(binary.LittleEndian.Uint16(chunk[8:]) & 0x3fff)
+		return width, height, nil
+	case "VP8L":
+		// a signature byte before the 14 bit sizes minus one
+		if len(chunk) < 5 || chunk[0] != 0x2f {
+			return 0, 0, errors.New("invalid image")
+		}
+		bits := binary.LittleEndian.Uint32(chunk[1:])
+		return int(bits&0x3fff) + 1, int(bits>>14&0x3fff) + 1, nil
+	default:
+		return 0, 0, errors.New("invalid image")
+	}
+}
@@ -0,0 +1,20 @@
+//go:build cgo
+// +build cgo
+
+package imaging
+
+import (
+	"image"
+	"io"
+
+	// also registers WebP for Decode
+	"github.com/chai2010/webp"
+)
+
+// WebP is decoded and encoded with libwebp, which needs cgo
+const CanEncodeWebP = true
+
+// lossy, the images are already flattened, so there's no alpha channel to keep
+func EncodeWebP(writer io.Writer, img image.Image) error {
+	return webp.Encode(writer, img, &webp.Options{Quality: webpQuality})
+}
@@ -0,0 +1,19 @@
+//go:build !cgo
+// +build !cgo
+
+package imaging
+
+import (
+	"errors"
+	"image"
+	"io"
+
+	// builds without cgo decode WebP with the pure Go decoder, and can't encode it
+	_ "golang.org/x/image/webp"
+)
+
+const CanEncodeWebP = false
+
+func EncodeWebP(writer io.Writer, img image.Image) error {
+	return errors.New("WebP encoding needs a build with cgo")
+}
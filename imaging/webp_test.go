@@ -0,0 +1,84 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"testing"
+)
+
+// RIFF container with a single chunk
+func webpFile(fourCC string, payload []byte) []byte {
+	chunk := make([]byte, 8, 8+len(payload))
+	copy(chunk, fourCC)
+	binary.LittleEndian.PutUint32(chunk[4:], uint32(len(payload)))
+	chunk = append(chunk, payload...)
+
+	file := make([]byte, 12, 12+len(chunk))
+	copy(file, "RIFF")
+	binary.LittleEndian.PutUint32(file[4:], uint32(4+len(chunk)))
+	copy(file[8:], "WEBP")
+	return append(file, chunk...)
+}
+
+func TestWebpDimensions(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		width  int
+		height int
+		valid  bool
+	}{
+		{
+			"extended", webpFile("VP8X", []byte{0x10, 0, 0, 0, 0x8f, 0x01, 0x00, 0x2b, 0x01, 0x00}),
+			400, 300, true,
+		},
+		{
+			// the top 2 bits of the sizes are the scale
+			"lossy", webpFile("VP8 ", []byte{0, 0, 0, 0x9d, 0x01, 0x2a, 0x80, 0x42, 0xe0, 0x01}),
+			640, 480, true,
+		},
+		{
+			// 14 bit width - 1, 14 bit height - 1, then the alpha bit
+			"lossless", webpFile("VP8L", []byte{0x2f, 0x1f, 0xc0, 0x07, 0x10}),
+			32, 32, true,
+		},
+		{"lossy without the start code", webpFile("VP8 ", []byte{0, 0, 0, 0, 0, 0, 0x80, 0x02, 0xe0, 0x01}), 0, 0, false},
+		{"lossless without the signature", webpFile("VP8L", []byte{0, 0x1f, 0xc0, 0x07, 0x10}), 0, 0, false},
+		{"truncated chunk", webpFile("VP8X", []byte{0, 0, 0, 0}), 0, 0, false},
+		{"unknown chunk", webpFile("ALPH", []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0}), 0, 0, false},
+		{"too short", []byte("RIFF\x00\x00\x00\x00WEBP"), 0, 0, false},
+	}
+
+	for _, test := range tests {
+		width, height, err := webpDimensions(test.data)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: error = %v, want valid = %t", test.name, err, test.valid)
+			continue
+		}
+		if width != test.width || height != test.height {
+			t.Errorf("%s: webpDimensions() = %dx%d, want %dx%d", test.name, width, height, test.width, test.height)
+		}
+	}
+}
+
+// the sizes are read the same way from files the encoder makes
+func TestWebpDimensionsEncoded(t *testing.T) {
+	if !CanEncodeWebP {
+		t.Skip("WebP encoding needs a build with cgo")
+	}
+
+	var buffer bytes.Buffer
+	if err := EncodeWebP(&buffer, image.NewRGBA(image.Rect(0, 0, 123, 45))); err != nil {
+		t.Fatalf("EncodeWebP() returned an error: %s", err)
+	}
+
+	if !isWebP(buffer.Bytes()) {
+		t.Fatalf("EncodeWebP() didn't make a WebP file")
+	}
+
+	width, height, err := webpDimensions(buffer.Bytes())
+	if err != nil || width != 123 || height != 45 {
+		t.Errorf("webpDimensions() = %dx%d, %v, want 123x45", width, height, err)
+	}
+}
@@ -0,0 +1,38 @@
+package mail
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"time"
+)
+
+// Doesn't deliver mail, used for local development. Messages are written as .eml files
+// to the directory, or to the log if the directory is empty.
+type LogSender struct {
+	from      string
+	directory string
+}
+
+func CreateLogSender(from string, directory string) Sender {
+	return &LogSender{
+		from:      from,
+		directory: directory,
+	}
+}
+
+func (sender *LogSender) Send(message Message) error {
+	data, err := compose(sender.from, message)
+	if err != nil {
+		return err
+	}
+
+	if sender.directory == "" {
+		log.Printf("Mail to %s:\n%s", message.To, data)
+		return nil
+	}
+
+	name := fmt.Sprintf("%d.eml", time.Now().UnixNano())
+	return ioutil.WriteFile(filepath.Join(sender.directory, name), data, 0644)
+}
@@ -0,0 +1,79 @@
+package mail
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/mail"
+	"os"
+	"strings"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string // plain text
+}
+
+type Sender interface {
+	Send(message Message) error
+}
+
+// Creates the sender configured with MAIL_DRIVER env variable:
+// 'smtp' sends mail via SMTP_HOST (see CreateSMTPSender),
+// 'log' (the default, for local development) writes mail to MAIL_DIRECTORY or to the log if it's not set.
+func CreateSenderFromEnv() (Sender, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+
+	switch driver := os.Getenv("MAIL_DRIVER"); driver {
+	case "", "log":
+		return CreateLogSender(from, os.Getenv("MAIL_DIRECTORY")), nil
+	case "smtp":
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			return nil, errors.New("SMTP_HOST is not set")
+		}
+
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+
+		return CreateSMTPSender(host, port, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), from), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver '%s'", driver)
+	}
+}
+
+// returns the address part of a valid email address, e.g. "John <John@Example.com>" -> "John@Example.com"
+func ParseAddress(address string) (string, error) {
+	parsed, err := mail.ParseAddress(address)
+	if err != nil {
+		return "", errors.New("invalid email address")
+	}
+	return parsed.Address, nil
+}
+
+// builds the message with headers, header values can't contain line breaks
+func compose(from string, message Message) ([]byte, error) {
+	if strings.ContainsAny(message.To, "\r\n") || strings.ContainsAny(message.Subject, "\r\n") {
+		return nil, errors.New("invalid message headers")
+	}
+
+	var builder strings.Builder
+	builder.WriteString("From: " + from + "\r\n")
+	builder.WriteString("To: " + message.To + "\r\n")
+	builder.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", message.Subject) + "\r\n")
+	builder.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	builder.WriteString("MIME-Version: 1.0\r\n")
+	builder.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	builder.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	builder.WriteString("\r\n")
+	builder.WriteString(strings.ReplaceAll(strings.ReplaceAll(message.Body, "\r\n", "\n"), "\n", "\r\n"))
+
+	return []byte(builder.String()), nil
+}
@@ -0,0 +1,48 @@
+package mail
+
+import (
+	"net"
+	"net/smtp"
+)
+
+type SMTPSender struct {
+	address  string
+	auth     smtp.Auth
+	from     string
+	fromAddr string
+}
+
+// username can be empty if the server doesn't require authentication,
+// the connection is upgraded with STARTTLS if the server supports it
+func CreateSMTPSender(host string, port string, username string, password string, from string) Sender {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	fromAddr, err := ParseAddress(from)
+	if err != nil {
+		fromAddr = from
+	}
+
+	return &SMTPSender{
+		address:  net.JoinHostPort(host, port),
+		auth:     auth,
+		from:     from,
+		fromAddr: fromAddr,
+	}
+}
+
+func (sender *SMTPSender) Send(message Message) error {
+	to, err := ParseAddress(message.To)
+	if err != nil {
+		return err
+	}
+
+	data, err := compose(sender.from, message)
+	if err != nil {
+		return err
+	}
+
+	return smtp.SendMail(sender.address, sender.auth, sender.fromAddr, []string{to}, data)
+}
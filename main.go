@@ -3,11 +3,21 @@ package main
 import (
 	"log"
 	"net/http"
+	"os"
+	"time"
 
+	"github.com/danielblagy/blog-webapp-server/auth"
 	"github.com/danielblagy/blog-webapp-server/controller"
 	"github.com/danielblagy/blog-webapp-server/db"
+	"github.com/danielblagy/blog-webapp-server/entity"
+	"github.com/danielblagy/blog-webapp-server/events"
+	"github.com/danielblagy/blog-webapp-server/mail"
+	"github.com/danielblagy/blog-webapp-server/oidc"
+	"github.com/danielblagy/blog-webapp-server/ratelimit"
 	"github.com/danielblagy/blog-webapp-server/routes"
+	"github.com/danielblagy/blog-webapp-server/scheduler"
 	"github.com/danielblagy/blog-webapp-server/service"
+	"github.com/danielblagy/blog-webapp-server/storage"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
@@ -16,9 +26,54 @@ var (
 	usersService    service.UsersService
 	usersController controller.UsersController
 
+	sessionsService service.SessionsService
+	accountService  service.AccountService
+
+	twoFactorService    service.TwoFactorService
+	twoFactorController controller.TwoFactorController
+
+	identitiesService service.IdentitiesService
+	oidcController    controller.OidcController
+
+	personalTokensService    service.PersonalTokensService
+	personalTokensController controller.PersonalTokensController
+
 	articlesService    service.ArticlesService
 	articlesController controller.ArticlesController
 
+	tagsService    service.TagsService
+	tagsController controller.TagsController
+
+	commentsService    service.CommentsService
+	commentsController controller.CommentsController
+
+	mediaService    service.MediaService
+	mediaController controller.MediaController
+
+	reactionsService    service.ReactionsService
+	reactionsController controller.ReactionsController
+
+	seriesService    service.SeriesService
+	seriesController controller.SeriesController
+
+	collectionsService    service.CollectionsService
+	collectionsController controller.CollectionsController
+
+	searchController controller.SearchController
+
+	revisionsService    service.RevisionsService
+	revisionsController controller.RevisionsController
+
+	feedsController controller.FeedsController
+
+	auditService    service.AuditService
+	adminController controller.AdminController
+
+	keysController controller.KeysController
+
+	notificationsService    service.NotificationsService
+	notificationsController controller.NotificationsController
+
 	database          *gorm.DB
 	dbConnectionError error
 )
@@ -30,20 +85,145 @@ func main() {
 		return
 	}
 
+	mailSender, err := mail.CreateSenderFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to set up mail: %s", err.Error())
+		return
+	}
+
+	siteUrl, err := service.SiteUrlFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to set up mail: %s", err.Error())
+		return
+	}
+
+	rateLimitStore, err := ratelimit.CreateStoreFromEnv(database)
+	if err != nil {
+		log.Fatalf("Failed to set up rate limits: %s", err.Error())
+		return
+	}
+	limiter := ratelimit.CreateLimiter(rateLimitStore)
+
+	if err := auth.LoadKeysFromEnv(); err != nil {
+		log.Fatalf("Failed to load JWT keys: %s", err.Error())
+		return
+	}
+
+	if err := auth.LoadAccountSecretFromEnv(); err != nil {
+		log.Fatalf("Failed to load the account secret: %s", err.Error())
+		return
+	}
+
+	mediaStorage, err := storage.CreateStorageFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to set up storage: %s", err.Error())
+		return
+	}
+	entity.SetMediaUrlResolver(mediaStorage.Url)
+
+	maxMediaSize, err := service.MaxMediaSizeFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to set up media uploads: %s", err.Error())
+		return
+	}
+
+	reactionTypes, err := service.ReactionTypesFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to set up reaction types: %s", err.Error())
+		return
+	}
+
+	oidcProviders, err := oidc.ProvidersFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to set up OpenID Connect providers: %s", err.Error())
+		return
+	}
+
 	// init services and controllers
 
 	// TODO: init services and controllers somewhere else ??
 
-	articlesService = service.CreateArticlesService(database)
-	articlesController = controller.CreateArticlesController(articlesService)
+	bus := events.CreateBus()
+
+	tagsService = service.CreateTagsService(database)
+	revisionsService = service.CreateRevisionsService(database)
+	reactionsService = service.CreateReactionsService(database, reactionTypes)
+	mediaService = service.CreateMediaService(database, mediaStorage)
+
+	articlesService = service.CreateArticlesService(database, tagsService, revisionsService, reactionsService, mediaService, bus)
+
+	tagsController = controller.CreateTagsController(tagsService, articlesService)
+
+	commentsService = service.CreateCommentsService(database, articlesService)
+	commentsController = controller.CreateCommentsController(commentsService, articlesService)
+
+	revisionsController = controller.CreateRevisionsController(revisionsService, articlesService)
+
+	reactionsController = controller.CreateReactionsController(reactionsService, articlesService)
+
+	mediaController = controller.CreateMediaController(mediaService, maxMediaSize)
+
+	seriesService = service.CreateSeriesService(database)
+	seriesController = controller.CreateSeriesController(seriesService, articlesService)
+
+	collectionsService = service.CreateCollectionsService(database, articlesService)
+	collectionsController = controller.CreateCollectionsController(collectionsService, articlesService)
 
-	usersService = service.CreateUsersService(database, articlesService)
-	usersController = controller.CreateUsersController(usersService)
+	usersService = service.CreateUsersService(database, articlesService, bus, mediaStorage)
+	sessionsService = service.CreateSessionsService(database)
+	accountService = service.CreateAccountService(database, usersService, mailSender, siteUrl)
+	twoFactorService = service.CreateTwoFactorService(database)
+	usersController = controller.CreateUsersController(usersService, sessionsService, accountService, twoFactorService, limiter)
+	twoFactorController = controller.CreateTwoFactorController(twoFactorService, usersService)
+
+	identitiesService = service.CreateIdentitiesService(database)
+	oidcController = controller.CreateOidcController(oidcProviders, usersService, identitiesService, sessionsService, twoFactorService)
+
+	personalTokensService = service.CreatePersonalTokensService(database, usersService)
+	personalTokensController = controller.CreatePersonalTokensController(personalTokensService)
+	auth.SetPersonalAccessTokenResolver(personalTokensService.Resolve)
+
+	articlesController = controller.CreateArticlesController(articlesService, usersService, mediaService, seriesService)
+
+	searchController = controller.CreateSearchController(articlesService, usersService)
+	feedsController = controller.CreateFeedsController(articlesService, usersService)
+
+	auditService = service.CreateAuditService(database)
+	adminController = controller.CreateAdminController(usersService, articlesService, auditService)
+
+	keysController = controller.CreateKeysController()
+
+	notificationsService = service.CreateNotificationsService(database, bus)
+	notificationsController = controller.CreateNotificationsController(notificationsService, usersService, sessionsService, personalTokensService)
+
+	// the first admin is set with ADMIN_LOGIN env variable, the user must already be signed up
+	if adminLogin := os.Getenv("ADMIN_LOGIN"); adminLogin != "" {
+		if err := service.BootstrapAdmin(usersService, auditService, adminLogin); err != nil {
+			log.Printf("Failed to bootstrap admin '%s': %s", adminLogin, err.Error())
+		}
+	}
+
+	// articles created before slugs existed get them on the first start
+	if err := service.GenerateMissingSlugs(database); err != nil {
+		log.Printf("Failed to generate article slugs: %s", err.Error())
+	}
+
+	// background jobs
+
+	go scheduler.PublishScheduledArticles(articlesService, time.Minute)
+	go scheduler.DeleteOrphanedMedia(mediaService, time.Hour, 24*time.Hour)
+	go scheduler.ProcessMedia(mediaService, time.Minute)
 
 	// set up gin router
 
 	router := gin.Default()
 
+	// per IP rate limits rely on the client address, forwarded headers are only trusted from the configured proxies
+	if err := router.SetTrustedProxies(ratelimit.TrustedProxiesFromEnv()); err != nil {
+		log.Fatalf("Failed to set up trusted proxies: %s", err.Error())
+		return
+	}
+
 	router.GET("/", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
 			"message": "hello world!",
@@ -51,8 +231,26 @@ func main() {
 	})
 
 	api := router.Group("/")
-	routes.CreateUsersRoutes(api, usersController)
-	routes.CreateArticlesRoutes(api, articlesController)
+	routes.CreateUsersRoutes(api, usersController, limiter)
+	routes.CreateTwoFactorRoutes(api, twoFactorController)
+	routes.CreateOidcRoutes(api, oidcController)
+	routes.CreatePersonalTokensRoutes(api, personalTokensController)
+	routes.CreateArticlesRoutes(api, articlesController, commentsController)
+	routes.CreatePermalinkRoutes(api, usersController, articlesController)
+	routes.CreateTagsRoutes(api, tagsController)
+	routes.CreateReactionsRoutes(api, reactionsController)
+	routes.CreateMediaRoutes(api, mediaController, limiter)
+	routes.CreateSeriesRoutes(api, seriesController)
+	routes.CreateCollectionsRoutes(api, collectionsController)
+	routes.CreateSearchRoutes(api, searchController)
+	routes.CreateRevisionsRoutes(api, revisionsController)
+	routes.CreateFeedsRoutes(api, feedsController)
+	routes.CreateAdminRoutes(api, adminController)
+	routes.CreateKeysRoutes(api, keysController)
+	routes.CreateNotificationsRoutes(api, notificationsController)
+	if localStorage, ok := mediaStorage.(*storage.LocalStorage); ok {
+		routes.CreateUploadsRoutes(api, localStorage.Directory)
+	}
 
 	log.Fatal(router.Run(":4000"))
 }
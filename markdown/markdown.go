@@ -0,0 +1,76 @@
+package markdown
+
+import (
+	"bytes"
+	"html"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+const (
+	Plain    = "plain"
+	Markdown = "markdown"
+)
+
+// rel attribute of every rendered link, user content links shouldn't get any
+// search engine credit or access to the page that opened them
+const linkRel = "nofollow noopener noreferrer ugc"
+
+// goldmark doesn't render raw html (it's replaced with <!-- raw HTML omitted -->) and drops
+// javascript:, vbscript:, file: and data: (except images) urls unless html.WithUnsafe() is used,
+// so the output is safe to insert into pages as is
+var renderer = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithParserOptions(
+		parser.WithASTTransformers(util.Prioritized(linkAttributesTransformer{}, 100)),
+	),
+)
+
+type linkAttributesTransformer struct{}
+
+func (transformer linkAttributesTransformer) Transform(document *ast.Document, reader text.Reader, pc parser.Context) {
+	ast.Walk(document, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering && (node.Kind() == ast.KindLink || node.Kind() == ast.KindAutoLink) {
+			node.SetAttributeString("rel", []byte(linkRel))
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+func IsValidFormat(format string) bool {
+	return format == Plain || format == Markdown
+}
+
+// Renders the content to sanitized html, plain text is escaped and split into paragraphs on empty lines
+func Render(format string, content string) (string, error) {
+	if format != Markdown {
+		return renderPlain(content), nil
+	}
+
+	var buffer bytes.Buffer
+	if err := renderer.Convert([]byte(content), &buffer); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+func renderPlain(content string) string {
+	var builder strings.Builder
+	for _, paragraph := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+
+		builder.WriteString("<p>")
+		builder.WriteString(strings.ReplaceAll(html.EscapeString(paragraph), "\n", "<br>\n"))
+		builder.WriteString("</p>\n")
+	}
+	return builder.String()
+}
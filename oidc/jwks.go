@@ -0,0 +1,137 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"time"
+)
+
+// keys are fetched again at most this often when a token is signed with an unknown key
+const keysRefreshInterval = time.Minute
+
+type keySet struct {
+	keys      map[string]interface{} // by kid
+	fetchedAt time.Time
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	bytes, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(bytes), nil
+}
+
+// converts RSA and EC keys to public keys, other keys are skipped
+func (jwk jsonWebKey) publicKey() (interface{}, bool) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return nil, false
+		}
+		e, err := decodeBigInt(jwk.E)
+		if err != nil || !e.IsInt64() {
+			return nil, false
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, true
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, false
+		}
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return nil, false
+		}
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return nil, false
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, true
+	}
+	return nil, false
+}
+
+func (provider *Provider) fetchKeys() (*keySet, error) {
+	discovered, err := provider.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	var document struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := getJSON(discovered.JwksUri, &document); err != nil {
+		return nil, err
+	}
+
+	set := &keySet{keys: map[string]interface{}{}, fetchedAt: time.Now()}
+	for _, jwk := range document.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key, ok := jwk.publicKey(); ok {
+			set.keys[jwk.Kid] = key
+		}
+	}
+
+	return set, nil
+}
+
+// Returns the signing key with the kid. Keys are cached, they are fetched again when the kid
+// is unknown, since providers rotate their keys. An empty kid matches the only key of the provider.
+func (provider *Provider) key(kid string) (interface{}, error) {
+	provider.mutex.Lock()
+	keys := provider.keys
+	provider.mutex.Unlock()
+
+	if keys == nil || (keys.find(kid) == nil && time.Since(keys.fetchedAt) > keysRefreshInterval) {
+		fetched, err := provider.fetchKeys()
+		if err != nil {
+			return nil, err
+		}
+
+		provider.mutex.Lock()
+		provider.keys = fetched
+		provider.mutex.Unlock()
+		keys = fetched
+	}
+
+	if key := keys.find(kid); key != nil {
+		return key, nil
+	}
+
+	return nil, errors.New("unknown signing key")
+}
+
+func (set *keySet) find(kid string) interface{} {
+	if kid == "" && len(set.keys) == 1 {
+		for _, key := range set.keys {
+			return key
+		}
+	}
+	return set.keys[kid]
+}
@@ -0,0 +1,205 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var httpClient = &http.Client{Timeout: time.Second * 10}
+
+// OpenID Connect provider, signs users in with the authorization code flow with PKCE.
+// Endpoints and keys are discovered from the issuer, so any compliant provider works.
+type Provider struct {
+	Name         string
+	Issuer       string
+	ClientId     string
+	ClientSecret string // can be empty for public clients
+	Scopes       []string
+
+	mutex    sync.Mutex
+	metadata *metadata
+	keys     *keySet
+}
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksUri               string `json:"jwks_uri"`
+}
+
+// Reads providers from env variables: OIDC_PROVIDERS is a comma separated list of names,
+// each provider is configured with OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID,
+// OIDC_<NAME>_CLIENT_SECRET (optional) and OIDC_<NAME>_SCOPES (optional, space separated).
+func ProvidersFromEnv() (map[string]*Provider, error) {
+	providers := map[string]*Provider{}
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		provider := &Provider{
+			Name:         name,
+			Issuer:       strings.TrimSuffix(os.Getenv(prefix+"ISSUER"), "/"),
+			ClientId:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+		}
+
+		if provider.Issuer == "" || provider.ClientId == "" {
+			return nil, fmt.Errorf("%sISSUER and %sCLIENT_ID must be set", prefix, prefix)
+		}
+
+		if len(provider.Scopes) == 0 {
+			provider.Scopes = []string{"openid", "email", "profile"}
+		}
+
+		providers[name] = provider
+	}
+
+	return providers, nil
+}
+
+// random url-safe string for state, nonce and PKCE code verifier
+func RandomString() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// S256 PKCE code challenge for the verifier
+func CodeChallenge(verifier string) string {
+	hash := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+func getJSON(url string, target interface{}) error {
+	response, err := httpClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", response.StatusCode, url)
+	}
+
+	return json.NewDecoder(response.Body).Decode(target)
+}
+
+// fetches the provider metadata once, it's retried on the next call after a failure
+func (provider *Provider) discover() (metadata, error) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	if provider.metadata != nil {
+		return *provider.metadata, nil
+	}
+
+	var discovered metadata
+	if err := getJSON(provider.Issuer+"/.well-known/openid-configuration", &discovered); err != nil {
+		return discovered, err
+	}
+
+	if strings.TrimSuffix(discovered.Issuer, "/") != provider.Issuer {
+		return discovered, errors.New("issuer in the provider metadata doesn't match the configured issuer")
+	}
+
+	if discovered.AuthorizationEndpoint == "" || discovered.TokenEndpoint == "" || discovered.JwksUri == "" {
+		return discovered, errors.New("provider metadata is incomplete")
+	}
+
+	provider.metadata = &discovered
+	return discovered, nil
+}
+
+// url of the provider's sign in page, the user is redirected back to redirectUrl with the code and the state
+func (provider *Provider) AuthCodeURL(redirectUrl string, state string, nonce string, codeVerifier string) (string, error) {
+	discovered, err := provider.discover()
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", provider.ClientId)
+	query.Set("redirect_uri", redirectUrl)
+	query.Set("scope", strings.Join(provider.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", CodeChallenge(codeVerifier))
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(discovered.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return discovered.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchanges the authorization code for tokens and returns the verified claims of the id token.
+// redirectUrl must be the same as in AuthCodeURL, nonce is the one sent to AuthCodeURL.
+func (provider *Provider) Exchange(code string, redirectUrl string, codeVerifier string, nonce string) (Claims, error) {
+	discovered, err := provider.discover()
+	if err != nil {
+		return Claims{}, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectUrl)
+	form.Set("client_id", provider.ClientId)
+	form.Set("code_verifier", codeVerifier)
+
+	request, err := http.NewRequest(http.MethodPost, discovered.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Claims{}, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	if provider.ClientSecret != "" {
+		request.SetBasicAuth(url.QueryEscape(provider.ClientId), url.QueryEscape(provider.ClientSecret))
+	}
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return Claims{}, err
+	}
+	defer response.Body.Close()
+
+	var tokens struct {
+		IdToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&tokens); err != nil {
+		return Claims{}, errors.New("invalid token response")
+	}
+
+	if response.StatusCode != http.StatusOK || tokens.Error != "" {
+		return Claims{}, fmt.Errorf("code exchange failed: %s %s", tokens.Error, tokens.ErrorDescription)
+	}
+
+	if tokens.IdToken == "" {
+		return Claims{}, errors.New("token response has no id token")
+	}
+
+	return provider.verify(tokens.IdToken, nonce)
+}
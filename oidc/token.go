@@ -0,0 +1,105 @@
+package oidc
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// claims of the id token the user is identified by
+type Claims struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+// allowed clock difference with the provider
+const leeway = time.Minute
+
+func stringClaim(claims jwt.MapClaims, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+// aud can be a string or an array of strings
+func hasAudience(claims jwt.MapClaims, audience string) bool {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, value := range aud {
+			if value == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func numericClaim(claims jwt.MapClaims, name string) (int64, bool) {
+	value, ok := claims[name].(float64)
+	return int64(value), ok
+}
+
+// checks the signature of the id token with the provider's keys, and its issuer, audience, expiration time and nonce
+func (provider *Provider) verify(rawIdToken string, nonce string) (Claims, error) {
+	mapClaims := jwt.MapClaims{}
+	parser := &jwt.Parser{
+		ValidMethods:         []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"},
+		SkipClaimsValidation: true, // validated below with leeway
+	}
+
+	_, err := parser.ParseWithClaims(rawIdToken, mapClaims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return provider.key(kid)
+	})
+	if err != nil {
+		return Claims{}, errors.New("invalid id token: " + err.Error())
+	}
+
+	now := time.Now()
+
+	if stringClaim(mapClaims, "iss") != provider.Issuer {
+		return Claims{}, errors.New("invalid id token issuer")
+	}
+
+	if !hasAudience(mapClaims, provider.ClientId) {
+		return Claims{}, errors.New("invalid id token audience")
+	}
+
+	if exp, ok := numericClaim(mapClaims, "exp"); !ok || now.After(time.Unix(exp, 0).Add(leeway)) {
+		return Claims{}, errors.New("id token has expired")
+	}
+
+	if iat, ok := numericClaim(mapClaims, "iat"); ok && time.Unix(iat, 0).After(now.Add(leeway)) {
+		return Claims{}, errors.New("id token is issued in the future")
+	}
+
+	if stringClaim(mapClaims, "nonce") != nonce {
+		return Claims{}, errors.New("invalid id token nonce")
+	}
+
+	claims := Claims{
+		Subject:           stringClaim(mapClaims, "sub"),
+		Email:             stringClaim(mapClaims, "email"),
+		Name:              stringClaim(mapClaims, "name"),
+		PreferredUsername: stringClaim(mapClaims, "preferred_username"),
+	}
+
+	// some providers send email_verified as a string
+	switch verified := mapClaims["email_verified"].(type) {
+	case bool:
+		claims.EmailVerified = verified
+	case string:
+		claims.EmailVerified = verified == "true"
+	}
+
+	if claims.Subject == "" {
+		return Claims{}, errors.New("id token has no subject")
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,180 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// Points to the item the page starts after (or ends before if Backward is true).
+// Clients receive it encoded and must treat it as an opaque string.
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	Id        int       `json:"id"`
+	Backward  bool      `json:"b,omitempty"`
+}
+
+type Params struct {
+	Limit  int
+	Cursor *Cursor // nil for the first page
+}
+
+type Cursors struct {
+	Next string `json:"next_cursor"`
+	Prev string `json:"prev_cursor"`
+}
+
+// Response envelope for all paginated list endpoints
+type Page struct {
+	Items interface{} `json:"items"`
+	Cursors
+}
+
+func (cursor Cursor) Encode() string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(encoded string) (Cursor, error) {
+	var cursor Cursor
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return cursor, errors.New("invalid cursor")
+	}
+
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return cursor, errors.New("invalid cursor")
+	}
+
+	return cursor, nil
+}
+
+// reads 'limit' query parameter, DefaultLimit if it's not provided
+func ParseLimit(c *gin.Context) (int, error) {
+	limit := c.Query("limit")
+	if limit == "" {
+		return DefaultLimit, nil
+	}
+
+	parsedLimit, err := strconv.Atoi(limit)
+	if err != nil || parsedLimit < 1 || parsedLimit > MaxLimit {
+		return DefaultLimit, fmt.Errorf("limit must be between 1 and %d", MaxLimit)
+	}
+
+	return parsedLimit, nil
+}
+
+// reads 'limit' and 'cursor' query parameters
+func ParseParams(c *gin.Context) (Params, error) {
+	limit, err := ParseLimit(c)
+	if err != nil {
+		return Params{}, err
+	}
+
+	params := Params{Limit: limit}
+
+	if encodedCursor := c.Query("cursor"); encodedCursor != "" {
+		cursor, err := DecodeCursor(encodedCursor)
+		if err != nil {
+			return params, err
+		}
+		params.Cursor = &cursor
+	}
+
+	return params, nil
+}
+
+func (params Params) backward() bool {
+	return params.Cursor != nil && params.Cursor.Backward
+}
+
+// Orders the query from the newest to the oldest items and selects the requested page.
+// timeColumn can be empty, then items are ordered only by idColumn.
+// One extra item is fetched to find out if there are more pages, see Trim.
+func Apply(query *gorm.DB, params Params, timeColumn string, idColumn string) *gorm.DB {
+	order := "desc"
+	if params.backward() {
+		order = "asc"
+	}
+
+	if params.Cursor != nil {
+		operator := "<"
+		if params.backward() {
+			operator = ">"
+		}
+
+		if timeColumn != "" {
+			query = query.Where(fmt.Sprintf("(%s, %s) %s (?, ?)", timeColumn, idColumn, operator), params.Cursor.CreatedAt, params.Cursor.Id)
+		} else {
+			query = query.Where(fmt.Sprintf("%s %s ?", idColumn, operator), params.Cursor.Id)
+		}
+	}
+
+	if timeColumn != "" {
+		query = query.Order(timeColumn + " " + order)
+	}
+
+	return query.Order(idColumn + " " + order).Limit(params.Limit + 1)
+}
+
+// Takes a pointer to the slice of items fetched with Apply, removes the extra item and restores
+// the newest to oldest order of backward pages.
+// Returns true if there are more items in the direction of pagination.
+func Trim(params Params, items interface{}) bool {
+	slice := reflect.ValueOf(items).Elem()
+
+	hasMore := slice.Len() > params.Limit
+	if hasMore {
+		slice.Set(slice.Slice(0, params.Limit))
+	}
+
+	if params.backward() {
+		swap := reflect.Swapper(slice.Interface())
+		for i, j := 0, slice.Len()-1; i < j; i, j = i+1, j-1 {
+			swap(i, j)
+		}
+	}
+
+	return hasMore
+}
+
+// Builds cursors for the trimmed page of count items, key returns the cursor pointing to the i-th item.
+func NewCursors(params Params, hasMore bool, count int, key func(i int) Cursor) Cursors {
+	cursors := Cursors{}
+	if count == 0 {
+		return cursors
+	}
+
+	// forward pages have more items after them if the extra item was fetched,
+	// and there is a previous page if they were requested with a cursor
+	hasNext := hasMore
+	hasPrev := params.Cursor != nil
+	if params.backward() {
+		hasNext = true
+		hasPrev = hasMore
+	}
+
+	if hasNext {
+		cursors.Next = key(count - 1).Encode()
+	}
+
+	if hasPrev {
+		prev := key(0)
+		prev.Backward = true
+		cursors.Prev = prev.Encode()
+	}
+
+	return cursors
+}
@@ -0,0 +1,99 @@
+package ratelimit
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// remaining requests of the most restrictive limit applied to the request so far
+const remainingKey = "rateLimitRemaining"
+
+// Applies limits and lockouts to requests. Store errors are logged and the requests are let through,
+// so the API stays available when the store isn't.
+type Limiter struct {
+	store Store
+}
+
+func CreateLimiter(store Store) *Limiter {
+	return &Limiter{store: store}
+}
+
+// middleware limiting requests from one IP address
+func (limiter *Limiter) PerIP(limit Limit) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !limiter.Allow(c, limit, c.ClientIP()) {
+			c.Abort()
+		}
+	}
+}
+
+// Takes a token from the key's bucket and sets RateLimit-* headers.
+// Sends out 429 Too Many Requests with Retry-After header if the limit is exceeded.
+func (limiter *Limiter) Allow(c *gin.Context, limit Limit, key string) bool {
+	result, err := limiter.store.Take(limit.Name+":"+key, limit)
+	if err != nil {
+		log.Printf("Failed to check rate limit '%s': %s", limit.Name, err.Error())
+		return true
+	}
+
+	// with several limits the headers describe the one with the fewest requests left
+	if remaining, ok := c.Get(remainingKey); !ok || result.Remaining <= remaining.(int) {
+		c.Set(remainingKey, result.Remaining)
+		c.Header("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", formatSeconds(result.Reset))
+		c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%s", limit.Burst, formatSeconds(limit.Period)))
+	}
+
+	if !result.Allowed {
+		c.Header("Retry-After", formatSeconds(result.RetryAfter))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"message": "too many requests, try again later",
+		})
+		return false
+	}
+
+	return true
+}
+
+// Sends out 429 Too Many Requests with Retry-After header if the key is locked out
+func (limiter *Limiter) CheckLockout(c *gin.Context, lockout Lockout, key string) bool {
+	lockedUntil, err := limiter.store.LockedUntil(lockout.Name + ":" + key)
+	if err != nil {
+		log.Printf("Failed to check lockout '%s': %s", lockout.Name, err.Error())
+		return true
+	}
+
+	if wait := time.Until(lockedUntil); wait > 0 {
+		c.Header("Retry-After", formatSeconds(wait))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"message": "too many failed attempts, try again later",
+		})
+		return false
+	}
+
+	return true
+}
+
+func (limiter *Limiter) AddFailure(lockout Lockout, key string) {
+	if _, err := limiter.store.AddFailure(lockout.Name+":"+key, lockout); err != nil {
+		log.Printf("Failed to record failure for lockout '%s': %s", lockout.Name, err.Error())
+	}
+}
+
+func (limiter *Limiter) ResetFailures(lockout Lockout, key string) {
+	if err := limiter.store.ResetFailures(lockout.Name + ":" + key); err != nil {
+		log.Printf("Failed to reset failures for lockout '%s': %s", lockout.Name, err.Error())
+	}
+}
+
+// whole seconds, rounded up so clients don't retry too early
+func formatSeconds(duration time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(duration.Seconds())))
+}
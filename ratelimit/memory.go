@@ -0,0 +1,117 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// expired buckets and failures are removed at most this often
+const sweepInterval = time.Minute
+
+type memoryBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	expiresAt time.Time
+}
+
+type memoryFailures struct {
+	count       int
+	lockedUntil time.Time
+	updatedAt   time.Time
+	expiresAt   time.Time
+}
+
+// Keeps the limits in memory, each instance has its own limits
+type MemoryStore struct {
+	mutex     sync.Mutex
+	buckets   map[string]*memoryBucket
+	failures  map[string]*memoryFailures
+	lastSweep time.Time
+}
+
+func CreateMemoryStore() Store {
+	return &MemoryStore{
+		buckets:   map[string]*memoryBucket{},
+		failures:  map[string]*memoryFailures{},
+		lastSweep: time.Now(),
+	}
+}
+
+func (store *MemoryStore) Take(key string, limit Limit) (Result, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := time.Now()
+	store.sweep(now)
+
+	bucket, ok := store.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{}
+		store.buckets[key] = bucket
+	}
+
+	tokens, result := take(bucket.tokens, bucket.updatedAt, limit, now)
+	bucket.tokens = tokens
+	bucket.updatedAt = now
+	bucket.expiresAt = now.Add(result.Reset)
+
+	return result, nil
+}
+
+func (store *MemoryStore) AddFailure(key string, lockout Lockout) (time.Time, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := time.Now()
+	store.sweep(now)
+
+	failures, ok := store.failures[key]
+	if !ok {
+		failures = &memoryFailures{}
+		store.failures[key] = failures
+	}
+
+	failures.count, failures.lockedUntil = addFailure(failures.count, failures.updatedAt, lockout, now)
+	failures.updatedAt = now
+	failures.expiresAt = failuresExpiresAt(failures.lockedUntil, lockout, now)
+
+	return failures.lockedUntil, nil
+}
+
+func (store *MemoryStore) LockedUntil(key string) (time.Time, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if failures, ok := store.failures[key]; ok {
+		return failures.lockedUntil, nil
+	}
+	return time.Time{}, nil
+}
+
+func (store *MemoryStore) ResetFailures(key string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	delete(store.failures, key)
+	return nil
+}
+
+// the mutex must be held
+func (store *MemoryStore) sweep(now time.Time) {
+	if now.Sub(store.lastSweep) < sweepInterval {
+		return
+	}
+	store.lastSweep = now
+
+	for key, bucket := range store.buckets {
+		if now.After(bucket.expiresAt) {
+			delete(store.buckets, key)
+		}
+	}
+
+	for key, failures := range store.failures {
+		if now.After(failures.expiresAt) {
+			delete(store.failures, key)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/danielblagy/blog-webapp-server/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Keeps the limits in the database, so they are shared by all instances.
+// Rows of a key are locked while they are updated.
+type PostgresStore struct {
+	database  *gorm.DB
+	mutex     sync.Mutex
+	lastSweep time.Time
+}
+
+func CreatePostgresStore(database *gorm.DB) Store {
+	return &PostgresStore{
+		database:  database,
+		lastSweep: time.Now(),
+	}
+}
+
+func (store *PostgresStore) Take(key string, limit Limit) (Result, error) {
+	store.sweep()
+
+	var result Result
+	err := store.database.Transaction(func(tx *gorm.DB) error {
+		// a new bucket is full
+		newBucket := entity.RateLimitBucket{Key: key, Tokens: float64(limit.Burst), ExpiresAt: time.Now()}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&newBucket).Error; err != nil {
+			return err
+		}
+
+		var bucket entity.RateLimitBucket
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&bucket).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		var tokens float64
+		tokens, result = take(bucket.Tokens, bucket.UpdatedAt, limit, now)
+
+		return tx.Model(&entity.RateLimitBucket{}).Where("key = ?", key).Updates(map[string]interface{}{
+			"tokens":     tokens,
+			"updated_at": now,
+			"expires_at": now.Add(result.Reset),
+		}).Error
+	})
+
+	return result, err
+}
+
+func (store *PostgresStore) AddFailure(key string, lockout Lockout) (time.Time, error) {
+	store.sweep()
+
+	var lockedUntil time.Time
+	err := store.database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&entity.RateLimitFailures{Key: key, ExpiresAt: time.Now()}).Error; err != nil {
+			return err
+		}
+
+		var failures entity.RateLimitFailures
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&failures).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		var count int
+		count, lockedUntil = addFailure(failures.Count, failures.UpdatedAt, lockout, now)
+
+		return tx.Model(&entity.RateLimitFailures{}).Where("key = ?", key).Updates(map[string]interface{}{
+			"count":        count,
+			"locked_until": lockedUntil,
+			"updated_at":   now,
+			"expires_at":   failuresExpiresAt(lockedUntil, lockout, now),
+		}).Error
+	})
+
+	return lockedUntil, err
+}
+
+func (store *PostgresStore) LockedUntil(key string) (time.Time, error) {
+	var failures []entity.RateLimitFailures
+	if err := store.database.Where("key = ?", key).Limit(1).Find(&failures).Error; err != nil {
+		return time.Time{}, err
+	}
+
+	if len(failures) == 0 {
+		return time.Time{}, nil
+	}
+	return failures[0].LockedUntil, nil
+}
+
+func (store *PostgresStore) ResetFailures(key string) error {
+	return store.database.Where("key = ?", key).Delete(&entity.RateLimitFailures{}).Error
+}
+
+// deletes expired rows, at most once per sweepInterval on each instance
+func (store *PostgresStore) sweep() {
+	store.mutex.Lock()
+	now := time.Now()
+	if now.Sub(store.lastSweep) < sweepInterval {
+		store.mutex.Unlock()
+		return
+	}
+	store.lastSweep = now
+	store.mutex.Unlock()
+
+	if err := store.database.Where("expires_at < ?", now).Delete(&entity.RateLimitBucket{}).Error; err != nil {
+		log.Printf("Failed to delete expired rate limit buckets: %s", err.Error())
+	}
+	if err := store.database.Where("expires_at < ?", now).Delete(&entity.RateLimitFailures{}).Error; err != nil {
+		log.Printf("Failed to delete expired rate limit failures: %s", err.Error())
+	}
+}
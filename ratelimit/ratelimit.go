@@ -0,0 +1,139 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Token bucket limit: every key has a bucket of Burst tokens, every request takes a token,
+// and the bucket refills evenly, from empty to full in Period
+type Limit struct {
+	Name   string // prefix of the keys, keeps the buckets of different limits apart
+	Burst  int
+	Period time.Duration
+}
+
+// tokens per second
+func (limit Limit) rate() float64 {
+	return float64(limit.Burst) / limit.Period.Seconds()
+}
+
+// Locks a key out after too many failures in a row, e.g. wrong passwords for an account.
+// The first lockout lasts Duration, every next failure doubles it up to MaxDuration.
+type Lockout struct {
+	Name        string
+	Threshold   int           // failures allowed before the key is locked
+	Duration    time.Duration // lockout after the first failure over the threshold
+	MaxDuration time.Duration
+	Window      time.Duration // failures are forgotten after this long without new ones
+}
+
+type Result struct {
+	Limit      Limit
+	Allowed    bool
+	Remaining  int
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until the next token, 0 if the request is allowed
+}
+
+// Keeps the buckets and failures, Memory store for a single instance and Postgres store
+// when several instances must share the limits. Operations on a key are atomic.
+type Store interface {
+	// takes a token from the key's bucket if there is one
+	Take(key string, limit Limit) (Result, error)
+	// records a failure, returns the time the key is locked until (zero if it isn't locked)
+	AddFailure(key string, lockout Lockout) (time.Time, error)
+	LockedUntil(key string) (time.Time, error)
+	ResetFailures(key string) error
+}
+
+// Creates the store configured with RATE_LIMIT_STORE env variable:
+// 'memory' (the default) keeps the limits in the instance's memory,
+// 'postgres' keeps them in the database, shared by all instances.
+func CreateStoreFromEnv(database *gorm.DB) (Store, error) {
+	switch driver := os.Getenv("RATE_LIMIT_STORE"); driver {
+	case "", "memory":
+		return CreateMemoryStore(), nil
+	case "postgres":
+		return CreatePostgresStore(database), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit store '%s'", driver)
+	}
+}
+
+// Reads TRUSTED_PROXIES env variable, a comma separated list of the IP addresses and CIDR ranges
+// of the reverse proxies in front of the server. Client addresses in X-Forwarded-For and X-Real-IP
+// are only used for requests coming from these proxies. Nil, so the headers are ignored, if it isn't set.
+func TrustedProxiesFromEnv() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+// Refills the bucket for the time since it was last updated and takes a token if there is one.
+// A bucket that was never updated is full.
+func take(tokens float64, updatedAt time.Time, limit Limit, now time.Time) (float64, Result) {
+	if updatedAt.IsZero() {
+		tokens = float64(limit.Burst)
+	} else if elapsed := now.Sub(updatedAt).Seconds(); elapsed > 0 {
+		tokens = math.Min(float64(limit.Burst), tokens+elapsed*limit.rate())
+	}
+
+	result := Result{Limit: limit}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - tokens) / limit.rate())
+	}
+
+	result.Remaining = int(tokens)
+	result.Reset = seconds((float64(limit.Burst) - tokens) / limit.rate())
+	return tokens, result
+}
+
+// Counts the failure, failures older than the window are forgotten first.
+// Returns the new count and the time the key is locked until.
+func addFailure(count int, updatedAt time.Time, lockout Lockout, now time.Time) (int, time.Time) {
+	if now.Sub(updatedAt) > lockout.Window {
+		count = 0
+	}
+	count++
+
+	over := count - lockout.Threshold
+	if over <= 0 {
+		return count, time.Time{}
+	}
+
+	duration := lockout.Duration
+	for i := 1; i < over && duration < lockout.MaxDuration; i++ {
+		duration *= 2
+	}
+	if duration > lockout.MaxDuration {
+		duration = lockout.MaxDuration
+	}
+
+	return count, now.Add(duration)
+}
+
+// the failures can be deleted once the key is unlocked and the window has passed
+func failuresExpiresAt(lockedUntil time.Time, lockout Lockout, now time.Time) time.Time {
+	expiresAt := now.Add(lockout.Window)
+	if lockedUntil.After(expiresAt) {
+		return lockedUntil
+	}
+	return expiresAt
+}
+
+func seconds(value float64) time.Duration {
+	return time.Duration(value * float64(time.Second))
+}
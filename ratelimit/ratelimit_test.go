@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTake(t *testing.T) {
+	// 10 tokens, refilled at one token a second
+	limit := Limit{Name: "test", Burst: 10, Period: 10 * time.Second}
+	now := time.Unix(1000000, 0)
+
+	tests := []struct {
+		name       string
+		tokens     float64
+		updatedAt  time.Time
+		wantTokens float64
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+	}{
+		{"new bucket is full", 0, time.Time{}, 9, true, 9, 0},
+		{"takes a token", 5, now, 4, true, 4, 0},
+		{"refills for the elapsed time", 2, now.Add(-3 * time.Second), 4, true, 4, 0},
+		{"refills up to the burst", 0, now.Add(-time.Hour), 9, true, 9, 0},
+		{"empty bucket", 0, now, 0, false, 0, time.Second},
+		{"partly refilled bucket", 0.5, now, 0.5, false, 0, 500 * time.Millisecond},
+		{"clock going back doesn't refill", 0, now.Add(time.Minute), 0, false, 0, time.Second},
+	}
+
+	for _, test := range tests {
+		tokens, result := take(test.tokens, test.updatedAt, limit, now)
+		if tokens != test.wantTokens {
+			t.Errorf("%s: tokens = %v, want %v", test.name, tokens, test.wantTokens)
+		}
+		if result.Allowed != test.allowed || result.Remaining != test.remaining || result.RetryAfter != test.retryAfter {
+			t.Errorf("%s: allowed = %t, remaining = %d, retry after = %s, want %t, %d, %s", test.name,
+				result.Allowed, result.Remaining, result.RetryAfter, test.allowed, test.remaining, test.retryAfter)
+		}
+		if wantReset := seconds(10 - tokens); result.Reset != wantReset {
+			t.Errorf("%s: reset = %s, want %s", test.name, result.Reset, wantReset)
+		}
+	}
+}
+
+func TestAddFailure(t *testing.T) {
+	lockout := Lockout{
+		Name:        "test",
+		Threshold:   3,
+		Duration:    time.Minute,
+		MaxDuration: 5 * time.Minute,
+		Window:      time.Hour,
+	}
+	now := time.Unix(1000000, 0)
+
+	tests := []struct {
+		name      string
+		count     int
+		updatedAt time.Time
+		wantCount int
+		locked    time.Duration // 0 if the key isn't locked
+	}{
+		{"first failure", 0, time.Time{}, 1, 0},
+		{"up to the threshold", 2, now, 3, 0},
+		{"first failure over the threshold", 3, now, 4, time.Minute},
+		{"next failure doubles the lockout", 4, now, 5, 2 * time.Minute},
+		{"doubles again", 5, now, 6, 4 * time.Minute},
+		{"capped at the max duration", 6, now, 7, 5 * time.Minute},
+		{"stays at the max duration", 20, now, 21, 5 * time.Minute},
+		{"old failures are forgotten", 20, now.Add(-2 * time.Hour), 1, 0},
+	}
+
+	for _, test := range tests {
+		count, lockedUntil := addFailure(test.count, test.updatedAt, lockout, now)
+		if count != test.wantCount {
+			t.Errorf("%s: count = %d, want %d", test.name, count, test.wantCount)
+		}
+
+		wantLockedUntil := time.Time{}
+		if test.locked != 0 {
+			wantLockedUntil = now.Add(test.locked)
+		}
+		if !lockedUntil.Equal(wantLockedUntil) {
+			t.Errorf("%s: locked until %s, want %s", test.name, lockedUntil, wantLockedUntil)
+		}
+	}
+}
@@ -1,52 +1,266 @@
 package routes
 
 import (
+	"time"
+
+	"github.com/danielblagy/blog-webapp-server/auth"
 	"github.com/danielblagy/blog-webapp-server/controller"
+	"github.com/danielblagy/blog-webapp-server/ratelimit"
 	"github.com/gin-gonic/gin"
 )
 
-func CreateUsersRoutes(apiGroup *gin.RouterGroup, usersController controller.UsersController) {
+// requests from one IP address
+var (
+	signUpLimit          = ratelimit.Limit{Name: "signup", Burst: 5, Period: time.Hour}
+	signInLimit          = ratelimit.Limit{Name: "signin", Burst: 20, Period: time.Minute * 10}
+	signInTwoFactorLimit = ratelimit.Limit{Name: "signin-2fa", Burst: 10, Period: time.Minute * 10}
+	uploadLimit          = ratelimit.Limit{Name: "upload", Burst: 60, Period: time.Hour}
+)
+
+func CreateUsersRoutes(apiGroup *gin.RouterGroup, usersController controller.UsersController, limiter *ratelimit.Limiter) {
 	users := apiGroup.Group("/users")
 
 	users.GET("/", usersController.GetAll)
-	users.GET("/:id", usersController.GetById)
+	users.GET("/:id", auth.Optional(), usersController.GetById)
 
-	users.POST("/signup", usersController.Create)
-	users.POST("/signin", usersController.SignIn)
+	users.POST("/signup", limiter.PerIP(signUpLimit), usersController.Create)
+	users.POST("/signin", limiter.PerIP(signInLimit), usersController.SignIn)
+	users.POST("/signin/2fa", limiter.PerIP(signInTwoFactorLimit), usersController.SignInTwoFactor)
 	users.POST("/refresh", usersController.Refresh)
-	users.GET("/me", usersController.Me)
-
-	users.PUT("/", usersController.Update)
-	// TODO: create delete /:id endpoint for administrators
-	users.DELETE("/", usersController.Delete)
-
-	users.POST("/follow/:id", usersController.Follow)
-	users.POST("/unfollow/:id", usersController.Unfollow)
+	users.POST("/signout", auth.Optional(), usersController.SignOut)
 
 	users.GET("/:id/followers", usersController.GetFollowers)
 	users.GET("/:id/following", usersController.GetFollowing)
 
-	users.GET("/:id/isfollowed", usersController.IsFollowed)
-}
+	users.POST("/verify-email", usersController.VerifyEmail)
+	users.POST("/reset-password/request", usersController.RequestPasswordReset)
+	users.POST("/reset-password", usersController.ResetPassword)
+
+	authorized := users.Group("", auth.Required())
+
+	authorized.GET("/me", usersController.Me)
+
+	authorized.PUT("/", usersController.Update)
+	authorized.DELETE("/", usersController.Delete)
+
+	authorized.PUT("/avatar", usersController.SetAvatar)
+	authorized.DELETE("/avatar", usersController.RemoveAvatar)
+	authorized.PUT("/header", usersController.SetHeader)
+	authorized.DELETE("/header", usersController.RemoveHeader)
+
+	authorized.POST("/follow/:id", usersController.Follow)
+	authorized.POST("/unfollow/:id", usersController.Unfollow)
 
-func CreateArticlesRoutes(apiGroup *gin.RouterGroup, articlesController controller.ArticlesController) {
-	users := apiGroup.Group("/articles")
+	authorized.GET("/:id/isfollowed", usersController.IsFollowed)
 
-	users.GET("/", articlesController.GetAll)
-	users.GET("/:id", articlesController.GetById)
+	authorized.POST("/verify-email/request", usersController.RequestEmailVerification)
 
-	users.POST("/", articlesController.Create)
+	sessions := users.Group("/sessions", auth.RequiredSession())
 
-	users.PUT("/:id", articlesController.Update)
-	users.DELETE("/:id", articlesController.Delete)
+	sessions.GET("", usersController.GetSessions)
+	sessions.DELETE("/:id", usersController.RevokeSession)
+}
+
+func CreateArticlesRoutes(apiGroup *gin.RouterGroup, articlesController controller.ArticlesController, commentsController controller.CommentsController) {
+	articles := apiGroup.Group("/articles")
+
+	articles.GET("/", auth.Optional(), articlesController.GetAll)
+	articles.GET("/:id", auth.Optional(), articlesController.GetById)
+	articles.GET("/:id/comments", auth.Optional(), commentsController.GetAll)
 
-	users.POST("/save/:id", articlesController.Save)
-	users.POST("/unsave/:id", articlesController.Unsave)
+	authorized := articles.Group("", auth.Required())
+
+	authorized.POST("/save/:id", articlesController.Save)
+	authorized.POST("/unsave/:id", articlesController.Unsave)
 
 	// returns saved articles for the authorized user
-	users.GET("/saves", articlesController.GetSaves)
+	authorized.GET("/saves", articlesController.GetSaves)
+	authorized.PUT("/saves/:id", articlesController.UpdateSave)
+
+	authorized.GET("/issaved/:id", articlesController.IsSaved)
+
+	authorized.GET("/for-you", articlesController.ForYou)
+
+	authorized.POST("/:id/comments", commentsController.Create)
+	authorized.PUT("/:id/comments/:commentId", commentsController.Update)
+	authorized.DELETE("/:id/comments/:commentId", commentsController.Delete)
+
+	writable := articles.Group("", auth.RequiredScope(auth.ScopeWriteArticles))
+
+	writable.POST("/", articlesController.Create)
+	writable.PUT("/:id", articlesController.Update)
+	writable.DELETE("/:id", articlesController.Delete)
+}
+
+// profile and article permalinks, /@login and /@login/slug
+func CreatePermalinkRoutes(apiGroup *gin.RouterGroup, usersController controller.UsersController, articlesController controller.ArticlesController) {
+	apiGroup.GET("/@:login", auth.Optional(), usersController.GetByLogin)
+	apiGroup.GET("/@:login/:slug", auth.Optional(), articlesController.GetBySlug)
+}
+
+func CreateTagsRoutes(apiGroup *gin.RouterGroup, tagsController controller.TagsController) {
+	tags := apiGroup.Group("/tags")
+
+	tags.GET("/", tagsController.GetAll)
+	tags.GET("/:name/articles", auth.Optional(), tagsController.GetArticles)
+}
+
+func CreateReactionsRoutes(apiGroup *gin.RouterGroup, reactionsController controller.ReactionsController) {
+	apiGroup.GET("/articles/reaction-types", reactionsController.GetTypes)
+
+	reactions := apiGroup.Group("/articles/:id/reactions")
+
+	reactions.GET("/", auth.Optional(), reactionsController.GetAll)
+	reactions.POST("/:type", auth.Required(), reactionsController.Add)
+	reactions.DELETE("/:type", auth.Required(), reactionsController.Remove)
+}
+
+func CreateSeriesRoutes(apiGroup *gin.RouterGroup, seriesController controller.SeriesController) {
+	apiGroup.GET("/users/:id/series", auth.Optional(), seriesController.GetByUser)
+
+	series := apiGroup.Group("/series")
+
+	series.GET("/:id", auth.Optional(), seriesController.GetById)
+
+	writable := series.Group("", auth.RequiredScope(auth.ScopeWriteArticles))
+
+	writable.POST("/", seriesController.Create)
+	writable.PUT("/:id", seriesController.Update)
+	writable.DELETE("/:id", seriesController.Delete)
+
+	writable.POST("/:id/articles", seriesController.AddArticle)
+	writable.PUT("/:id/articles", seriesController.Reorder)
+	writable.DELETE("/:id/articles/:articleId", seriesController.RemoveArticle)
+}
+
+func CreateCollectionsRoutes(apiGroup *gin.RouterGroup, collectionsController controller.CollectionsController) {
+	apiGroup.GET("/users/:id/collections", auth.Optional(), collectionsController.GetByUser)
+
+	collections := apiGroup.Group("/collections")
+
+	collections.GET("/:id", auth.Optional(), collectionsController.GetById)
+
+	authorized := collections.Group("", auth.Required())
+
+	authorized.GET("/following", collectionsController.GetFollowing)
+
+	authorized.POST("/", collectionsController.Create)
+	authorized.PUT("/:id", collectionsController.Update)
+	authorized.DELETE("/:id", collectionsController.Delete)
 
-	users.GET("/issaved/:id", articlesController.IsSaved)
+	authorized.POST("/:id/articles", collectionsController.AddArticle)
+	authorized.PUT("/:id/articles", collectionsController.Reorder)
+	authorized.DELETE("/:id/articles/:articleId", collectionsController.RemoveArticle)
+
+	authorized.POST("/:id/follow", collectionsController.Follow)
+	authorized.DELETE("/:id/follow", collectionsController.Unfollow)
+}
+
+func CreateSearchRoutes(apiGroup *gin.RouterGroup, searchController controller.SearchController) {
+	apiGroup.GET("/search", auth.Optional(), searchController.Search)
+}
+
+func CreateRevisionsRoutes(apiGroup *gin.RouterGroup, revisionsController controller.RevisionsController) {
+	revisions := apiGroup.Group("/articles/:id/revisions")
+
+	revisions.GET("/", auth.Required(), revisionsController.GetAll)
+	revisions.GET("/diff", auth.Required(), revisionsController.Diff)
+	revisions.GET("/:revisionId", auth.Required(), revisionsController.GetById)
+
+	revisions.POST("/:revisionId/restore", auth.RequiredScope(auth.ScopeWriteArticles), revisionsController.Restore)
+}
+
+func CreateFeedsRoutes(apiGroup *gin.RouterGroup, feedsController controller.FeedsController) {
+	feeds := apiGroup.Group("/feeds")
+
+	feeds.GET("/articles.rss", feedsController.SiteFeed)
+	feeds.GET("/articles.atom", feedsController.SiteFeed)
+	feeds.GET("/articles.json", feedsController.SiteFeed)
+
+	users := apiGroup.Group("/users")
+
+	users.GET("/:id/feed.rss", feedsController.UserFeed)
+	users.GET("/:id/feed.atom", feedsController.UserFeed)
+	users.GET("/:id/feed.json", feedsController.UserFeed)
+}
+
+func CreateAdminRoutes(apiGroup *gin.RouterGroup, adminController controller.AdminController) {
+	admin := apiGroup.Group("/admin", auth.RequiredScope(auth.ScopeAdmin))
+
+	admin.DELETE("/users/:id", adminController.DeleteUser)
+	admin.POST("/users/:id/suspend", adminController.SuspendUser)
+	admin.POST("/users/:id/unsuspend", adminController.UnsuspendUser)
+	admin.PUT("/users/:id/role", adminController.SetUserRole)
+
+	admin.POST("/articles/:id/unpublish", adminController.UnpublishArticle)
+	admin.DELETE("/articles/:id", adminController.DeleteArticle)
+
+	admin.GET("/audit", adminController.GetAuditLog)
+}
+
+func CreateTwoFactorRoutes(apiGroup *gin.RouterGroup, twoFactorController controller.TwoFactorController) {
+	twoFactor := apiGroup.Group("/users/2fa", auth.RequiredSession())
+
+	twoFactor.GET("/", twoFactorController.GetStatus)
+	twoFactor.POST("/enroll", twoFactorController.Enroll)
+	twoFactor.POST("/confirm", twoFactorController.Confirm)
+	twoFactor.POST("/disable", twoFactorController.Disable)
+	twoFactor.POST("/recovery-codes", twoFactorController.RegenerateRecoveryCodes)
+}
+
+func CreateOidcRoutes(apiGroup *gin.RouterGroup, oidcController controller.OidcController) {
+	oidc := apiGroup.Group("/auth/oidc")
+
+	oidc.GET("/providers", oidcController.GetProviders)
+	oidc.GET("/:provider/signin", oidcController.SignIn)
+	oidc.GET("/:provider/link", auth.RequiredSession(), oidcController.Link)
+	oidc.GET("/:provider/callback", oidcController.Callback)
+
+	identities := apiGroup.Group("/users/identities", auth.RequiredSession())
+
+	identities.GET("", oidcController.GetIdentities)
+	identities.DELETE("/:id", oidcController.Unlink)
+}
+
+func CreatePersonalTokensRoutes(apiGroup *gin.RouterGroup, personalTokensController controller.PersonalTokensController) {
+	tokens := apiGroup.Group("/users/tokens", auth.RequiredSession())
+
+	tokens.GET("/", personalTokensController.GetAll)
+	tokens.POST("/", personalTokensController.Create)
+	tokens.DELETE("/:id", personalTokensController.Revoke)
+}
+
+func CreateKeysRoutes(apiGroup *gin.RouterGroup, keysController controller.KeysController) {
+	apiGroup.GET("/.well-known/jwks.json", keysController.GetJSONWebKeySet)
+}
+
+func CreateNotificationsRoutes(apiGroup *gin.RouterGroup, notificationsController controller.NotificationsController) {
+	notifications := apiGroup.Group("/notifications", auth.Required())
+
+	notifications.GET("/", notificationsController.GetAll)
+	notifications.GET("/unread-count", notificationsController.CountUnread)
+	notifications.GET("/stream", notificationsController.Stream)
+
+	notifications.POST("/read", notificationsController.MarkAllRead)
+	notifications.POST("/:id/read", notificationsController.MarkRead)
+
+	notifications.GET("/preferences", notificationsController.GetPreferences)
+	notifications.PUT("/preferences", notificationsController.SetPreferences)
+}
+
+func CreateMediaRoutes(apiGroup *gin.RouterGroup, mediaController controller.MediaController, limiter *ratelimit.Limiter) {
+	media := apiGroup.Group("/media")
+
+	media.GET("/:id", auth.Optional(), mediaController.GetById)
+	media.GET("/", auth.Required(), mediaController.GetAll)
+
+	writable := media.Group("", auth.RequiredScope(auth.ScopeWriteArticles))
+
+	writable.POST("/", limiter.PerIP(uploadLimit), mediaController.Upload)
+	writable.DELETE("/:id", mediaController.Delete)
+}
 
-	users.GET("/for-you", articlesController.ForYou)
+// serves files of the local storage
+func CreateUploadsRoutes(apiGroup *gin.RouterGroup, directory string) {
+	apiGroup.Static("/uploads", directory)
 }
@@ -0,0 +1,74 @@
+package scheduler
+
+import (
+	"log"
+	"time"
+
+	"github.com/danielblagy/blog-webapp-server/service"
+)
+
+// Publishes scheduled articles every interval, blocks forever, so it's meant to be run in a goroutine.
+// It's safe to run in multiple server instances at once, see ArticlesService.PublishScheduled.
+func PublishScheduledArticles(articlesService service.ArticlesService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		articles, err := articlesService.PublishScheduled()
+		if err != nil {
+			log.Printf("Failed to publish scheduled articles: %s", err.Error())
+			continue
+		}
+
+		for _, article := range articles {
+			log.Printf("Published scheduled article %d", article.Id)
+		}
+	}
+}
+
+// Deletes uploaded media that are not used in any article for longer than maxAge, every interval,
+// blocks forever, so it's meant to be run in a goroutine.
+func DeleteOrphanedMedia(mediaService service.MediaService, interval time.Duration, maxAge time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		count, err := mediaService.DeleteOrphans(time.Now().Add(-maxAge))
+		if err != nil {
+			log.Printf("Failed to delete orphaned media: %s", err.Error())
+			continue
+		}
+
+		if count > 0 {
+			log.Printf("Deleted %d orphaned media", count)
+		}
+	}
+}
+
+// Makes the variants of uploaded images right after they are uploaded to this server instance,
+// and every interval for the ones uploaded to other instances or left unfinished.
+// Blocks forever, so it's meant to be run in a goroutine.
+func ProcessMedia(mediaService service.MediaService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-mediaService.Pending():
+		}
+
+		// one at a time, decoded photos take a lot of memory
+		for {
+			count, err := mediaService.ProcessPending(1)
+			if err != nil {
+				log.Printf("Failed to process media: %s", err.Error())
+				break
+			}
+
+			if count == 0 {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danielblagy/blog-webapp-server/auth"
+	"github.com/danielblagy/blog-webapp-server/entity"
+	"github.com/danielblagy/blog-webapp-server/mail"
+	"gorm.io/gorm"
+)
+
+const (
+	emailVerificationTokenDuration = time.Hour * 24
+	passwordResetTokenDuration     = time.Hour
+)
+
+var ErrInvalidAccountToken = errors.New("invalid or expired token")
+
+// Email verification and password reset. Tokens are sent by email as links to the site,
+// the site pages send the tokens back to the API.
+type AccountService interface {
+	SendEmailVerification(user entity.User) error
+	VerifyEmail(token string) (entity.User, error)
+	SendPasswordReset(email string) error
+	ResetPassword(token string, password string) (entity.User, error)
+}
+
+type AccountServiceProvider struct {
+	database     *gorm.DB
+	usersService UsersService
+	sender       mail.Sender
+	siteUrl      string // the base of the links
+}
+
+func CreateAccountService(database *gorm.DB, usersService UsersService, sender mail.Sender, siteUrl string) AccountService {
+	return &AccountServiceProvider{
+		database:     database,
+		usersService: usersService,
+		sender:       sender,
+		siteUrl:      siteUrl,
+	}
+}
+
+// Reads SITE_URL env variable, the base of the links sent by email. The links carry the tokens,
+// so they are never made from the request, whose Host header the client controls.
+func SiteUrlFromEnv() (string, error) {
+	value := strings.TrimSuffix(os.Getenv("SITE_URL"), "/")
+	parsed, err := url.Parse(value)
+	if value == "" || err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", errors.New("SITE_URL must be set to the absolute http(s) url of the site")
+	}
+
+	return value, nil
+}
+
+// lowercases a valid email address, e.g. " John@Example.com" -> "john@example.com"
+func NormalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	address, err := mail.ParseAddress(email)
+	if err != nil || address != email || len(address) > 320 {
+		return "", errors.New("invalid email address")
+	}
+	return strings.ToLower(address), nil
+}
+
+// creates a signed token and records its id, so it can be used only once
+func issueAccountToken(database *gorm.DB, user entity.User, purpose string, duration time.Duration) (string, error) {
+	email := ""
+	if user.Email != nil {
+		email = *user.Email
+	}
+
+	expiresAt := time.Now().Add(duration)
+	token, claims, err := auth.GenerateAccountToken(user.Id, purpose, email, expiresAt)
+	if err != nil {
+		return "", err
+	}
+
+	record := entity.AccountToken{TokenId: claims.Id, UserId: user.Id, Purpose: purpose, ExpiresAt: expiresAt}
+	if result := database.Create(&record); result.Error != nil {
+		return "", result.Error
+	}
+
+	return token, nil
+}
+
+// Checks the token and marks it as used together with all other tokens issued to the user for the purpose.
+// Returns the user the token was issued to, the token is invalid if the email was changed since.
+func (service *AccountServiceProvider) consumeToken(token string, purpose string) (entity.User, error) {
+	claims, err := auth.ParseAccountToken(token, purpose)
+	if err != nil {
+		return entity.User{}, ErrInvalidAccountToken
+	}
+
+	var record entity.AccountToken
+	if result := service.database.Where("token_id = ? and purpose = ?", claims.Id, purpose).First(&record); result.Error != nil {
+		return entity.User{}, ErrInvalidAccountToken
+	}
+
+	// marking the token only if it's unused guards against using it twice concurrently
+	result := service.database.Model(&record).Where("used_at is null").Update("used_at", time.Now())
+	if result.Error != nil {
+		return entity.User{}, result.Error
+	}
+
+	if result.RowsAffected == 0 || strconv.Itoa(record.UserId) != claims.Subject {
+		return entity.User{}, ErrInvalidAccountToken
+	}
+
+	// tokens sent before this one can't be used anymore
+	result = service.database.Model(&entity.AccountToken{}).
+		Where("user_id = ? and purpose = ? and used_at is null", record.UserId, purpose).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return entity.User{}, result.Error
+	}
+
+	user, err := service.usersService.GetAccount(strconv.Itoa(record.UserId))
+	if err != nil || user.Email == nil || *user.Email != claims.Email {
+		return entity.User{}, ErrInvalidAccountToken
+	}
+
+	return user, nil
+}
+
+func (service *AccountServiceProvider) SendEmailVerification(user entity.User) error {
+	if user.Email == nil {
+		return errors.New("user has no email")
+	}
+
+	if user.EmailVerified {
+		return errors.New("email is already verified")
+	}
+
+	token, err := issueAccountToken(service.database, user, auth.PurposeVerifyEmail, emailVerificationTokenDuration)
+	if err != nil {
+		return err
+	}
+
+	return service.sender.Send(mail.Message{
+		To:      *user.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Hi %s,\n\nfollow the link to verify your email:\n%s/verify-email?token=%s\n\nThe link expires in 24 hours.\n",
+			user.FullName, service.siteUrl, token),
+	})
+}
+
+func (service *AccountServiceProvider) VerifyEmail(token string) (entity.User, error) {
+	user, err := service.consumeToken(token, auth.PurposeVerifyEmail)
+	if err != nil {
+		return user, err
+	}
+
+	user.EmailVerified = true
+	result := service.database.Model(&user).Update("email_verified", true)
+	return user, result.Error
+}
+
+// doesn't tell whether a user with the email exists, the mail is only sent if it does
+func (service *AccountServiceProvider) SendPasswordReset(email string) error {
+	user, err := service.usersService.GetByEmail(email)
+	if err != nil {
+		return nil
+	}
+
+	token, err := issueAccountToken(service.database, user, auth.PurposeResetPassword, passwordResetTokenDuration)
+	if err != nil {
+		return err
+	}
+
+	err = service.sender.Send(mail.Message{
+		To:      *user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nfollow the link to set a new password for %s:\n%s/reset-password?token=%s\n\nThe link expires in 1 hour. If you didn't request a password reset, ignore this email.\n",
+			user.FullName, user.Login, service.siteUrl, token),
+	})
+	if err != nil {
+		// not reported to the client, it would tell that the user exists
+		log.Printf("Failed to send password reset email to user %d: %s", user.Id, err.Error())
+	}
+
+	return nil
+}
+
+// sets the new password, the email is verified as well since the token was received by it
+func (service *AccountServiceProvider) ResetPassword(token string, password string) (entity.User, error) {
+	user, err := service.consumeToken(token, auth.PurposeResetPassword)
+	if err != nil {
+		return user, err
+	}
+
+	user, err = service.usersService.Update(strconv.Itoa(user.Id), entity.EditableUserData{Password: password})
+	if err != nil {
+		return user, err
+	}
+
+	user.EmailVerified = true
+	result := service.database.Model(&user).Update("email_verified", true)
+	return user, result.Error
+}
@@ -2,38 +2,66 @@ package service
 
 import (
 	"errors"
+	"log"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/danielblagy/blog-webapp-server/auth"
 	"github.com/danielblagy/blog-webapp-server/entity"
+	"github.com/danielblagy/blog-webapp-server/events"
+	"github.com/danielblagy/blog-webapp-server/markdown"
+	"github.com/danielblagy/blog-webapp-server/pagination"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ArticlesService interface {
-	LoadAssociatedData(*entity.Article) error
-	GetAll() ([]entity.Article, error)
-	GetById(id string, userId string) (entity.Article, error)
-	GetByTitle(authorId string, title string) (entity.Article, error)
+	LoadAssociatedData(article *entity.Article, viewer auth.Principal) error
+	GetAll(tag string, params pagination.Params, viewer auth.Principal) ([]entity.Article, pagination.Cursors, error)
+	GetById(id string, viewer auth.Principal) (entity.Article, error)
+	GetBySlug(authorId int, slug string, viewer auth.Principal) (entity.Article, error)
+	GetByPreviousSlug(authorId int, slug string, viewer auth.Principal) (entity.Article, error)
 	Create(article entity.Article) (entity.Article, error)
 	Update(id string, updatedData entity.EditableArticleData) (entity.Article, error)
 	Delete(id string) (entity.Article, error)
 	Save(userId string, articleToSave string) error
 	Unsave(userId string, articleToUnsave string) error
-	GetSaves(userId string) ([]entity.Article, error)
+	GetSaves(viewer auth.Principal, unreadOnly bool, params pagination.Params) ([]entity.SavedArticle, pagination.Cursors, error)
+	UpdateSave(userId string, articleId string, updatedData entity.EditableSaveData) (entity.Save, error)
 	IsSaved(userId string, articleId string) (bool, error)
-	ForYou(userId string) ([]entity.Article, error)
+	ForYou(viewer auth.Principal, params pagination.Params) ([]entity.Article, pagination.Cursors, error)
+	Search(query string, viewer auth.Principal, limit int) ([]entity.ArticleSearchResult, error)
+	PublishScheduled() ([]entity.Article, error)
+	Unpublish(id string) (entity.Article, error)
 }
 
 type ArticlesServiceProvider struct {
-	database *gorm.DB
+	database         *gorm.DB
+	tagsService      TagsService
+	revisionsService RevisionsService
+	reactionsService ReactionsService
+	mediaService     MediaService
+	bus              *events.Bus
 }
 
-func CreateArticlesService(database *gorm.DB) ArticlesService {
+func CreateArticlesService(database *gorm.DB, tagsService TagsService, revisionsService RevisionsService, reactionsService ReactionsService, mediaService MediaService, bus *events.Bus) ArticlesService {
 	return &ArticlesServiceProvider{
-		database: database,
+		database:         database,
+		tagsService:      tagsService,
+		revisionsService: revisionsService,
+		reactionsService: reactionsService,
+		mediaService:     mediaService,
+		bus:              bus,
 	}
 }
 
-func (service *ArticlesServiceProvider) LoadAssociatedData(article *entity.Article) error {
+func (service *ArticlesServiceProvider) publishEvent(eventType string, actorId int, articleId int) {
+	service.bus.Publish(events.Event{Type: eventType, ActorId: actorId, ArticleId: articleId})
+}
+
+// the viewer's own reactions are loaded if the viewer is signed in
+func (service *ArticlesServiceProvider) LoadAssociatedData(article *entity.Article, viewer auth.Principal) error {
 	// NOTE: user's associeated data will not be loaded
 	// loading article.author
 	result := service.database.Where("id = ?", article.AuthorId).First(&article.Author)
@@ -49,55 +77,203 @@ func (service *ArticlesServiceProvider) LoadAssociatedData(article *entity.Artic
 	}
 	article.Saves = int(count)
 
+	// loading article.reactions and article.my_reactions
+	reactions, err := service.reactionsService.GetCounts(article.Id)
+	if err != nil {
+		return errors.New("failed to load associated data")
+	}
+	article.Reactions = reactions
+
+	if !viewer.IsAnonymous() {
+		myReactions, err := service.reactionsService.GetUserReactions(article.Id, viewer.UserId)
+		if err != nil {
+			return errors.New("failed to load associated data")
+		}
+		article.MyReactions = myReactions
+	}
+
+	// loading article.tags
+	tags, err := service.tagsService.GetArticleTags(article.Id)
+	if err != nil {
+		return errors.New("failed to load associated data")
+	}
+	article.Tags = tags
+
+	// loading article.media and article.media_ids
+	media, err := service.mediaService.GetByArticle(article.Id)
+	if err != nil {
+		return errors.New("failed to load associated data")
+	}
+	article.Media = media
+	article.MediaIds = make([]int, len(media))
+	for i := range media {
+		article.MediaIds[i] = media[i].Id
+	}
+
+	// rendering article.html
+	html, err := markdown.Render(article.Format, article.Content)
+	if err != nil {
+		return errors.New("failed to load associated data")
+	}
+	article.Html = html
+
 	return nil
 }
 
-func (service *ArticlesServiceProvider) GetAll() ([]entity.Article, error) {
-	var articles []entity.Article
-	result := service.database.Where("published = true").Find(&articles)
+// if tag is not empty, only the articles with that tag will be returned
+func (service *ArticlesServiceProvider) GetAll(tag string, params pagination.Params, viewer auth.Principal) ([]entity.Article, pagination.Cursors, error) {
+	query := service.database.Where("published = true")
+	if tag != "" {
+		taggedArticlesIds := service.database.Table("article_tags").
+			Select("article_tags.article_id").
+			Joins("join tags on tags.id = article_tags.tag_id").
+			Where("tags.name = ?", NormalizeTag(tag))
+		query = query.Where("id in (?)", taggedArticlesIds)
+	}
+
+	return service.getPage(query, params, viewer)
+}
+
+// fetches the page of articles matching the query and loads their associated data
+func (service *ArticlesServiceProvider) getPage(query *gorm.DB, params pagination.Params, viewer auth.Principal) ([]entity.Article, pagination.Cursors, error) {
+	articles := []entity.Article{}
+	result := pagination.Apply(query, params, "created_at", "id").Find(&articles)
+	if result.Error != nil {
+		return articles, pagination.Cursors{}, result.Error
+	}
+
+	hasMore := pagination.Trim(params, &articles)
 
 	// associated data
 	for i := range articles {
-		if err := service.LoadAssociatedData(&articles[i]); err != nil {
-			return articles, err
+		if err := service.LoadAssociatedData(&articles[i], viewer); err != nil {
+			return articles, pagination.Cursors{}, err
 		}
 	}
 
-	return articles, result.Error
+	cursors := pagination.NewCursors(params, hasMore, len(articles), func(i int) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: articles[i].CreatedAt, Id: articles[i].Id}
+	})
+
+	return articles, cursors, nil
 }
 
-func (service *ArticlesServiceProvider) GetById(id string, userId string) (entity.Article, error) {
+// private articles are only visible to their authors
+func (service *ArticlesServiceProvider) GetById(id string, viewer auth.Principal) (entity.Article, error) {
 	var article entity.Article
 
-	if userId == "-1" {
+	if viewer.IsAnonymous() {
 		result := service.database.Where("published = true").First(&article, id)
-		service.database.Where("id = ?", article.AuthorId).Find(&article.Author)
-		return article, result.Error
+		if result.Error != nil {
+			return article, result.Error
+		}
+
+		if err := service.LoadAssociatedData(&article, viewer); err != nil {
+			return article, err
+		}
+
+		return article, nil
 	}
 
 	result := service.database.First(&article, id)
 
-	if article.Published == false && strconv.Itoa(article.AuthorId) != userId {
+	if article.Published == false && article.AuthorId != viewer.UserId {
 		return entity.Article{}, errors.New("article is private")
 	}
 
-	if err := service.LoadAssociatedData(&article); err != nil {
+	if err := service.LoadAssociatedData(&article, viewer); err != nil {
 		return article, err
 	}
 
 	return article, result.Error
 }
 
-func (service *ArticlesServiceProvider) GetByTitle(authorId string, title string) (entity.Article, error) {
+// the article must be visible to the viewer like in GetById
+func (service *ArticlesServiceProvider) GetBySlug(authorId int, slug string, viewer auth.Principal) (entity.Article, error) {
 	var article entity.Article
-	result := service.database.Where("author_id = ? and title = ?", authorId, title).First(&article)
-	return article, result.Error
+	result := service.database.Where("author_id = ? and slug = ?", authorId, slug).Limit(1).Find(&article)
+	if result.Error != nil {
+		return article, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return article, errors.New("article was not found")
+	}
+
+	return service.GetById(strconv.Itoa(article.Id), viewer)
+}
+
+// finds the article that had the slug before its title was changed
+func (service *ArticlesServiceProvider) GetByPreviousSlug(authorId int, slug string, viewer auth.Principal) (entity.Article, error) {
+	var previous entity.ArticleSlug
+	result := service.database.Where("author_id = ? and slug = ?", authorId, slug).Limit(1).Find(&previous)
+	if result.Error != nil {
+		return entity.Article{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return entity.Article{}, errors.New("article was not found")
+	}
+
+	return service.GetById(strconv.Itoa(previous.ArticleId), viewer)
 }
 
+// articles scheduled for the future stay private until the scheduler publishes them,
+// scheduling for a moment in the past publishes the article right away
+func applySchedule(article *entity.Article) {
+	if article.PublishAt == nil {
+		return
+	}
+
+	if article.PublishAt.After(time.Now()) {
+		article.Published = false
+	} else {
+		article.Published = true
+		article.PublishAt = nil
+	}
+}
+
+// titles are unique for the author, the slug is made from the title
 func (service *ArticlesServiceProvider) Create(article entity.Article) (entity.Article, error) {
-	result := service.database.Create(&article)
+	applySchedule(&article)
+
+	// a concurrent request can take the slug before the article is inserted, the slug is picked again then
+	var result *gorm.DB
+	for attempt := 1; ; attempt++ {
+		slug, err := uniqueSlug(service.database, article.AuthorId, 0, article.Title)
+		if err != nil {
+			return article, err
+		}
+		article.Slug = slug
+
+		// links with the slug led to another article before, now they lead to this one
+		if result := service.database.Where("author_id = ? and slug = ?", article.AuthorId, slug).Delete(&entity.ArticleSlug{}); result.Error != nil {
+			return article, result.Error
+		}
+
+		result = service.database.Create(&article)
+		if !isUniqueViolation(result.Error, articleSlugIndex) || attempt == maxSlugAttempts {
+			break
+		}
+	}
+	if result.Error != nil {
+		if isUniqueViolation(result.Error, articleTitleIndex) {
+			return article, errors.New("user already has article with this title")
+		}
+		return article, result.Error
+	}
+
+	if err := service.tagsService.SetArticleTags(article.Id, article.Tags); err != nil {
+		return article, err
+	}
+
+	if err := service.mediaService.SetArticleMedia(article.Id, article.MediaIds); err != nil {
+		return article, err
+	}
+
+	if article.Published && service.markFirstPublished(&article) {
+		service.publishEvent(events.TypePublish, article.AuthorId, article.Id)
+	}
 
-	if err := service.LoadAssociatedData(&article); err != nil {
+	if err := service.LoadAssociatedData(&article, auth.Principal{}); err != nil {
 		return article, err
 	}
 
@@ -108,6 +284,8 @@ func (service *ArticlesServiceProvider) Update(id string, updatedData entity.Edi
 	var article entity.Article
 	service.database.Find(&article, id)
 
+	previous := article
+
 	if updatedData.Title != "" {
 		article.Title = updatedData.Title
 	}
@@ -116,29 +294,127 @@ func (service *ArticlesServiceProvider) Update(id string, updatedData entity.Edi
 		article.Content = updatedData.Content
 	}
 
+	if updatedData.Format != "" {
+		article.Format = updatedData.Format
+	}
+
 	if updatedData.Published != article.Published {
 		article.Published = updatedData.Published
 	}
 
-	result := service.database.Save(&article)
+	// publishing right away cancels the schedule
+	if updatedData.PublishAt.Set {
+		article.PublishAt = updatedData.PublishAt.Value
+	} else if article.Published {
+		article.PublishAt = nil
+	}
+	applySchedule(&article)
+
+	// a new title gets a new slug, the previous one redirects to the article,
+	// the slug is picked again if a concurrent request takes it first
+	var err error
+	for attempt := 1; ; attempt++ {
+		article.Slug = previous.Slug
+		err = service.database.Transaction(func(tx *gorm.DB) error {
+			// published_at is only set by markFirstPublished
+			if result := tx.Omit("PublishedAt").Save(&article); result.Error != nil {
+				return result.Error
+			}
+
+			if previous.Title != article.Title {
+				return setArticleSlug(tx, &article)
+			}
+			return nil
+		})
+		if !isUniqueViolation(err, articleSlugIndex) || attempt == maxSlugAttempts {
+			break
+		}
+	}
+	if err != nil {
+		if isUniqueViolation(err, articleTitleIndex) {
+			return previous, errors.New("user already has article with this title")
+		}
+		return previous, err
+	}
+
+	// keep the previous version of the text in the article's history
+	if previous.Title != article.Title || previous.Content != article.Content || previous.Format != article.Format {
+		if _, err := service.revisionsService.Create(previous); err != nil {
+			return article, err
+		}
+	}
+
+	if !previous.Published && article.Published && service.markFirstPublished(&article) {
+		service.publishEvent(events.TypePublish, article.AuthorId, article.Id)
+	}
+
+	if updatedData.Tags != nil {
+		if err := service.tagsService.SetArticleTags(article.Id, updatedData.Tags); err != nil {
+			return article, err
+		}
+	}
+
+	if updatedData.MediaIds != nil {
+		if err := service.mediaService.SetArticleMedia(article.Id, updatedData.MediaIds); err != nil {
+			return article, err
+		}
+	}
 
-	if err := service.LoadAssociatedData(&article); err != nil {
+	if err := service.LoadAssociatedData(&article, auth.Principal{}); err != nil {
 		return article, err
 	}
 
-	return article, result.Error
+	return article, nil
 }
 
 func (service *ArticlesServiceProvider) Delete(id string) (entity.Article, error) {
 	//article, _ := service.GetById(id)
 	// getting the article before deleting to return
 	var article entity.Article
-	service.database.First(&article, id)
+	if result := service.database.First(&article, id); result.Error != nil {
+		return article, result.Error
+	}
+
+	if err := service.LoadAssociatedData(&article, auth.Principal{}); err != nil {
+		return article, err
+	}
+
+	if err := service.tagsService.SetArticleTags(article.Id, nil); err != nil {
+		return article, err
+	}
 
-	if err := service.LoadAssociatedData(&article); err != nil {
+	if result := service.database.Where("article_id = ?", id).Delete(&entity.Comment{}); result.Error != nil {
+		return article, result.Error
+	}
+
+	if err := service.revisionsService.DeleteByArticle(id); err != nil {
 		return article, err
 	}
 
+	if err := service.reactionsService.DeleteByArticle(id); err != nil {
+		return article, err
+	}
+
+	if err := service.mediaService.DeleteByArticle(id); err != nil {
+		return article, err
+	}
+
+	if result := service.database.Where("article_id = ?", id).Delete(&entity.Notification{}); result.Error != nil {
+		return article, result.Error
+	}
+
+	if result := service.database.Where("article_id = ?", id).Delete(&entity.SeriesEntry{}); result.Error != nil {
+		return article, result.Error
+	}
+
+	if result := service.database.Where("article_id = ?", id).Delete(&entity.ArticleSlug{}); result.Error != nil {
+		return article, result.Error
+	}
+
+	if result := service.database.Where("article_id = ?", id).Delete(&entity.CollectionItem{}); result.Error != nil {
+		return article, result.Error
+	}
+
 	result := service.database.Delete(&entity.Article{}, id)
 	return article, result.Error
 }
@@ -155,29 +431,99 @@ func (service *ArticlesServiceProvider) Save(userId string, articleToSave string
 	}
 
 	result := service.database.Create(&entity.Save{UserId: iUserId, ArticleId: iArticleToSave})
-	return result.Error
+	if result.Error != nil {
+		return result.Error
+	}
+
+	service.publishEvent(events.TypeSave, iUserId, iArticleToSave)
+	return nil
 }
 
+// the article is also removed from the user's collections
 func (service *ArticlesServiceProvider) Unsave(userId string, articleToUnsave string) error {
-	result := service.database.Where("user_id = ? and article_id = ?", userId, articleToUnsave).Delete(&entity.Save{})
-	return result.Error
+	return service.database.Transaction(func(tx *gorm.DB) error {
+		userCollectionsIds := tx.Model(&entity.Collection{}).Where("owner_id = ?", userId).Select("id")
+		result := tx.Where("article_id = ? and collection_id in (?)", articleToUnsave, userCollectionsIds).Delete(&entity.CollectionItem{})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Where("user_id = ? and article_id = ?", userId, articleToUnsave).Delete(&entity.Save{}).Error
+	})
 }
 
-func (service *ArticlesServiceProvider) GetSaves(userId string) ([]entity.Article, error) {
-	var savedArticlesIds []int
-	result := service.database.Table("saves").Where("user_id = ?", userId).Select("article_id").Find(&savedArticlesIds)
+// the newest saved articles first, with the viewer's notes and read states
+func (service *ArticlesServiceProvider) GetSaves(viewer auth.Principal, unreadOnly bool, params pagination.Params) ([]entity.SavedArticle, pagination.Cursors, error) {
+	savedArticlesIds := service.database.Table("saves").Where("user_id = ?", viewer.UserId).Select("article_id")
+	if unreadOnly {
+		savedArticlesIds = savedArticlesIds.Where("read = false")
+	}
 
-	var articles []entity.Article
-	result = service.database.Where("id in ? and published = true", savedArticlesIds).Find(&articles)
+	query := service.database.Where("id in (?) and published = true", savedArticlesIds)
+	articles, cursors, err := service.getPage(query, params, viewer)
+	if err != nil {
+		return []entity.SavedArticle{}, cursors, err
+	}
+
+	savedArticles, err := withSaves(service.database, viewer.UserId, articles)
+	return savedArticles, cursors, err
+}
+
+// Pairs the articles with the user's saves of them. The note and the read state
+// are left empty for the articles the user hasn't saved.
+func withSaves(database *gorm.DB, userId int, articles []entity.Article) ([]entity.SavedArticle, error) {
+	savedArticles := make([]entity.SavedArticle, len(articles))
+	if len(articles) == 0 {
+		return savedArticles, nil
+	}
+
+	articlesIds := make([]int, len(articles))
+	for i := range articles {
+		articlesIds[i] = articles[i].Id
+	}
+
+	var saves []entity.Save
+	if result := database.Where("user_id = ? and article_id in ?", userId, articlesIds).Find(&saves); result.Error != nil {
+		return savedArticles, result.Error
+	}
+
+	savesByArticle := map[int]entity.Save{}
+	for _, save := range saves {
+		savesByArticle[save.ArticleId] = save
+	}
 
-	// associated data
 	for i := range articles {
-		if err := service.LoadAssociatedData(&articles[i]); err != nil {
-			return articles, err
+		savedArticles[i].Article = articles[i]
+		if save, ok := savesByArticle[articles[i].Id]; ok {
+			savedArticles[i].Note = &save.Note
+			savedArticles[i].Read = &save.Read
 		}
 	}
 
-	return articles, result.Error
+	return savedArticles, nil
+}
+
+func (service *ArticlesServiceProvider) UpdateSave(userId string, articleId string, updatedData entity.EditableSaveData) (entity.Save, error) {
+	var save entity.Save
+	result := service.database.Where("user_id = ? and article_id = ?", userId, articleId).Limit(1).Find(&save)
+	if result.Error != nil {
+		return save, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return save, errors.New("article is not saved")
+	}
+
+	if updatedData.Note != nil {
+		save.Note = *updatedData.Note
+	}
+
+	if updatedData.Read != nil {
+		save.Read = *updatedData.Read
+	}
+
+	result = service.database.Model(&entity.Save{}).Where("user_id = ? and article_id = ?", userId, articleId).
+		Updates(map[string]interface{}{"note": save.Note, "read": save.Read})
+	return save, result.Error
 }
 
 func (service *ArticlesServiceProvider) IsSaved(userId string, articleId string) (bool, error) {
@@ -187,19 +533,131 @@ func (service *ArticlesServiceProvider) IsSaved(userId string, articleId string)
 	return result.RowsAffected > 0, result.Error
 }
 
-func (service *ArticlesServiceProvider) ForYou(userId string) ([]entity.Article, error) {
-	var following []int
-	result := service.database.Table("followers").Where("follower_id = ?", userId).Select("follows_id").Find(&following)
+func (service *ArticlesServiceProvider) ForYou(viewer auth.Principal, params pagination.Params) ([]entity.Article, pagination.Cursors, error) {
+	following := service.database.Table("followers").Where("follower_id = ?", viewer.UserId).Select("follows_id")
+	query := service.database.Where("author_id in (?) and published = true", following)
+	return service.getPage(query, params, viewer)
+}
 
-	var articles []entity.Article
-	result = service.database.Where("author_id in ? and published = true", following).Find(&articles)
+type articleSearchRow struct {
+	entity.Article
+	Rank           float64
+	TitleHighlight string
+	Snippet        string
+}
+
+// full-text search over titles and contents of published articles and the viewer's own drafts,
+// the most relevant articles first
+func (service *ArticlesServiceProvider) Search(query string, viewer auth.Principal, limit int) ([]entity.ArticleSearchResult, error) {
+	var rows []articleSearchRow
+	result := service.database.Raw(`
+		select articles.*, ts_rank(articles.search_vector, search_query) as rank,
+			ts_headline(?::regconfig, articles.title, search_query, ?) as title_highlight,
+			ts_headline(?::regconfig, articles.content, search_query, ?) as snippet
+		from articles, websearch_to_tsquery(?::regconfig, ?) search_query
+		where articles.search_vector @@ search_query and (articles.published = true or articles.author_id = ?)
+		order by rank desc, articles.id desc
+		limit ?`,
+		searchConfig, highlightAllOptions, searchConfig, snippetOptions, searchConfig, query, viewer.UserId, limit).Scan(&rows)
+	if result.Error != nil {
+		return []entity.ArticleSearchResult{}, result.Error
+	}
+
+	results := make([]entity.ArticleSearchResult, len(rows))
+	for i, row := range rows {
+		article := row.Article
+		if err := service.LoadAssociatedData(&article, viewer); err != nil {
+			return []entity.ArticleSearchResult{}, err
+		}
+
+		results[i] = entity.ArticleSearchResult{
+			Article:        article,
+			Rank:           row.Rank,
+			TitleHighlight: highlight(row.TitleHighlight),
+			Snippet:        highlight(row.Snippet),
+		}
+	}
+
+	return results, nil
+}
+
+// Records the first publishing of the article, returns false if the article was published before.
+// Only one of concurrent calls for the article succeeds, so the article's publishing is announced once.
+func (service *ArticlesServiceProvider) markFirstPublished(article *entity.Article) bool {
+	now := time.Now()
+	result := service.database.Model(&entity.Article{}).
+		Where("id = ? and published_at is null", article.Id).
+		UpdateColumn("published_at", now)
+	if result.Error != nil {
+		log.Printf("Failed to record the first publishing of article %d: %s", article.Id, result.Error.Error())
+		return false
+	}
+
+	if result.RowsAffected == 0 {
+		return false
+	}
+
+	article.PublishedAt = &now
+	return true
+}
+
+// publishes all articles whose scheduled time has come and returns them,
+// it's a single update statement, so when several server instances run it at the same time
+// every article is published (and returned) by only one of them
+func (service *ArticlesServiceProvider) PublishScheduled() ([]entity.Article, error) {
+	articles := []entity.Article{}
+	result := service.database.Model(&articles).
+		Clauses(clause.Returning{}).
+		Where("published = false and publish_at <= ?", time.Now()).
+		Updates(map[string]interface{}{"published": true, "publish_at": nil})
+	if result.Error != nil {
+		return articles, result.Error
+	}
 
-	// associated data
 	for i := range articles {
-		if err := service.LoadAssociatedData(&articles[i]); err != nil {
-			return articles, err
+		if service.markFirstPublished(&articles[i]) {
+			service.publishEvent(events.TypePublish, articles[i].AuthorId, articles[i].Id)
 		}
 	}
 
-	return articles, result.Error
+	return articles, nil
+}
+
+// makes the article private and cancels its scheduled publishing
+func (service *ArticlesServiceProvider) Unpublish(id string) (entity.Article, error) {
+	var article entity.Article
+	if result := service.database.First(&article, id); result.Error != nil {
+		return article, result.Error
+	}
+
+	article.Published = false
+	article.PublishAt = nil
+	result := service.database.Model(&article).Updates(map[string]interface{}{"published": false, "publish_at": nil})
+	if result.Error != nil {
+		return article, result.Error
+	}
+
+	if err := service.LoadAssociatedData(&article, auth.Principal{}); err != nil {
+		return article, err
+	}
+
+	return article, nil
+}
+
+// postgres error code of unique constraint violations
+const uniqueViolation = "23505"
+
+// unique indexes of articles
+const (
+	articleTitleIndex = "idx_author_title"
+	articleSlugIndex  = "idx_articles_author_slug"
+)
+
+// concurrent requests can pick the same slug, the slug is generated again this many times
+const maxSlugAttempts = 3
+
+// whether err violates the unique index, postgres names the index in the error message
+func isUniqueViolation(err error, index string) bool {
+	var pgErr interface{ SQLState() string }
+	return errors.As(err, &pgErr) && pgErr.SQLState() == uniqueViolation && strings.Contains(err.Error(), `"`+index+`"`)
 }
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"github.com/danielblagy/blog-webapp-server/entity"
+	"github.com/danielblagy/blog-webapp-server/pagination"
+	"gorm.io/gorm"
+)
+
+const (
+	AuditActionDeleteUser       = "delete_user"
+	AuditActionSuspendUser      = "suspend_user"
+	AuditActionUnsuspendUser    = "unsuspend_user"
+	AuditActionChangeRole       = "change_role"
+	AuditActionUnpublishArticle = "unpublish_article"
+	AuditActionDeleteArticle    = "delete_article"
+)
+
+type AuditService interface {
+	Record(actorId *int, action string, targetType string, targetId int, details string) error
+	GetAll(params pagination.Params) ([]entity.AuditLogEntry, pagination.Cursors, error)
+}
+
+type AuditServiceProvider struct {
+	database *gorm.DB
+}
+
+func CreateAuditService(database *gorm.DB) AuditService {
+	return &AuditServiceProvider{
+		database: database,
+	}
+}
+
+func (service *AuditServiceProvider) Record(actorId *int, action string, targetType string, targetId int, details string) error {
+	result := service.database.Create(&entity.AuditLogEntry{
+		ActorId:    actorId,
+		Action:     action,
+		TargetType: targetType,
+		TargetId:   targetId,
+		Details:    details,
+	})
+	return result.Error
+}
+
+func (service *AuditServiceProvider) GetAll(params pagination.Params) ([]entity.AuditLogEntry, pagination.Cursors, error) {
+	entries := []entity.AuditLogEntry{}
+	result := pagination.Apply(service.database, params, "created_at", "id").Find(&entries)
+	if result.Error != nil {
+		return entries, pagination.Cursors{}, result.Error
+	}
+
+	hasMore := pagination.Trim(params, &entries)
+
+	cursors := pagination.NewCursors(params, hasMore, len(entries), func(i int) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: entries[i].CreatedAt, Id: entries[i].Id}
+	})
+
+	return entries, cursors, nil
+}
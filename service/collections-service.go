@@ -0,0 +1,414 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/danielblagy/blog-webapp-server/auth"
+	"github.com/danielblagy/blog-webapp-server/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxCollectionNameLength        = 100
+	maxCollectionDescriptionLength = 1000
+	maxCollectionSize              = 500
+	maxSaveNoteLength              = 2000
+)
+
+type CollectionsService interface {
+	GetById(id int, viewer auth.Principal) (entity.Collection, error)
+	GetByOwner(ownerId string, viewer auth.Principal) ([]entity.Collection, error)
+	GetFollowing(userId int) ([]entity.Collection, error)
+	Create(collection entity.Collection) (entity.Collection, error)
+	Update(id int, updatedData entity.EditableCollectionData) (entity.Collection, error)
+	Delete(id int) (entity.Collection, error)
+	AddArticle(id int, articleId int) (entity.Collection, error)
+	RemoveArticle(id int, articleId int) (entity.Collection, error)
+	Reorder(id int, articleIds []int) (entity.Collection, error)
+	Follow(id int, userId int) error
+	Unfollow(id int, userId int) error
+}
+
+type CollectionsServiceProvider struct {
+	database        *gorm.DB
+	articlesService ArticlesService
+}
+
+func CreateCollectionsService(database *gorm.DB, articlesService ArticlesService) CollectionsService {
+	return &CollectionsServiceProvider{
+		database:        database,
+		articlesService: articlesService,
+	}
+}
+
+// checks the name and the description length
+func IsValidCollectionData(name string, description string) bool {
+	return name != "" && len([]rune(name)) <= maxCollectionNameLength && len([]rune(description)) <= maxCollectionDescriptionLength
+}
+
+func IsValidSaveNote(note string) bool {
+	return len([]rune(note)) <= maxSaveNoteLength
+}
+
+// private collections are only visible to their owners
+func canView(collection entity.Collection, viewer auth.Principal) bool {
+	return collection.Public || viewer.UserId == collection.OwnerId
+}
+
+// the articles of the collection the viewer can see, the owner also sees own drafts
+func visibleItems(database *gorm.DB, collection entity.Collection, viewer auth.Principal) *gorm.DB {
+	query := database.Table("collection_items").
+		Joins("join articles on articles.id = collection_items.article_id").
+		Where("collection_items.collection_id = ?", collection.Id)
+
+	if viewer.UserId == collection.OwnerId {
+		return query.Where("articles.published = true or articles.author_id = ?", collection.OwnerId)
+	}
+	return query.Where("articles.published = true")
+}
+
+// articles are only loaded if withArticles is set, notes and read states only for the owner
+func (service *CollectionsServiceProvider) loadAssociatedData(collection *entity.Collection, viewer auth.Principal, withArticles bool) error {
+	// NOTE: owner's associated data will not be loaded
+	if result := service.database.Where("id = ?", collection.OwnerId).First(&collection.Owner); result.Error != nil {
+		return errors.New("failed to load associated data")
+	}
+
+	var count int64
+	if result := service.database.Model(&entity.CollectionFollower{}).Where("collection_id = ?", collection.Id).Count(&count); result.Error != nil {
+		return errors.New("failed to load associated data")
+	}
+	collection.Followers = int(count)
+
+	if !viewer.IsAnonymous() {
+		result := service.database.Model(&entity.CollectionFollower{}).
+			Where("collection_id = ? and user_id = ?", collection.Id, viewer.UserId).Count(&count)
+		if result.Error != nil {
+			return errors.New("failed to load associated data")
+		}
+		collection.Following = count > 0
+	}
+
+	if result := visibleItems(service.database, *collection, viewer).Count(&count); result.Error != nil {
+		return errors.New("failed to load associated data")
+	}
+	collection.Size = int(count)
+
+	if withArticles {
+		articles, err := service.getArticles(*collection, viewer)
+		if err != nil {
+			return errors.New("failed to load associated data")
+		}
+		collection.Articles = articles
+	}
+
+	return nil
+}
+
+func (service *CollectionsServiceProvider) getArticles(collection entity.Collection, viewer auth.Principal) ([]entity.SavedArticle, error) {
+	articles := []entity.Article{}
+	result := visibleItems(service.database, collection, viewer).
+		Select("articles.*").
+		Order("collection_items.position").
+		Find(&articles)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	for i := range articles {
+		if err := service.articlesService.LoadAssociatedData(&articles[i], viewer); err != nil {
+			return nil, err
+		}
+	}
+
+	if viewer.UserId == collection.OwnerId {
+		return withSaves(service.database, collection.OwnerId, articles)
+	}
+
+	savedArticles := make([]entity.SavedArticle, len(articles))
+	for i := range articles {
+		savedArticles[i].Article = articles[i]
+	}
+	return savedArticles, nil
+}
+
+func (service *CollectionsServiceProvider) GetById(id int, viewer auth.Principal) (entity.Collection, error) {
+	var collection entity.Collection
+	if result := service.database.First(&collection, id); result.Error != nil {
+		return collection, result.Error
+	}
+
+	if !canView(collection, viewer) {
+		return entity.Collection{}, errors.New("collection is private")
+	}
+
+	if err := service.loadAssociatedData(&collection, viewer, true); err != nil {
+		return collection, err
+	}
+
+	return collection, nil
+}
+
+// the newest first, private collections are only listed for the owner
+func (service *CollectionsServiceProvider) GetByOwner(ownerId string, viewer auth.Principal) ([]entity.Collection, error) {
+	query := service.database.Where("owner_id = ?", ownerId)
+	if strconv.Itoa(viewer.UserId) != ownerId {
+		query = query.Where("public = true")
+	}
+
+	collections := []entity.Collection{}
+	if result := query.Order("created_at desc, id desc").Find(&collections); result.Error != nil {
+		return collections, result.Error
+	}
+
+	for i := range collections {
+		if err := service.loadAssociatedData(&collections[i], viewer, false); err != nil {
+			return collections, err
+		}
+	}
+
+	return collections, nil
+}
+
+// the public collections the user follows, the recently updated first
+func (service *CollectionsServiceProvider) GetFollowing(userId int) ([]entity.Collection, error) {
+	collections := []entity.Collection{}
+	result := service.database.
+		Joins("join collection_followers on collection_followers.collection_id = collections.id").
+		Where("collection_followers.user_id = ? and collections.public = true", userId).
+		Order("collections.updated_at desc, collections.id desc").
+		Find(&collections)
+	if result.Error != nil {
+		return collections, result.Error
+	}
+
+	viewer := auth.Principal{UserId: userId}
+	for i := range collections {
+		if err := service.loadAssociatedData(&collections[i], viewer, false); err != nil {
+			return collections, err
+		}
+	}
+
+	return collections, nil
+}
+
+func (service *CollectionsServiceProvider) Create(collection entity.Collection) (entity.Collection, error) {
+	if result := service.database.Create(&collection); result.Error != nil {
+		return collection, result.Error
+	}
+
+	return service.reload(collection.Id)
+}
+
+// followers of a collection made private are kept, it's listed for them again once it's public
+func (service *CollectionsServiceProvider) Update(id int, updatedData entity.EditableCollectionData) (entity.Collection, error) {
+	var collection entity.Collection
+	if result := service.database.First(&collection, id); result.Error != nil {
+		return collection, result.Error
+	}
+
+	if updatedData.Name != "" {
+		collection.Name = updatedData.Name
+	}
+
+	if updatedData.Description != nil {
+		collection.Description = *updatedData.Description
+	}
+
+	if updatedData.Public != nil {
+		collection.Public = *updatedData.Public
+	}
+
+	if result := service.database.Save(&collection); result.Error != nil {
+		return collection, result.Error
+	}
+
+	return service.reload(collection.Id)
+}
+
+// the articles stay saved, only the collection is deleted
+func (service *CollectionsServiceProvider) Delete(id int) (entity.Collection, error) {
+	collection, err := service.reload(id)
+	if err != nil {
+		return collection, err
+	}
+
+	err = service.database.Transaction(func(tx *gorm.DB) error {
+		if result := tx.Where("collection_id = ?", collection.Id).Delete(&entity.CollectionItem{}); result.Error != nil {
+			return result.Error
+		}
+		if result := tx.Where("collection_id = ?", collection.Id).Delete(&entity.CollectionFollower{}); result.Error != nil {
+			return result.Error
+		}
+		return tx.Delete(&entity.Collection{}, collection.Id).Error
+	})
+
+	return collection, err
+}
+
+// adds the article to the end of the collection, the caller makes sure the owner has saved the article
+func (service *CollectionsServiceProvider) AddArticle(id int, articleId int) (entity.Collection, error) {
+	var collection entity.Collection
+	if result := service.database.First(&collection, id); result.Error != nil {
+		return collection, result.Error
+	}
+
+	err := service.database.Transaction(func(tx *gorm.DB) error {
+		// lock the collection, so articles added at once get different positions
+		if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&entity.Collection{}, collection.Id); result.Error != nil {
+			return result.Error
+		}
+
+		var count int64
+		if result := tx.Model(&entity.CollectionItem{}).Where("collection_id = ?", collection.Id).Count(&count); result.Error != nil {
+			return result.Error
+		}
+		if count >= maxCollectionSize {
+			return errors.New("collection is full")
+		}
+
+		result := tx.Model(&entity.CollectionItem{}).Where("collection_id = ? and article_id = ?", collection.Id, articleId).Count(&count)
+		if result.Error != nil {
+			return result.Error
+		}
+		if count > 0 {
+			return errors.New("article is already in the collection")
+		}
+
+		var last int
+		result = tx.Model(&entity.CollectionItem{}).Where("collection_id = ?", collection.Id).Select("coalesce(max(position), 0)").Scan(&last)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Create(&entity.CollectionItem{CollectionId: collection.Id, ArticleId: articleId, Position: last + 1}).Error
+	})
+	if err != nil {
+		return collection, err
+	}
+
+	return service.reload(collection.Id)
+}
+
+// the article stays saved
+func (service *CollectionsServiceProvider) RemoveArticle(id int, articleId int) (entity.Collection, error) {
+	result := service.database.Where("collection_id = ? and article_id = ?", id, articleId).Delete(&entity.CollectionItem{})
+	if result.Error != nil {
+		return entity.Collection{}, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return entity.Collection{}, errors.New("article is not in the collection")
+	}
+
+	return service.reload(id)
+}
+
+// Sets the order of the articles, articleIds must have every article of the collection the owner can see once.
+// Articles hidden from the owner, e.g. unpublished by their authors, are moved to the end.
+func (service *CollectionsServiceProvider) Reorder(id int, articleIds []int) (entity.Collection, error) {
+	var collection entity.Collection
+	if result := service.database.First(&collection, id); result.Error != nil {
+		return collection, result.Error
+	}
+
+	err := service.database.Transaction(func(tx *gorm.DB) error {
+		var current []int
+		result := tx.Model(&entity.CollectionItem{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("collection_id = ?", collection.Id).Order("position").Pluck("article_id", &current)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		var visible []int
+		result = visibleItems(tx, collection, auth.Principal{UserId: collection.OwnerId}).Pluck("collection_items.article_id", &visible)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		isVisible := map[int]bool{}
+		for _, articleId := range visible {
+			isVisible[articleId] = true
+		}
+
+		seen := map[int]bool{}
+		for _, articleId := range articleIds {
+			if !isVisible[articleId] || seen[articleId] {
+				return errors.New("article ids must match the articles of the collection")
+			}
+			seen[articleId] = true
+		}
+		if len(seen) != len(isVisible) {
+			return errors.New("article ids must match the articles of the collection")
+		}
+
+		order := append([]int{}, articleIds...)
+		for _, articleId := range current {
+			if !isVisible[articleId] {
+				order = append(order, articleId)
+			}
+		}
+
+		for i, articleId := range order {
+			result := tx.Model(&entity.CollectionItem{}).Where("collection_id = ? and article_id = ?", collection.Id, articleId).Update("position", i+1)
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return collection, err
+	}
+
+	return service.reload(collection.Id)
+}
+
+// only public collections of other users can be followed, following twice is not an error
+func (service *CollectionsServiceProvider) Follow(id int, userId int) error {
+	var collection entity.Collection
+	if result := service.database.First(&collection, id); result.Error != nil {
+		return result.Error
+	}
+
+	if collection.OwnerId == userId {
+		return errors.New("can't follow own collection")
+	}
+
+	if !collection.Public {
+		return errors.New("collection is private")
+	}
+
+	follower := entity.CollectionFollower{CollectionId: collection.Id, UserId: userId}
+	return service.database.Clauses(clause.OnConflict{DoNothing: true}).Create(&follower).Error
+}
+
+func (service *CollectionsServiceProvider) Unfollow(id int, userId int) error {
+	result := service.database.Where("collection_id = ? and user_id = ?", id, userId).Delete(&entity.CollectionFollower{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return errors.New("collection is not followed")
+	}
+
+	return nil
+}
+
+// the collection as its owner sees it, after a change made by the owner
+func (service *CollectionsServiceProvider) reload(id int) (entity.Collection, error) {
+	var collection entity.Collection
+	if result := service.database.First(&collection, id); result.Error != nil {
+		return collection, result.Error
+	}
+
+	if err := service.loadAssociatedData(&collection, auth.Principal{UserId: collection.OwnerId}, true); err != nil {
+		return collection, err
+	}
+
+	return collection, nil
+}
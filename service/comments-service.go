@@ -0,0 +1,144 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/danielblagy/blog-webapp-server/auth"
+	"github.com/danielblagy/blog-webapp-server/entity"
+	"gorm.io/gorm"
+)
+
+type CommentsService interface {
+	GetByArticle(articleId string, viewer auth.Principal) ([]entity.Comment, error)
+	GetById(id int) (entity.Comment, error)
+	Create(comment entity.Comment) (entity.Comment, error)
+	Update(id int, updatedData entity.EditableCommentData) (entity.Comment, error)
+	Delete(id int) (entity.Comment, error)
+}
+
+type CommentsServiceProvider struct {
+	database        *gorm.DB
+	articlesService ArticlesService
+}
+
+func CreateCommentsService(database *gorm.DB, articlesService ArticlesService) CommentsService {
+	return &CommentsServiceProvider{
+		database:        database,
+		articlesService: articlesService,
+	}
+}
+
+func (service *CommentsServiceProvider) loadAssociatedData(comment *entity.Comment) error {
+	// NOTE: author's associated data will not be loaded
+	if result := service.database.Where("id = ?", comment.AuthorId).First(&comment.Author); result.Error != nil {
+		return errors.New("failed to load associated data")
+	}
+
+	return nil
+}
+
+// returns top-level comments of the article with their replies nested in them,
+// the article must be visible to the viewer
+func (service *CommentsServiceProvider) GetByArticle(articleId string, viewer auth.Principal) ([]entity.Comment, error) {
+	if _, err := service.articlesService.GetById(articleId, viewer); err != nil {
+		return []entity.Comment{}, err
+	}
+
+	var comments []entity.Comment
+	result := service.database.Where("article_id = ?", articleId).Order("created_at, id").Find(&comments)
+	if result.Error != nil {
+		return []entity.Comment{}, result.Error
+	}
+
+	// associated data
+	for i := range comments {
+		if err := service.loadAssociatedData(&comments[i]); err != nil {
+			return []entity.Comment{}, err
+		}
+	}
+
+	return buildCommentsTree(comments, nil), nil
+}
+
+func buildCommentsTree(comments []entity.Comment, parentId *int) []entity.Comment {
+	tree := []entity.Comment{}
+	for _, comment := range comments {
+		if (parentId == nil && comment.ParentId == nil) || (parentId != nil && comment.ParentId != nil && *comment.ParentId == *parentId) {
+			id := comment.Id
+			comment.Replies = buildCommentsTree(comments, &id)
+			tree = append(tree, comment)
+		}
+	}
+	return tree
+}
+
+func (service *CommentsServiceProvider) GetById(id int) (entity.Comment, error) {
+	var comment entity.Comment
+	result := service.database.Where("id = ?", id).First(&comment)
+	if result.Error != nil {
+		return comment, result.Error
+	}
+
+	if err := service.loadAssociatedData(&comment); err != nil {
+		return comment, err
+	}
+
+	return comment, nil
+}
+
+func (service *CommentsServiceProvider) Create(comment entity.Comment) (entity.Comment, error) {
+	result := service.database.Create(&comment)
+	if result.Error != nil {
+		return comment, result.Error
+	}
+
+	if err := service.loadAssociatedData(&comment); err != nil {
+		return comment, err
+	}
+
+	comment.Replies = []entity.Comment{}
+	return comment, nil
+}
+
+func (service *CommentsServiceProvider) Update(id int, updatedData entity.EditableCommentData) (entity.Comment, error) {
+	var comment entity.Comment
+	if result := service.database.Where("id = ?", id).First(&comment); result.Error != nil {
+		return comment, errors.New("comment was not found")
+	}
+
+	comment.Body = updatedData.Body
+
+	result := service.database.Save(&comment)
+
+	if err := service.loadAssociatedData(&comment); err != nil {
+		return comment, err
+	}
+
+	return comment, result.Error
+}
+
+// deletes the comment along with all the replies to it
+func (service *CommentsServiceProvider) Delete(id int) (entity.Comment, error) {
+	// getting the comment before deleting to return
+	comment, err := service.GetById(id)
+	if err != nil {
+		return comment, err
+	}
+
+	var articleComments []entity.Comment
+	if result := service.database.Where("article_id = ?", comment.ArticleId).Find(&articleComments); result.Error != nil {
+		return comment, result.Error
+	}
+
+	idsToDelete := []int{comment.Id}
+	for i := 0; i < len(idsToDelete); i++ {
+		for _, articleComment := range articleComments {
+			if articleComment.ParentId != nil && *articleComment.ParentId == idsToDelete[i] {
+				idsToDelete = append(idsToDelete, articleComment.Id)
+			}
+		}
+	}
+
+	result := service.database.Delete(&entity.Comment{}, idsToDelete)
+	return comment, result.Error
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/danielblagy/blog-webapp-server/entity"
+	"gorm.io/gorm"
+)
+
+type IdentitiesService interface {
+	GetByUser(userId string) ([]entity.Identity, error)
+	GetBySubject(provider string, subject string) (entity.Identity, error)
+	Create(identity entity.Identity) (entity.Identity, error)
+	Delete(userId string, id string) (entity.Identity, error)
+}
+
+type IdentitiesServiceProvider struct {
+	database *gorm.DB
+}
+
+func CreateIdentitiesService(database *gorm.DB) IdentitiesService {
+	return &IdentitiesServiceProvider{
+		database: database,
+	}
+}
+
+func (service *IdentitiesServiceProvider) GetByUser(userId string) ([]entity.Identity, error) {
+	identities := []entity.Identity{}
+	result := service.database.Where("user_id = ?", userId).Order("created_at").Find(&identities)
+	return identities, result.Error
+}
+
+func (service *IdentitiesServiceProvider) GetBySubject(provider string, subject string) (entity.Identity, error) {
+	var identity entity.Identity
+	result := service.database.Where("provider = ? and subject = ?", provider, subject).First(&identity)
+	return identity, result.Error
+}
+
+func (service *IdentitiesServiceProvider) Create(identity entity.Identity) (entity.Identity, error) {
+	result := service.database.Create(&identity)
+	return identity, result.Error
+}
+
+func (service *IdentitiesServiceProvider) Delete(userId string, id string) (entity.Identity, error) {
+	var identity entity.Identity
+	if result := service.database.Where("id = ? and user_id = ?", id, userId).First(&identity); result.Error != nil {
+		return identity, errors.New("identity was not found")
+	}
+
+	result := service.database.Delete(&identity)
+	return identity, result.Error
+}
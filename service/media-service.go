@@ -0,0 +1,484 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"image"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/danielblagy/blog-webapp-server/auth"
+	"github.com/danielblagy/blog-webapp-server/entity"
+	"github.com/danielblagy/blog-webapp-server/imaging"
+	"github.com/danielblagy/blog-webapp-server/pagination"
+	"github.com/danielblagy/blog-webapp-server/storage"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultMaxMediaSize = 10 << 20
+	maxFilenameLength   = 255
+	maxMediaPerArticle  = 50
+
+	// a worker that doesn't finish processing media in time is assumed to have failed,
+	// the media is processed again after that
+	mediaProcessingLease       = 10 * time.Minute
+	maxMediaProcessingAttempts = 3
+)
+
+// content types of the uploads are sniffed from their contents, the extensions of the keys match them
+var mediaExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// Resized copies of uploaded images that fit in the size, the original is kept as it was uploaded.
+// Every size is made as JPEG, e.g. "medium", and as WebP, e.g. "medium_webp".
+var mediaVariantSizes = []struct {
+	name string
+	size int
+}{
+	{"thumbnail", 320},
+	{"medium", 800},
+	{"large", 1600},
+}
+
+type MediaService interface {
+	GetById(id int, viewer auth.Principal) (entity.Media, error)
+	GetByOwner(ownerId int, params pagination.Params) ([]entity.Media, pagination.Cursors, error)
+	GetByArticle(articleId int) ([]entity.Media, error)
+	Upload(ownerId int, filename string, data []byte) (entity.Media, error)
+	Delete(id int) (entity.Media, error)
+	CheckOwner(ownerId int, ids []int) error
+	SetArticleMedia(articleId int, ids []int) error
+	DeleteByArticle(articleId string) error
+	DeleteOrphans(olderThan time.Time) (int, error)
+	Pending() <-chan struct{}
+	ProcessPending(limit int) (int, error)
+}
+
+type MediaServiceProvider struct {
+	database *gorm.DB
+	storage  storage.Storage
+	pending  chan struct{} // signaled when an image that needs processing is uploaded
+}
+
+func CreateMediaService(database *gorm.DB, storage storage.Storage) MediaService {
+	return &MediaServiceProvider{
+		database: database,
+		storage:  storage,
+		pending:  make(chan struct{}, 1),
+	}
+}
+
+// Reads the upload size limit in bytes from MEDIA_MAX_SIZE env variable, 10 MB by default
+func MaxMediaSizeFromEnv() (int64, error) {
+	value := os.Getenv("MEDIA_MAX_SIZE")
+	if value == "" {
+		return defaultMaxMediaSize, nil
+	}
+
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size <= 0 {
+		return 0, errors.New("MEDIA_MAX_SIZE must be a positive number of bytes")
+	}
+
+	return size, nil
+}
+
+// keeps the base name of the uploaded file, without control characters
+func sanitizeFilename(filename string) string {
+	filename = filepath.Base(strings.ReplaceAll(filename, "\\", "/"))
+	filename = strings.Map(func(r rune) rune {
+		if r < 32 || r == 127 {
+			return -1
+		}
+		return r
+	}, filename)
+
+	if filename == "." || filename == "/" {
+		return ""
+	}
+
+	filename = strings.ToValidUTF8(filename, "")
+	for len(filename) > maxFilenameLength {
+		_, size := utf8.DecodeLastRuneInString(filename)
+		filename = filename[:len(filename)-size]
+	}
+
+	return filename
+}
+
+// Media is visible to its owner, and to everyone once it's used in a published article.
+// Media the viewer can't see is reported as not found, so the uploads of other users can't be listed.
+func (service *MediaServiceProvider) GetById(id int, viewer auth.Principal) (entity.Media, error) {
+	var media entity.Media
+	if result := service.database.First(&media, id); result.Error != nil {
+		return entity.Media{}, errors.New("media was not found")
+	}
+
+	if viewer.UserId == media.OwnerId && !viewer.IsAnonymous() {
+		return media, nil
+	}
+
+	var count int64
+	result := service.database.Model(&entity.ArticleMedia{}).
+		Joins("join articles on articles.id = article_media.article_id").
+		Where("article_media.media_id = ? and articles.published = true", media.Id).
+		Count(&count)
+	if result.Error != nil {
+		return entity.Media{}, result.Error
+	}
+	if count == 0 {
+		return entity.Media{}, errors.New("media was not found")
+	}
+
+	return media, nil
+}
+
+// the newest first
+func (service *MediaServiceProvider) GetByOwner(ownerId int, params pagination.Params) ([]entity.Media, pagination.Cursors, error) {
+	media := []entity.Media{}
+	result := pagination.Apply(service.database.Where("owner_id = ?", ownerId), params, "created_at", "id").Find(&media)
+	if result.Error != nil {
+		return media, pagination.Cursors{}, result.Error
+	}
+
+	hasMore := pagination.Trim(params, &media)
+
+	cursors := pagination.NewCursors(params, hasMore, len(media), func(i int) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: media[i].CreatedAt, Id: media[i].Id}
+	})
+
+	return media, cursors, nil
+}
+
+// in the order they were set in
+func (service *MediaServiceProvider) GetByArticle(articleId int) ([]entity.Media, error) {
+	media := []entity.Media{}
+	result := service.database.Joins("join article_media on article_media.media_id = media.id").
+		Where("article_media.article_id = ?", articleId).Order("article_media.position").Find(&media)
+	return media, result.Error
+}
+
+// The content type is sniffed from the data, the one sent by the client is ignored.
+// The size limit is expected to be checked by the caller. Metadata like EXIF (with GPS locations)
+// is removed before the file is stored. The variants and the blurhash are made later
+// by ProcessPending, until then the media is pending.
+func (service *MediaServiceProvider) Upload(ownerId int, filename string, data []byte) (entity.Media, error) {
+	contentType := http.DetectContentType(data)
+	extension, ok := mediaExtensions[contentType]
+	if !ok {
+		return entity.Media{}, errors.New("unsupported media type")
+	}
+
+	width, height, err := imaging.Dimensions(data)
+	if err != nil {
+		return entity.Media{}, err
+	}
+
+	data, err = imaging.StripMetadata(data, contentType)
+	if err != nil {
+		return entity.Media{}, err
+	}
+
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return entity.Media{}, err
+	}
+
+	media := entity.Media{
+		OwnerId:     ownerId,
+		Key:         "media/" + strconv.Itoa(ownerId) + "/" + hex.EncodeToString(random) + extension,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Filename:    sanitizeFilename(filename),
+		Width:       width,
+		Height:      height,
+		Status:      entity.MediaPending,
+	}
+
+	if err := service.storage.Put(media.Key, contentType, bytes.NewReader(data)); err != nil {
+		return media, err
+	}
+
+	if result := service.database.Create(&media); result.Error != nil {
+		service.deleteFile(media.Key)
+		return media, result.Error
+	}
+
+	select {
+	case service.pending <- struct{}{}:
+	default:
+	}
+
+	return media, nil
+}
+
+// the media is removed from the articles it's used in
+func (service *MediaServiceProvider) Delete(id int) (entity.Media, error) {
+	var media entity.Media
+	if result := service.database.First(&media, id); result.Error != nil {
+		return media, result.Error
+	}
+
+	err := service.database.Transaction(func(tx *gorm.DB) error {
+		if result := tx.Where("media_id = ?", media.Id).Delete(&entity.ArticleMedia{}); result.Error != nil {
+			return result.Error
+		}
+		return tx.Delete(&media).Error
+	})
+	if err != nil {
+		return media, err
+	}
+
+	service.deleteFiles(media)
+	return media, nil
+}
+
+// checks that all of the media exist and belong to the user
+func (service *MediaServiceProvider) CheckOwner(ownerId int, ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if len(ids) > maxMediaPerArticle {
+		return errors.New("too many media")
+	}
+
+	unique := map[int]bool{}
+	for _, id := range ids {
+		unique[id] = true
+	}
+
+	var count int64
+	result := service.database.Model(&entity.Media{}).Where("id in ? and owner_id = ?", ids, ownerId).Count(&count)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if int(count) != len(unique) {
+		return errors.New("media was not found")
+	}
+
+	return nil
+}
+
+// replaces the media of the article, duplicates are ignored
+func (service *MediaServiceProvider) SetArticleMedia(articleId int, ids []int) error {
+	return service.database.Transaction(func(tx *gorm.DB) error {
+		if result := tx.Where("article_id = ?", articleId).Delete(&entity.ArticleMedia{}); result.Error != nil {
+			return result.Error
+		}
+
+		seen := map[int]bool{}
+		for _, id := range ids {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			if result := tx.Create(&entity.ArticleMedia{ArticleId: articleId, MediaId: id, Position: len(seen)}); result.Error != nil {
+				return result.Error
+			}
+		}
+
+		return nil
+	})
+}
+
+// removes the media from the deleted article and deletes the ones no other article uses
+func (service *MediaServiceProvider) DeleteByArticle(articleId string) error {
+	var ids []int
+	if result := service.database.Model(&entity.ArticleMedia{}).Where("article_id = ?", articleId).Pluck("media_id", &ids); result.Error != nil {
+		return result.Error
+	}
+
+	if result := service.database.Where("article_id = ?", articleId).Delete(&entity.ArticleMedia{}); result.Error != nil {
+		return result.Error
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := service.deleteUnused(service.database.Where("id in ?", ids))
+	return err
+}
+
+// Deletes media not used in any article that were uploaded before olderThan,
+// e.g. uploads for an article that was never saved. Returns how many were deleted.
+func (service *MediaServiceProvider) DeleteOrphans(olderThan time.Time) (int, error) {
+	return service.deleteUnused(service.database.Where("created_at < ?", olderThan))
+}
+
+// deletes the media matching the query that are not used in any article
+func (service *MediaServiceProvider) deleteUnused(query *gorm.DB) (int, error) {
+	used := service.database.Table("article_media").Select("media_id")
+
+	// the rows are deleted first, so a file is never linked without existing
+	orphans := []entity.Media{}
+	result := query.Clauses(clause.Returning{}).Where("id not in (?)", used).Delete(&orphans)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	for _, media := range orphans {
+		service.deleteFiles(media)
+	}
+
+	return len(orphans), nil
+}
+
+// signaled after uploads that need processing, it's buffered so a worker misses none of them
+func (service *MediaServiceProvider) Pending() <-chan struct{} {
+	return service.pending
+}
+
+// Makes the variants and the blurhash of up to limit pending media, returns how many were taken.
+// Each media is leased to one worker, so it's safe to run in multiple server instances at once.
+// Images that can't be decoded fail, storage errors are retried after the lease expires.
+func (service *MediaServiceProvider) ProcessPending(limit int) (int, error) {
+	now := time.Now()
+
+	claimed := []entity.Media{}
+	result := service.database.Raw(`update media set processing_until = ?, processing_attempts = processing_attempts + 1
+		where id in (
+			select id from media where status = ? and (processing_until is null or processing_until < ?)
+			order by id limit ? for update skip locked
+		) returning *`, now.Add(mediaProcessingLease), entity.MediaPending, now, limit).Scan(&claimed)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	for _, media := range claimed {
+		if err := service.process(media); err != nil {
+			log.Printf("Failed to process media %d: %s", media.Id, err.Error())
+		}
+	}
+
+	return len(claimed), nil
+}
+
+func (service *MediaServiceProvider) process(media entity.Media) error {
+	reader, err := service.storage.Get(media.Key)
+	if err != nil {
+		return service.processingFailed(media, err, false)
+	}
+
+	img, err := imaging.Decode(reader)
+	reader.Close()
+	if err != nil {
+		return service.processingFailed(media, err, true)
+	}
+
+	// the keys are unique to this attempt, so a worker that lost its lease can't delete the files of another one
+	random := make([]byte, 4)
+	if _, err := rand.Read(random); err != nil {
+		return err
+	}
+	prefix := strings.TrimSuffix(media.Key, path.Ext(media.Key)) + "-" + hex.EncodeToString(random)
+
+	variants := entity.ImageSet{}
+	for _, size := range mediaVariantSizes {
+		resized := imaging.Fit(img, size.size, size.size)
+
+		formats := []struct {
+			name        string
+			extension   string
+			contentType string
+			encode      func(io.Writer, image.Image) error
+		}{
+			{size.name, ".jpg", "image/jpeg", imaging.EncodeJPEG},
+		}
+		// builds without cgo only make JPEG variants
+		if imaging.CanEncodeWebP {
+			formats = append(formats, struct {
+				name        string
+				extension   string
+				contentType string
+				encode      func(io.Writer, image.Image) error
+			}{size.name + "_webp", ".webp", "image/webp", imaging.EncodeWebP})
+		}
+
+		for _, format := range formats {
+			var encoded bytes.Buffer
+			if err := format.encode(&encoded, resized); err != nil {
+				service.deleteVariants(variants)
+				return err
+			}
+
+			key := prefix + "-" + size.name + format.extension
+			if err := service.storage.Put(key, format.contentType, &encoded); err != nil {
+				service.deleteVariants(variants)
+				return service.processingFailed(media, err, false)
+			}
+			variants[format.name] = key
+		}
+	}
+
+	bounds := img.Bounds()
+	result := service.database.Model(&entity.Media{}).Where("id = ? and status = ? and processing_until = ?", media.Id, entity.MediaPending, media.ProcessingUntil).
+		Updates(map[string]interface{}{
+			"width":            bounds.Dx(),
+			"height":           bounds.Dy(),
+			"blurhash":         imaging.Blurhash(img),
+			"variants":         variants,
+			"status":           entity.MediaReady,
+			"processing_until": nil,
+		})
+
+	// the media was deleted or leased to another worker meanwhile
+	if result.Error != nil || result.RowsAffected == 0 {
+		service.deleteVariants(variants)
+	}
+
+	return result.Error
+}
+
+// marks the media as failed if the error is permanent or it was attempted too many times
+func (service *MediaServiceProvider) processingFailed(media entity.Media, err error, permanent bool) error {
+	if !permanent && media.ProcessingAttempts < maxMediaProcessingAttempts {
+		return err
+	}
+
+	result := service.database.Model(&entity.Media{}).Where("id = ? and status = ?", media.Id, entity.MediaPending).
+		Updates(map[string]interface{}{"status": entity.MediaFailed, "processing_until": nil})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return err
+}
+
+// deletes the file and its variants
+func (service *MediaServiceProvider) deleteFiles(media entity.Media) {
+	service.deleteFile(media.Key)
+	service.deleteVariants(media.Variants)
+}
+
+func (service *MediaServiceProvider) deleteVariants(variants entity.ImageSet) {
+	for _, key := range variants {
+		service.deleteFile(key)
+	}
+}
+
+// the file isn't linked anymore, so failing to delete it is only logged
+func (service *MediaServiceProvider) deleteFile(key string) {
+	if err := service.storage.Delete(key); err != nil {
+		log.Printf("Failed to delete media '%s': %s", key, err.Error())
+	}
+}
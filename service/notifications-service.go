@@ -0,0 +1,309 @@
+package service
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/danielblagy/blog-webapp-server/entity"
+	"github.com/danielblagy/blog-webapp-server/events"
+	"github.com/danielblagy/blog-webapp-server/pagination"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const notificationsBatchSize = 100
+
+type NotificationsService interface {
+	GetAll(userId int, unreadOnly bool, params pagination.Params) ([]entity.Notification, pagination.Cursors, error)
+	GetAfter(userId int, afterId int) ([]entity.Notification, error)
+	GetLastId(userId int) (int, error)
+	CountUnread(userId int) (int64, error)
+	MarkRead(userId int, id string) error
+	MarkAllRead(userId int) error
+	GetPreferences(userId int) (map[string]bool, error)
+	SetPreferences(userId int, preferences map[string]bool) (map[string]bool, error)
+	Listen(userId int) (<-chan struct{}, func())
+}
+
+type NotificationsServiceProvider struct {
+	database *gorm.DB
+
+	mutex     sync.Mutex
+	listeners map[int]map[chan struct{}]bool
+}
+
+// subscribes to the events notifications are created for
+func CreateNotificationsService(database *gorm.DB, bus *events.Bus) NotificationsService {
+	service := &NotificationsServiceProvider{
+		database:  database,
+		listeners: map[int]map[chan struct{}]bool{},
+	}
+
+	bus.Subscribe(events.TypeFollow, service.handleFollow)
+	bus.Subscribe(events.TypeSave, service.handleSave)
+	bus.Subscribe(events.TypePublish, service.handlePublish)
+
+	return service
+}
+
+func isValidNotificationType(notificationType string) bool {
+	for _, valid := range events.Types {
+		if notificationType == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// NOTE: actors' associated data will not be loaded
+func (service *NotificationsServiceProvider) loadAssociatedData(notification *entity.Notification) error {
+	if result := service.database.Where("id = ?", notification.ActorId).First(&notification.Actor); result.Error != nil {
+		return errors.New("failed to load associated data")
+	}
+
+	if notification.ArticleId != nil {
+		var titles []string
+		if result := service.database.Model(&entity.Article{}).Where("id = ?", *notification.ArticleId).Pluck("title", &titles); result.Error != nil {
+			return errors.New("failed to load associated data")
+		}
+		if len(titles) > 0 {
+			notification.ArticleTitle = titles[0]
+		}
+	}
+
+	return nil
+}
+
+// the newest first
+func (service *NotificationsServiceProvider) GetAll(userId int, unreadOnly bool, params pagination.Params) ([]entity.Notification, pagination.Cursors, error) {
+	query := service.database.Where("user_id = ?", userId)
+	if unreadOnly {
+		query = query.Where("read = false")
+	}
+
+	notifications := []entity.Notification{}
+	result := pagination.Apply(query, params, "created_at", "id").Find(&notifications)
+	if result.Error != nil {
+		return notifications, pagination.Cursors{}, result.Error
+	}
+
+	hasMore := pagination.Trim(params, &notifications)
+
+	for i := range notifications {
+		if err := service.loadAssociatedData(&notifications[i]); err != nil {
+			return notifications, pagination.Cursors{}, err
+		}
+	}
+
+	cursors := pagination.NewCursors(params, hasMore, len(notifications), func(i int) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: notifications[i].CreatedAt, Id: notifications[i].Id}
+	})
+
+	return notifications, cursors, nil
+}
+
+// notifications created after the one with afterId, the oldest first
+func (service *NotificationsServiceProvider) GetAfter(userId int, afterId int) ([]entity.Notification, error) {
+	notifications := []entity.Notification{}
+	result := service.database.Where("user_id = ? and id > ?", userId, afterId).Order("id").Limit(pagination.MaxLimit).Find(&notifications)
+	if result.Error != nil {
+		return notifications, result.Error
+	}
+
+	for i := range notifications {
+		if err := service.loadAssociatedData(&notifications[i]); err != nil {
+			return notifications, err
+		}
+	}
+
+	return notifications, nil
+}
+
+// 0 if the user has no notifications
+func (service *NotificationsServiceProvider) GetLastId(userId int) (int, error) {
+	var lastId int
+	result := service.database.Model(&entity.Notification{}).Where("user_id = ?", userId).Select("coalesce(max(id), 0)").Scan(&lastId)
+	return lastId, result.Error
+}
+
+func (service *NotificationsServiceProvider) CountUnread(userId int) (int64, error) {
+	var count int64
+	result := service.database.Model(&entity.Notification{}).Where("user_id = ? and read = false", userId).Count(&count)
+	return count, result.Error
+}
+
+func (service *NotificationsServiceProvider) MarkRead(userId int, id string) error {
+	result := service.database.Model(&entity.Notification{}).Where("id = ? and user_id = ?", id, userId).Update("read", true)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return errors.New("notification not found")
+	}
+
+	return nil
+}
+
+func (service *NotificationsServiceProvider) MarkAllRead(userId int) error {
+	result := service.database.Model(&entity.Notification{}).Where("user_id = ? and read = false", userId).Update("read", true)
+	return result.Error
+}
+
+// every notification type with whether it's enabled
+func (service *NotificationsServiceProvider) GetPreferences(userId int) (map[string]bool, error) {
+	var rows []entity.NotificationPreference
+	if result := service.database.Where("user_id = ?", userId).Find(&rows); result.Error != nil {
+		return nil, result.Error
+	}
+
+	preferences := map[string]bool{}
+	for _, notificationType := range events.Types {
+		preferences[notificationType] = true
+	}
+	for _, row := range rows {
+		if isValidNotificationType(row.Type) {
+			preferences[row.Type] = row.Enabled
+		}
+	}
+
+	return preferences, nil
+}
+
+// types that are not in preferences are left as is
+func (service *NotificationsServiceProvider) SetPreferences(userId int, preferences map[string]bool) (map[string]bool, error) {
+	for notificationType := range preferences {
+		if !isValidNotificationType(notificationType) {
+			return nil, errors.New("invalid notification type '" + notificationType + "'")
+		}
+	}
+
+	rows := []entity.NotificationPreference{}
+	for notificationType, enabled := range preferences {
+		rows = append(rows, entity.NotificationPreference{UserId: userId, Type: notificationType, Enabled: enabled})
+	}
+
+	if len(rows) > 0 {
+		result := service.database.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "type"}},
+			DoUpdates: clause.AssignmentColumns([]string{"enabled"}),
+		}).Create(&rows)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+	}
+
+	return service.GetPreferences(userId)
+}
+
+// Returns a channel that receives a value when the user gets new notifications, and a function
+// to stop listening. Only notifications created by this server instance are signaled.
+func (service *NotificationsServiceProvider) Listen(userId int) (<-chan struct{}, func()) {
+	// buffered, so a signal sent while the listener is busy is not lost
+	channel := make(chan struct{}, 1)
+
+	service.mutex.Lock()
+	if service.listeners[userId] == nil {
+		service.listeners[userId] = map[chan struct{}]bool{}
+	}
+	service.listeners[userId][channel] = true
+	service.mutex.Unlock()
+
+	stop := func() {
+		service.mutex.Lock()
+		defer service.mutex.Unlock()
+
+		delete(service.listeners[userId], channel)
+		if len(service.listeners[userId]) == 0 {
+			delete(service.listeners, userId)
+		}
+	}
+
+	return channel, stop
+}
+
+func (service *NotificationsServiceProvider) signal(userId int) {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	for channel := range service.listeners[userId] {
+		select {
+		case channel <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// recipients who have the notification type disabled are skipped
+func (service *NotificationsServiceProvider) notify(recipients []int, notificationType string, actorId int, articleId *int) error {
+	var disabled []int
+	result := service.database.Model(&entity.NotificationPreference{}).
+		Where("user_id in ? and type = ? and enabled = false", recipients, notificationType).Pluck("user_id", &disabled)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	skip := map[int]bool{actorId: true} // users aren't notified about their own actions
+	for _, userId := range disabled {
+		skip[userId] = true
+	}
+
+	notifications := []entity.Notification{}
+	for _, userId := range recipients {
+		if !skip[userId] {
+			notifications = append(notifications, entity.Notification{UserId: userId, Type: notificationType, ActorId: actorId, ArticleId: articleId})
+		}
+	}
+
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	if result := service.database.CreateInBatches(&notifications, notificationsBatchSize); result.Error != nil {
+		return result.Error
+	}
+
+	for _, notification := range notifications {
+		service.signal(notification.UserId)
+	}
+
+	return nil
+}
+
+func (service *NotificationsServiceProvider) handleFollow(event events.Event) error {
+	return service.notify([]int{event.UserId}, event.Type, event.ActorId, nil)
+}
+
+// notifies the author of the saved article
+func (service *NotificationsServiceProvider) handleSave(event events.Event) error {
+	var article entity.Article
+	if result := service.database.Select("id, author_id").First(&article, event.ArticleId); result.Error != nil {
+		return result.Error
+	}
+
+	articleId := event.ArticleId
+	return service.notify([]int{article.AuthorId}, event.Type, event.ActorId, &articleId)
+}
+
+// notifies the author's followers
+func (service *NotificationsServiceProvider) handlePublish(event events.Event) error {
+	var followers []int
+	result := service.database.Table("followers").Where("follows_id = ?", event.ActorId).Pluck("follower_id", &followers)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	for start := 0; start < len(followers); start += notificationsBatchSize {
+		end := start + notificationsBatchSize
+		if end > len(followers) {
+			end = len(followers)
+		}
+
+		articleId := event.ArticleId
+		if err := service.notify(followers[start:end], event.Type, event.ActorId, &articleId); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/danielblagy/blog-webapp-server/auth"
+	"github.com/danielblagy/blog-webapp-server/entity"
+	"gorm.io/gorm"
+)
+
+const (
+	maxPersonalTokensPerUser = 50
+
+	// last_used_at is updated at most this often, so using a token doesn't write on every request
+	personalTokenUsageResolution = time.Minute
+)
+
+type PersonalTokensService interface {
+	GetByUser(userId string) ([]entity.PersonalAccessToken, error)
+	Create(userId string, name string, scopes []string, expiresAt *time.Time) (entity.PersonalAccessToken, string, error)
+	Revoke(userId string, id string) (entity.PersonalAccessToken, error)
+	Resolve(token string) (auth.Principal, error)
+	IsActive(id int) bool
+}
+
+type PersonalTokensServiceProvider struct {
+	database     *gorm.DB
+	usersService UsersService
+}
+
+func CreatePersonalTokensService(database *gorm.DB, usersService UsersService) PersonalTokensService {
+	return &PersonalTokensServiceProvider{
+		database:     database,
+		usersService: usersService,
+	}
+}
+
+// returns tokens that are not revoked, including expired ones, the newest first
+func (service *PersonalTokensServiceProvider) GetByUser(userId string) ([]entity.PersonalAccessToken, error) {
+	tokens := []entity.PersonalAccessToken{}
+	result := service.database.Where("user_id = ? and revoked_at is null", userId).Order("created_at desc").Find(&tokens)
+	return tokens, result.Error
+}
+
+// returns the created token together with the token string, which isn't stored
+func (service *PersonalTokensServiceProvider) Create(userId string, name string, scopes []string, expiresAt *time.Time) (entity.PersonalAccessToken, string, error) {
+	var record entity.PersonalAccessToken
+
+	id, err := strconv.Atoi(userId)
+	if err != nil {
+		return record, "", errors.New("invalid user id")
+	}
+
+	var count int64
+	if result := service.database.Model(&entity.PersonalAccessToken{}).Where("user_id = ? and revoked_at is null", id).Count(&count); result.Error != nil {
+		return record, "", result.Error
+	}
+	if count >= maxPersonalTokensPerUser {
+		return record, "", errors.New("too many personal access tokens")
+	}
+
+	random, err := auth.GenerateRandomToken()
+	if err != nil {
+		return record, "", err
+	}
+	token := auth.PersonalAccessTokenPrefix + random
+
+	record = entity.PersonalAccessToken{
+		UserId:    id,
+		Name:      name,
+		Prefix:    token[:len(auth.PersonalAccessTokenPrefix)+6],
+		TokenHash: auth.HashToken(token),
+		Scopes:    entity.Scopes(scopes),
+		ExpiresAt: expiresAt,
+	}
+	result := service.database.Create(&record)
+	return record, token, result.Error
+}
+
+func (service *PersonalTokensServiceProvider) Revoke(userId string, id string) (entity.PersonalAccessToken, error) {
+	var record entity.PersonalAccessToken
+	if result := service.database.Where("id = ? and user_id = ? and revoked_at is null", id, userId).First(&record); result.Error != nil {
+		return record, errors.New("token was not found")
+	}
+
+	now := time.Now()
+	record.RevokedAt = &now
+	result := service.database.Model(&record).Update("revoked_at", now)
+	return record, result.Error
+}
+
+// Authenticates a personal access token, used by auth for the Authorization header.
+// The token acts with the current role of its user, tokens of suspended users are rejected.
+func (service *PersonalTokensServiceProvider) Resolve(token string) (auth.Principal, error) {
+	var record entity.PersonalAccessToken
+	result := service.database.Where("token_hash = ? and revoked_at is null", auth.HashToken(token)).First(&record)
+	if result.Error != nil || (record.ExpiresAt != nil && time.Now().After(*record.ExpiresAt)) {
+		return auth.Principal{}, errors.New("invalid or expired personal access token")
+	}
+
+	user, err := service.usersService.GetAccount(strconv.Itoa(record.UserId))
+	if err != nil || user.Suspended {
+		return auth.Principal{}, errors.New("invalid or expired personal access token")
+	}
+
+	now := time.Now()
+	service.database.Model(&entity.PersonalAccessToken{}).
+		Where("id = ? and (last_used_at is null or last_used_at < ?)", record.Id, now.Add(-personalTokenUsageResolution)).
+		Update("last_used_at", now)
+
+	principal := auth.Principal{
+		UserId:    user.Id,
+		Role:      user.Role,
+		TokenType: auth.TokenTypePersonal,
+		TokenId:   record.Id,
+		Scopes:    record.Scopes,
+	}
+	if record.ExpiresAt != nil {
+		principal.ExpiresAt = *record.ExpiresAt
+	}
+	return principal, nil
+}
+
+// whether the token is neither revoked nor expired
+func (service *PersonalTokensServiceProvider) IsActive(id int) bool {
+	var count int64
+	result := service.database.Model(&entity.PersonalAccessToken{}).
+		Where("id = ? and revoked_at is null and (expires_at is null or expires_at > ?)", id, time.Now()).
+		Count(&count)
+	return result.Error == nil && count > 0
+}
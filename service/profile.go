@@ -0,0 +1,107 @@
+package service
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/danielblagy/blog-webapp-server/entity"
+)
+
+const (
+	maxBioLength      = 500
+	maxLocationLength = 100
+	maxUrlLength      = 300
+	maxProfileLinks   = 5
+)
+
+type imageSize struct {
+	name   string
+	width  int
+	height int
+}
+
+// profile images are cropped to these sizes
+var profileImageSizes = map[string][]imageSize{
+	entity.ProfileImageAvatar: {
+		{name: "small", width: 64, height: 64},
+		{name: "medium", width: 200, height: 200},
+		{name: "large", width: 400, height: 400},
+	},
+	entity.ProfileImageHeader: {
+		{name: "medium", width: 750, height: 250},
+		{name: "large", width: 1500, height: 500},
+	},
+}
+
+func IsValidProfileImage(kind string) bool {
+	_, ok := profileImageSizes[kind]
+	return ok
+}
+
+// trims the url and checks that it's an absolute http(s) url
+func NormalizeProfileUrl(rawUrl string) (string, error) {
+	rawUrl = strings.TrimSpace(rawUrl)
+	if len(rawUrl) > maxUrlLength {
+		return "", errors.New("url is too long")
+	}
+
+	parsed, err := url.Parse(rawUrl)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", errors.New("invalid url '" + rawUrl + "'")
+	}
+
+	return rawUrl, nil
+}
+
+// trims the provided profile fields and checks their lengths and urls, empty links are removed
+func NormalizeProfile(data *entity.EditableUserData) error {
+	if data.Bio != nil {
+		bio := strings.TrimSpace(*data.Bio)
+		if utf8.RuneCountInString(bio) > maxBioLength {
+			return errors.New("bio is too long")
+		}
+		*data.Bio = bio
+	}
+
+	if data.Location != nil {
+		location := strings.TrimSpace(*data.Location)
+		if utf8.RuneCountInString(location) > maxLocationLength {
+			return errors.New("location is too long")
+		}
+		*data.Location = location
+	}
+
+	if data.Website != nil && strings.TrimSpace(*data.Website) != "" {
+		website, err := NormalizeProfileUrl(*data.Website)
+		if err != nil {
+			return err
+		}
+		*data.Website = website
+	} else if data.Website != nil {
+		*data.Website = ""
+	}
+
+	if data.Links != nil {
+		links := []string{}
+		for _, link := range data.Links {
+			if strings.TrimSpace(link) == "" {
+				continue
+			}
+
+			normalized, err := NormalizeProfileUrl(link)
+			if err != nil {
+				return err
+			}
+			links = append(links, normalized)
+		}
+
+		if len(links) > maxProfileLinks {
+			return errors.New("too many links")
+		}
+		data.Links = links
+	}
+
+	return nil
+}
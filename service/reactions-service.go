@@ -0,0 +1,193 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/danielblagy/blog-webapp-server/entity"
+	"github.com/danielblagy/blog-webapp-server/pagination"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const maxReactionTypeLength = 30
+
+var defaultReactionTypes = []string{"like", "love", "clap", "insightful"}
+
+type ReactionsService interface {
+	GetTypes() []string
+	GetCounts(articleId int) (map[string]int, error)
+	GetUserReactions(articleId int, userId int) ([]string, error)
+	GetByArticle(articleId string, reactionType string, params pagination.Params) ([]entity.Reaction, pagination.Cursors, error)
+	Add(userId string, articleId string, reactionType string) error
+	Remove(userId string, articleId string, reactionType string) error
+	DeleteByArticle(articleId string) error
+}
+
+type ReactionsServiceProvider struct {
+	database *gorm.DB
+	types    []string
+}
+
+func CreateReactionsService(database *gorm.DB, types []string) ReactionsService {
+	return &ReactionsServiceProvider{
+		database: database,
+		types:    types,
+	}
+}
+
+// Reads the comma-separated reaction types from REACTION_TYPES env variable, e.g. "like,love,clap".
+// Types are normalized like tags, the default types are used if it's not set.
+// Removing a type hides its reactions, they are kept in the database.
+func ReactionTypesFromEnv() ([]string, error) {
+	value := os.Getenv("REACTION_TYPES")
+	if value == "" {
+		return defaultReactionTypes, nil
+	}
+
+	types := []string{}
+	seen := map[string]bool{}
+	for _, name := range strings.Split(value, ",") {
+		reactionType := NormalizeTag(name)
+		if reactionType == "" || seen[reactionType] {
+			continue
+		}
+
+		if len(reactionType) > maxReactionTypeLength {
+			return nil, errors.New("reaction type '" + reactionType + "' is too long")
+		}
+
+		seen[reactionType] = true
+		types = append(types, reactionType)
+	}
+
+	if len(types) == 0 {
+		return nil, errors.New("REACTION_TYPES has no reaction types")
+	}
+
+	return types, nil
+}
+
+func (service *ReactionsServiceProvider) GetTypes() []string {
+	return service.types
+}
+
+func (service *ReactionsServiceProvider) isValidType(reactionType string) bool {
+	for _, valid := range service.types {
+		if reactionType == valid {
+			return true
+		}
+	}
+	return false
+}
+
+type reactionCount struct {
+	Type  string
+	Count int
+}
+
+// every reaction type has a count, 0 if there are no reactions of the type
+func (service *ReactionsServiceProvider) GetCounts(articleId int) (map[string]int, error) {
+	var rows []reactionCount
+	result := service.database.Model(&entity.Reaction{}).Select("type, count(*) as count").
+		Where("article_id = ? and type in ?", articleId, service.types).Group("type").Scan(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	counts := map[string]int{}
+	for _, reactionType := range service.types {
+		counts[reactionType] = 0
+	}
+	for _, row := range rows {
+		counts[row.Type] = row.Count
+	}
+
+	return counts, nil
+}
+
+// reaction types the user reacted to the article with, in the configured order
+func (service *ReactionsServiceProvider) GetUserReactions(articleId int, userId int) ([]string, error) {
+	var userTypes []string
+	result := service.database.Model(&entity.Reaction{}).Where("article_id = ? and user_id = ?", articleId, userId).Pluck("type", &userTypes)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	reacted := map[string]bool{}
+	for _, reactionType := range userTypes {
+		reacted[reactionType] = true
+	}
+
+	types := []string{}
+	for _, reactionType := range service.types {
+		if reacted[reactionType] {
+			types = append(types, reactionType)
+		}
+	}
+
+	return types, nil
+}
+
+// reactions to the article with the users who reacted, the newest first,
+// reactionType can be empty to get reactions of all types
+func (service *ReactionsServiceProvider) GetByArticle(articleId string, reactionType string, params pagination.Params) ([]entity.Reaction, pagination.Cursors, error) {
+	query := service.database.Where("article_id = ? and type in ?", articleId, service.types)
+	if reactionType != "" {
+		query = query.Where("type = ?", reactionType)
+	}
+
+	reactions := []entity.Reaction{}
+	result := pagination.Apply(query, params, "created_at", "id").Find(&reactions)
+	if result.Error != nil {
+		return reactions, pagination.Cursors{}, result.Error
+	}
+
+	hasMore := pagination.Trim(params, &reactions)
+
+	// NOTE: users' associated data will not be loaded
+	for i := range reactions {
+		if result := service.database.Where("id = ?", reactions[i].UserId).First(&reactions[i].User); result.Error != nil {
+			return reactions, pagination.Cursors{}, errors.New("failed to load associated data")
+		}
+	}
+
+	cursors := pagination.NewCursors(params, hasMore, len(reactions), func(i int) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: reactions[i].CreatedAt, Id: reactions[i].Id}
+	})
+
+	return reactions, cursors, nil
+}
+
+// adding a reaction the user already has does nothing
+func (service *ReactionsServiceProvider) Add(userId string, articleId string, reactionType string) error {
+	if !service.isValidType(reactionType) {
+		return errors.New("invalid reaction type")
+	}
+
+	iUserId, err := strconv.Atoi(userId)
+	if err != nil {
+		return err
+	}
+
+	iArticleId, err := strconv.Atoi(articleId)
+	if err != nil {
+		return err
+	}
+
+	reaction := entity.Reaction{UserId: iUserId, ArticleId: iArticleId, Type: reactionType}
+	result := service.database.Clauses(clause.OnConflict{DoNothing: true}).Create(&reaction)
+	return result.Error
+}
+
+func (service *ReactionsServiceProvider) Remove(userId string, articleId string, reactionType string) error {
+	result := service.database.Where("user_id = ? and article_id = ? and type = ?", userId, articleId, reactionType).Delete(&entity.Reaction{})
+	return result.Error
+}
+
+func (service *ReactionsServiceProvider) DeleteByArticle(articleId string) error {
+	result := service.database.Where("article_id = ?", articleId).Delete(&entity.Reaction{})
+	return result.Error
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"github.com/danielblagy/blog-webapp-server/diff"
+	"github.com/danielblagy/blog-webapp-server/entity"
+	"github.com/danielblagy/blog-webapp-server/pagination"
+	"gorm.io/gorm"
+)
+
+type RevisionsService interface {
+	GetByArticle(articleId string, params pagination.Params) ([]entity.ArticleRevision, pagination.Cursors, error)
+	GetById(articleId int, id int) (entity.ArticleRevision, error)
+	Create(article entity.Article) (entity.ArticleRevision, error)
+	Diff(from entity.ArticleRevision, to entity.ArticleRevision) entity.RevisionDiff
+	DeleteByArticle(articleId string) error
+}
+
+type RevisionsServiceProvider struct {
+	database *gorm.DB
+}
+
+func CreateRevisionsService(database *gorm.DB) RevisionsService {
+	return &RevisionsServiceProvider{
+		database: database,
+	}
+}
+
+func (service *RevisionsServiceProvider) GetByArticle(articleId string, params pagination.Params) ([]entity.ArticleRevision, pagination.Cursors, error) {
+	revisions := []entity.ArticleRevision{}
+	query := service.database.Where("article_id = ?", articleId)
+	result := pagination.Apply(query, params, "created_at", "id").Find(&revisions)
+	if result.Error != nil {
+		return revisions, pagination.Cursors{}, result.Error
+	}
+
+	hasMore := pagination.Trim(params, &revisions)
+
+	cursors := pagination.NewCursors(params, hasMore, len(revisions), func(i int) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: revisions[i].CreatedAt, Id: revisions[i].Id}
+	})
+
+	return revisions, cursors, nil
+}
+
+func (service *RevisionsServiceProvider) GetById(articleId int, id int) (entity.ArticleRevision, error) {
+	var revision entity.ArticleRevision
+	result := service.database.Where("article_id = ?", articleId).First(&revision, id)
+	return revision, result.Error
+}
+
+// snapshots the article's current state
+func (service *RevisionsServiceProvider) Create(article entity.Article) (entity.ArticleRevision, error) {
+	revision := entity.ArticleRevision{
+		ArticleId: article.Id,
+		Title:     article.Title,
+		Content:   article.Content,
+		Format:    article.Format,
+		Published: article.Published,
+	}
+
+	result := service.database.Create(&revision)
+	return revision, result.Error
+}
+
+func (service *RevisionsServiceProvider) Diff(from entity.ArticleRevision, to entity.ArticleRevision) entity.RevisionDiff {
+	return entity.RevisionDiff{
+		From:    from.Id,
+		To:      to.Id,
+		Title:   diff.Lines(from.Title, to.Title),
+		Content: diff.Lines(from.Content, to.Content),
+	}
+}
+
+func (service *RevisionsServiceProvider) DeleteByArticle(articleId string) error {
+	result := service.database.Where("article_id = ?", articleId).Delete(&entity.ArticleRevision{})
+	return result.Error
+}
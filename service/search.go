@@ -0,0 +1,28 @@
+package service
+
+import (
+	"html"
+	"strings"
+)
+
+// text search configuration used for search columns and queries, 'simple' doesn't do any
+// language-specific stemming, so it works the same for articles written in any language
+const searchConfig = "simple"
+
+// ts_headline wraps matches in these private use characters, so the rest of the text can be
+// html-escaped before replacing them with <mark> tags
+const (
+	highlightStart = "\ue000"
+	highlightStop  = "\ue001"
+)
+
+var (
+	highlightAllOptions = "StartSel=" + highlightStart + ", StopSel=" + highlightStop + ", HighlightAll=true"
+	snippetOptions      = "StartSel=" + highlightStart + ", StopSel=" + highlightStop + ", MaxFragments=3, MaxWords=30, MinWords=10, FragmentDelimiter=\" ... \""
+)
+
+func highlight(headline string) string {
+	escaped := html.EscapeString(headline)
+	escaped = strings.ReplaceAll(escaped, highlightStart, "<mark>")
+	return strings.ReplaceAll(escaped, highlightStop, "</mark>")
+}
@@ -0,0 +1,305 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/danielblagy/blog-webapp-server/auth"
+	"github.com/danielblagy/blog-webapp-server/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxSeriesTitleLength       = 300
+	maxSeriesDescriptionLength = 2000
+)
+
+type SeriesService interface {
+	GetById(id int, viewer auth.Principal) (entity.Series, error)
+	GetByOwner(ownerId string, viewer auth.Principal) ([]entity.Series, error)
+	Create(series entity.Series) (entity.Series, error)
+	Update(id int, updatedData entity.EditableSeriesData) (entity.Series, error)
+	Delete(id int) (entity.Series, error)
+	AddArticle(id int, articleId int) (entity.Series, error)
+	RemoveArticle(id int, articleId int) (entity.Series, error)
+	Reorder(id int, articleIds []int) (entity.Series, error)
+	GetNavigation(articleId int, viewer auth.Principal) (*entity.SeriesNavigation, error)
+}
+
+type SeriesServiceProvider struct {
+	database *gorm.DB
+}
+
+func CreateSeriesService(database *gorm.DB) SeriesService {
+	return &SeriesServiceProvider{
+		database: database,
+	}
+}
+
+// checks the title and the description length
+func IsValidSeriesData(title string, description string) bool {
+	return title != "" && len([]rune(title)) <= maxSeriesTitleLength && len([]rune(description)) <= maxSeriesDescriptionLength
+}
+
+// the owner sees all of the articles, other viewers only the published ones
+func (service *SeriesServiceProvider) loadAssociatedData(series *entity.Series, viewer auth.Principal) error {
+	// NOTE: owner's associated data will not be loaded
+	if result := service.database.Where("id = ?", series.OwnerId).First(&series.Owner); result.Error != nil {
+		return errors.New("failed to load associated data")
+	}
+
+	articles, err := service.getArticles(*series, viewer)
+	if err != nil {
+		return errors.New("failed to load associated data")
+	}
+	series.Articles = articles
+
+	return nil
+}
+
+func (service *SeriesServiceProvider) getArticles(series entity.Series, viewer auth.Principal) ([]entity.SeriesArticle, error) {
+	query := service.database.Table("series_entries").
+		Select("articles.id, articles.title, articles.published, articles.created_at").
+		Joins("join articles on articles.id = series_entries.article_id").
+		Where("series_entries.series_id = ?", series.Id)
+
+	if viewer.UserId != series.OwnerId {
+		query = query.Where("articles.published = true")
+	}
+
+	articles := []entity.SeriesArticle{}
+	result := query.Order("series_entries.position").Scan(&articles)
+	return articles, result.Error
+}
+
+func (service *SeriesServiceProvider) GetById(id int, viewer auth.Principal) (entity.Series, error) {
+	var series entity.Series
+	if result := service.database.First(&series, id); result.Error != nil {
+		return series, result.Error
+	}
+
+	if err := service.loadAssociatedData(&series, viewer); err != nil {
+		return series, err
+	}
+
+	return series, nil
+}
+
+// the newest first
+func (service *SeriesServiceProvider) GetByOwner(ownerId string, viewer auth.Principal) ([]entity.Series, error) {
+	series := []entity.Series{}
+	result := service.database.Where("owner_id = ?", ownerId).Order("created_at desc, id desc").Find(&series)
+	if result.Error != nil {
+		return series, result.Error
+	}
+
+	for i := range series {
+		if err := service.loadAssociatedData(&series[i], viewer); err != nil {
+			return series, err
+		}
+	}
+
+	return series, nil
+}
+
+func (service *SeriesServiceProvider) Create(series entity.Series) (entity.Series, error) {
+	if result := service.database.Create(&series); result.Error != nil {
+		return series, result.Error
+	}
+
+	return service.reload(series.Id)
+}
+
+func (service *SeriesServiceProvider) Update(id int, updatedData entity.EditableSeriesData) (entity.Series, error) {
+	var series entity.Series
+	if result := service.database.First(&series, id); result.Error != nil {
+		return series, result.Error
+	}
+
+	if updatedData.Title != "" {
+		series.Title = updatedData.Title
+	}
+
+	if updatedData.Description != nil {
+		series.Description = *updatedData.Description
+	}
+
+	if result := service.database.Save(&series); result.Error != nil {
+		return series, result.Error
+	}
+
+	return service.reload(series.Id)
+}
+
+// the articles stay, only the series is deleted
+func (service *SeriesServiceProvider) Delete(id int) (entity.Series, error) {
+	series, err := service.reload(id)
+	if err != nil {
+		return series, err
+	}
+
+	err = service.database.Transaction(func(tx *gorm.DB) error {
+		if result := tx.Where("series_id = ?", series.Id).Delete(&entity.SeriesEntry{}); result.Error != nil {
+			return result.Error
+		}
+		return tx.Delete(&entity.Series{}, series.Id).Error
+	})
+
+	return series, err
+}
+
+// adds the article to the end of the series, the caller checks that the article belongs to the owner
+func (service *SeriesServiceProvider) AddArticle(id int, articleId int) (entity.Series, error) {
+	var series entity.Series
+	if result := service.database.First(&series, id); result.Error != nil {
+		return series, result.Error
+	}
+
+	err := service.database.Transaction(func(tx *gorm.DB) error {
+		// lock the series, so articles added at once get different positions
+		if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&entity.Series{}, series.Id); result.Error != nil {
+			return result.Error
+		}
+
+		var count int64
+		if result := tx.Model(&entity.SeriesEntry{}).Where("article_id = ?", articleId).Count(&count); result.Error != nil {
+			return result.Error
+		}
+		if count > 0 {
+			return errors.New("article is already in a series")
+		}
+
+		var last int
+		result := tx.Model(&entity.SeriesEntry{}).Where("series_id = ?", series.Id).Select("coalesce(max(position), 0)").Scan(&last)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Create(&entity.SeriesEntry{SeriesId: series.Id, ArticleId: articleId, Position: last + 1}).Error
+	})
+	if err != nil {
+		return series, err
+	}
+
+	return service.reload(series.Id)
+}
+
+func (service *SeriesServiceProvider) RemoveArticle(id int, articleId int) (entity.Series, error) {
+	result := service.database.Where("series_id = ? and article_id = ?", id, articleId).Delete(&entity.SeriesEntry{})
+	if result.Error != nil {
+		return entity.Series{}, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return entity.Series{}, errors.New("article is not in the series")
+	}
+
+	return service.reload(id)
+}
+
+// articleIds must have every article of the series once, in the new order
+func (service *SeriesServiceProvider) Reorder(id int, articleIds []int) (entity.Series, error) {
+	var series entity.Series
+	if result := service.database.First(&series, id); result.Error != nil {
+		return series, result.Error
+	}
+
+	err := service.database.Transaction(func(tx *gorm.DB) error {
+		var current []int
+		result := tx.Model(&entity.SeriesEntry{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("series_id = ?", series.Id).Pluck("article_id", &current)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		inSeries := map[int]bool{}
+		for _, articleId := range current {
+			inSeries[articleId] = true
+		}
+
+		seen := map[int]bool{}
+		for _, articleId := range articleIds {
+			if !inSeries[articleId] || seen[articleId] {
+				return errors.New("article ids must match the articles of the series")
+			}
+			seen[articleId] = true
+		}
+		if len(seen) != len(inSeries) {
+			return errors.New("article ids must match the articles of the series")
+		}
+
+		for i, articleId := range articleIds {
+			result := tx.Model(&entity.SeriesEntry{}).Where("series_id = ? and article_id = ?", series.Id, articleId).Update("position", i+1)
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return series, err
+	}
+
+	return service.reload(series.Id)
+}
+
+// Returns the place of the article in its series with the previous and the next articles,
+// only the articles the viewer can see are counted. Nil if the article isn't in a series.
+func (service *SeriesServiceProvider) GetNavigation(articleId int, viewer auth.Principal) (*entity.SeriesNavigation, error) {
+	var entry entity.SeriesEntry
+	result := service.database.Where("article_id = ?", articleId).Limit(1).Find(&entry)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	var series entity.Series
+	if result := service.database.First(&series, entry.SeriesId); result.Error != nil {
+		return nil, result.Error
+	}
+
+	articles, err := service.getArticles(series, viewer)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range articles {
+		if articles[i].Id != articleId {
+			continue
+		}
+
+		navigation := &entity.SeriesNavigation{
+			Id:       series.Id,
+			Title:    series.Title,
+			Position: i + 1,
+			Total:    len(articles),
+		}
+		if i > 0 {
+			navigation.Previous = &articles[i-1]
+		}
+		if i < len(articles)-1 {
+			navigation.Next = &articles[i+1]
+		}
+		return navigation, nil
+	}
+
+	// the article itself is hidden from the viewer
+	return nil, nil
+}
+
+// the series as its owner sees it, after a change made by the owner
+func (service *SeriesServiceProvider) reload(id int) (entity.Series, error) {
+	var series entity.Series
+	if result := service.database.First(&series, id); result.Error != nil {
+		return series, result.Error
+	}
+
+	if err := service.loadAssociatedData(&series, auth.Principal{UserId: series.OwnerId}); err != nil {
+		return series, err
+	}
+
+	return series, nil
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/danielblagy/blog-webapp-server/auth"
+	"github.com/danielblagy/blog-webapp-server/entity"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// a used refresh token was presented again, it might have been stolen
+	ErrRefreshTokenReused = errors.New("refresh token was already used, the session is revoked")
+)
+
+type SessionsService interface {
+	Start(userId int, userAgent string, ip string) (entity.Session, string, error)
+	Refresh(refreshToken string, userAgent string, ip string) (entity.Session, string, error)
+	GetByRefreshToken(refreshToken string) (entity.Session, error)
+	GetActive(userId string) ([]entity.Session, error)
+	Revoke(userId string, id string) (entity.Session, error)
+	RevokeAll(userId string) error
+	IsActive(id int) bool
+}
+
+type SessionsServiceProvider struct {
+	database *gorm.DB
+}
+
+func CreateSessionsService(database *gorm.DB) SessionsService {
+	return &SessionsServiceProvider{
+		database: database,
+	}
+}
+
+// issues a new refresh token for the session, only its hash is stored
+func (service *SessionsServiceProvider) issueRefreshToken(sessionId int) (string, error) {
+	token, err := auth.GenerateRandomToken()
+	if err != nil {
+		return "", err
+	}
+
+	refreshToken := entity.RefreshToken{SessionId: sessionId, TokenHash: auth.HashToken(token)}
+	if result := service.database.Create(&refreshToken); result.Error != nil {
+		return "", result.Error
+	}
+
+	return token, nil
+}
+
+func (service *SessionsServiceProvider) revoke(sessionId int) error {
+	now := time.Now()
+	result := service.database.Model(&entity.Session{}).
+		Where("id = ? and revoked_at is null", sessionId).
+		Update("revoked_at", now)
+	return result.Error
+}
+
+// returns the session the refresh token was issued for, the token can be already used
+func (service *SessionsServiceProvider) findByRefreshToken(refreshToken string) (entity.Session, entity.RefreshToken, error) {
+	var session entity.Session
+	var token entity.RefreshToken
+
+	if result := service.database.Where("token_hash = ?", auth.HashToken(refreshToken)).First(&token); result.Error != nil {
+		return session, token, ErrInvalidRefreshToken
+	}
+
+	if result := service.database.First(&session, token.SessionId); result.Error != nil {
+		return session, token, ErrInvalidRefreshToken
+	}
+
+	if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return session, token, ErrInvalidRefreshToken
+	}
+
+	return session, token, nil
+}
+
+// creates a session for the signed in user, returns it with its first refresh token
+func (service *SessionsServiceProvider) Start(userId int, userAgent string, ip string) (entity.Session, string, error) {
+	now := time.Now()
+	session := entity.Session{
+		UserId:     userId,
+		UserAgent:  userAgent,
+		Ip:         ip,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(auth.RefreshTokenDuration),
+	}
+
+	if result := service.database.Create(&session); result.Error != nil {
+		return session, "", result.Error
+	}
+
+	token, err := service.issueRefreshToken(session.Id)
+	return session, token, err
+}
+
+// Exchanges the refresh token for a new one. Each refresh token can be used only once,
+// presenting a used token revokes the whole session.
+func (service *SessionsServiceProvider) Refresh(refreshToken string, userAgent string, ip string) (entity.Session, string, error) {
+	session, token, err := service.findByRefreshToken(refreshToken)
+	if err != nil {
+		return session, "", err
+	}
+
+	// marking the token as used only if it's still unused guards against concurrent refreshes with the same token
+	result := service.database.Model(&entity.RefreshToken{}).
+		Where("id = ? and used = false", token.Id).
+		Update("used", true)
+	if result.Error != nil {
+		return session, "", result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		if err := service.revoke(session.Id); err != nil {
+			return session, "", err
+		}
+		return session, "", ErrRefreshTokenReused
+	}
+
+	session.UserAgent = userAgent
+	session.Ip = ip
+	session.LastUsedAt = time.Now()
+	if result := service.database.Model(&session).Updates(map[string]interface{}{
+		"user_agent":   session.UserAgent,
+		"ip":           session.Ip,
+		"last_used_at": session.LastUsedAt,
+	}); result.Error != nil {
+		return session, "", result.Error
+	}
+
+	newToken, err := service.issueRefreshToken(session.Id)
+	return session, newToken, err
+}
+
+func (service *SessionsServiceProvider) GetByRefreshToken(refreshToken string) (entity.Session, error) {
+	session, _, err := service.findByRefreshToken(refreshToken)
+	return session, err
+}
+
+// returns sessions that are neither revoked nor expired, the most recently used first
+func (service *SessionsServiceProvider) GetActive(userId string) ([]entity.Session, error) {
+	sessions := []entity.Session{}
+	result := service.database.
+		Where("user_id = ? and revoked_at is null and expires_at > ?", userId, time.Now()).
+		Order("last_used_at desc").
+		Find(&sessions)
+	return sessions, result.Error
+}
+
+func (service *SessionsServiceProvider) Revoke(userId string, id string) (entity.Session, error) {
+	var session entity.Session
+	if result := service.database.Where("id = ? and user_id = ? and revoked_at is null", id, userId).First(&session); result.Error != nil {
+		return session, errors.New("session was not found")
+	}
+
+	if err := service.revoke(session.Id); err != nil {
+		return session, err
+	}
+
+	return session, nil
+}
+
+// revokes every session of the user, e.g. when the user is deleted
+func (service *SessionsServiceProvider) RevokeAll(userId string) error {
+	result := service.database.Model(&entity.Session{}).
+		Where("user_id = ? and revoked_at is null", userId).
+		Update("revoked_at", time.Now())
+	return result.Error
+}
+
+// whether the session is neither revoked nor expired
+func (service *SessionsServiceProvider) IsActive(id int) bool {
+	var count int64
+	result := service.database.Model(&entity.Session{}).
+		Where("id = ? and revoked_at is null and expires_at > ?", id, time.Now()).
+		Count(&count)
+	return result.Error == nil && count > 0
+}
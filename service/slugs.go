@@ -0,0 +1,158 @@
+package service
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/danielblagy/blog-webapp-server/entity"
+	"gorm.io/gorm"
+)
+
+const maxSlugLength = 80
+
+// latin spellings of letters that don't have one in ASCII
+var transliterations = map[rune]string{
+	// apostrophes don't split words, "it's" -> "its"
+	'\'': "", '’': "", 'ʼ': "",
+
+	// latin letters with diacritics and ligatures
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'æ': "ae", 'ç': "c", 'ć': "c", 'č': "c", 'ĉ': "c", 'ċ': "c", 'ď': "d", 'đ': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ĕ': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'ĝ': "g", 'ğ': "g", 'ġ': "g", 'ģ': "g", 'ĥ': "h", 'ħ': "h",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'ĭ': "i", 'į': "i", 'ı': "i", 'ĵ': "j", 'ķ': "k",
+	'ĺ': "l", 'ļ': "l", 'ľ': "l", 'ŀ': "l", 'ł': "l", 'ñ': "n", 'ń': "n", 'ņ': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ŏ': "o", 'ő': "o", 'œ': "oe",
+	'ŕ': "r", 'ŗ': "r", 'ř': "r", 'ś': "s", 'ŝ': "s", 'ş': "s", 'š': "s", 'ș': "s", 'ß': "ss",
+	'ţ': "t", 'ť': "t", 'ŧ': "t", 'ț': "t", 'þ': "th",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ũ': "u", 'ū': "u", 'ŭ': "u", 'ů': "u", 'ű': "u", 'ų': "u",
+	'ŵ': "w", 'ý': "y", 'ÿ': "y", 'ŷ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+
+	// cyrillic (russian, ukrainian, belarusian)
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo", 'ж': "zh", 'з': "z",
+	'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r",
+	'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+	'є': "ye", 'і': "i", 'ї': "yi", 'ґ': "g", 'ў': "u",
+
+	// greek
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th", 'ι': "i",
+	'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s",
+	'ς': "s", 'τ': "t", 'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+	'ά': "a", 'έ': "e", 'ή': "i", 'ί': "i", 'ό': "o", 'ύ': "y", 'ώ': "o",
+}
+
+// Turns a title into a lowercase url path segment of ASCII letters, digits and dashes,
+// e.g. "Привет, мир!" -> "privet-mir". Letters without a latin spelling are dropped.
+func Slugify(title string) string {
+	var slug strings.Builder
+	dash := false
+
+	for _, r := range strings.ToLower(title) {
+		latin, ok := transliterations[r]
+		if !ok {
+			latin = string(r)
+		}
+
+		for _, c := range latin {
+			if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+				if dash && slug.Len() > 0 {
+					slug.WriteByte('-')
+				}
+				slug.WriteRune(c)
+				dash = false
+			} else if unicode.IsSpace(c) || unicode.IsPunct(c) || unicode.IsSymbol(c) {
+				dash = true
+			}
+		}
+	}
+
+	result := slug.String()
+	if len(result) > maxSlugLength {
+		result = result[:maxSlugLength]
+		if i := strings.LastIndexByte(result, '-'); i > maxSlugLength/2 {
+			result = result[:i]
+		}
+		result = strings.TrimSuffix(result, "-")
+	}
+
+	if result == "" {
+		return "article"
+	}
+	return result
+}
+
+// Returns a slug for the title that no other article of the author has,
+// taken slugs get a number suffix, e.g. "green-leopards-2".
+func uniqueSlug(database *gorm.DB, authorId int, articleId int, title string) (string, error) {
+	base := Slugify(title)
+
+	var taken []string
+	result := database.Model(&entity.Article{}).
+		Where("author_id = ? and id <> ? and (slug = ? or slug like ?)", authorId, articleId, base, base+"-%").
+		Pluck("slug", &taken)
+	if result.Error != nil {
+		return "", result.Error
+	}
+
+	isTaken := map[string]bool{}
+	for _, slug := range taken {
+		isTaken[slug] = true
+	}
+
+	slug := base
+	for i := 2; isTaken[slug]; i++ {
+		slug = base + "-" + strconv.Itoa(i)
+	}
+
+	return slug, nil
+}
+
+// Sets the slug of the article, the previous slug is kept in the history to redirect from.
+// A slug that's used again is removed from the history, current slugs take precedence.
+func setArticleSlug(tx *gorm.DB, article *entity.Article) error {
+	slug, err := uniqueSlug(tx, article.AuthorId, article.Id, article.Title)
+	if err != nil {
+		return err
+	}
+
+	if slug == article.Slug {
+		return nil
+	}
+
+	if result := tx.Where("author_id = ? and slug = ?", article.AuthorId, slug).Delete(&entity.ArticleSlug{}); result.Error != nil {
+		return result.Error
+	}
+
+	if article.Slug != "" {
+		previous := entity.ArticleSlug{ArticleId: article.Id, AuthorId: article.AuthorId, Slug: article.Slug}
+		if result := tx.Create(&previous); result.Error != nil {
+			return result.Error
+		}
+	}
+
+	article.Slug = slug
+	return tx.Model(&entity.Article{}).Where("id = ?", article.Id).Update("slug", slug).Error
+}
+
+// Generates slugs for the articles created before articles had them, meant to be run on start.
+func GenerateMissingSlugs(database *gorm.DB) error {
+	var articles []entity.Article
+	if result := database.Where("slug = ''").Order("id").Find(&articles); result.Error != nil {
+		return result.Error
+	}
+
+	for i := range articles {
+		if err := setArticleSlug(database, &articles[i]); err != nil {
+			return err
+		}
+	}
+
+	if len(articles) > 0 {
+		log.Printf("Generated slugs for %d articles", len(articles))
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Green Leopards", "green-leopards"},
+		{"  Hello,   World!  ", "hello-world"},
+		{"It's a dog’s life", "its-a-dogs-life"},
+		{"Top 10 cats of 2022", "top-10-cats-of-2022"},
+		{"Crème brûlée", "creme-brulee"},
+		{"Straße", "strasse"},
+		{"Привет, мир!", "privet-mir"},
+		{"Щука і їжак", "shchuka-i-yizhak"},
+		{"Καλημέρα", "kalimera"},
+		{"C++ & Go", "c-go"},
+		{"snake_case-and-dashes", "snake-case-and-dashes"},
+		{"日本語", "article"},
+		{"!!!", "article"},
+		{"", "article"},
+		// cut at the last dash that keeps more than half of the max length
+		{strings.Repeat("word ", 20), strings.TrimSuffix(strings.Repeat("word-", 16), "-")},
+		// a single long word is cut at the max length
+		{strings.Repeat("a", 100), strings.Repeat("a", maxSlugLength)},
+	}
+
+	for _, test := range tests {
+		if got := Slugify(test.title); got != test.want {
+			t.Errorf("Slugify(%q) = %q, want %q", test.title, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/danielblagy/blog-webapp-server/entity"
+	"gorm.io/gorm"
+)
+
+const (
+	maxTagLength      = 50
+	maxTagsPerArticle = 10
+)
+
+type TagsService interface {
+	GetAll() ([]entity.TagCount, error)
+	GetArticleTags(articleId int) ([]string, error)
+	SetArticleTags(articleId int, tags []string) error
+}
+
+type TagsServiceProvider struct {
+	database *gorm.DB
+}
+
+func CreateTagsService(database *gorm.DB) TagsService {
+	return &TagsServiceProvider{
+		database: database,
+	}
+}
+
+// lowercases the tag name and replaces any whitespace with single dashes, e.g. " Machine  Learning" -> "machine-learning"
+func NormalizeTag(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), "-")
+}
+
+// normalizes tag names, removing empty tags and duplicates
+func NormalizeTags(names []string) ([]string, error) {
+	normalized := []string{}
+	seen := map[string]bool{}
+
+	for _, name := range names {
+		tag := NormalizeTag(name)
+		if tag == "" || seen[tag] {
+			continue
+		}
+
+		if len(tag) > maxTagLength {
+			return nil, errors.New("tag is too long")
+		}
+
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	if len(normalized) > maxTagsPerArticle {
+		return nil, errors.New("too many tags")
+	}
+
+	return normalized, nil
+}
+
+// returns all tags that are assigned to at least one published article, the most popular first
+func (service *TagsServiceProvider) GetAll() ([]entity.TagCount, error) {
+	tags := []entity.TagCount{}
+	result := service.database.Table("tags").
+		Select("tags.name as name, count(articles.id) as count").
+		Joins("join article_tags on article_tags.tag_id = tags.id").
+		Joins("join articles on articles.id = article_tags.article_id and articles.published = true").
+		Group("tags.name").
+		Order("count desc, name").
+		Scan(&tags)
+	return tags, result.Error
+}
+
+func (service *TagsServiceProvider) GetArticleTags(articleId int) ([]string, error) {
+	tags := []string{}
+	result := service.database.Table("tags").
+		Joins("join article_tags on article_tags.tag_id = tags.id").
+		Where("article_tags.article_id = ?", articleId).
+		Order("tags.name").
+		Pluck("tags.name", &tags)
+	return tags, result.Error
+}
+
+// replaces the tags of the article, tags are expected to be normalized
+func (service *TagsServiceProvider) SetArticleTags(articleId int, tags []string) error {
+	if result := service.database.Where("article_id = ?", articleId).Delete(&entity.ArticleTag{}); result.Error != nil {
+		return result.Error
+	}
+
+	for _, name := range tags {
+		tag := entity.Tag{Name: name}
+		if result := service.database.Where(entity.Tag{Name: name}).FirstOrCreate(&tag); result.Error != nil {
+			return result.Error
+		}
+
+		if result := service.database.Create(&entity.ArticleTag{ArticleId: articleId, TagId: tag.Id}); result.Error != nil {
+			return result.Error
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,293 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danielblagy/blog-webapp-server/auth"
+	"github.com/danielblagy/blog-webapp-server/entity"
+	"github.com/danielblagy/blog-webapp-server/totp"
+	"gorm.io/gorm"
+)
+
+const (
+	recoveryCodesCount       = 10
+	twoFactorChallengeLength = time.Minute * 5
+	maxChallengeAttempts     = 5
+)
+
+var ErrInvalidTwoFactorCode = errors.New("invalid two factor code")
+
+type TwoFactorStatus struct {
+	Enabled           bool `json:"enabled"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	Uri    string `json:"uri"` // otpauth:// URI to show as a QR code
+}
+
+type TwoFactorService interface {
+	GetStatus(userId string) (TwoFactorStatus, error)
+	IsEnabled(userId string) (bool, error)
+	Enroll(user entity.User) (TwoFactorEnrollment, error)
+	Confirm(userId string, code string) ([]string, error)
+	Disable(userId string, code string) error
+	Verify(userId string, code string) error
+	RegenerateRecoveryCodes(userId string, code string) ([]string, error)
+	StartChallenge(user entity.User) (string, error)
+	CompleteChallenge(token string, code string) (string, error)
+}
+
+type TwoFactorServiceProvider struct {
+	database *gorm.DB
+}
+
+func CreateTwoFactorService(database *gorm.DB) TwoFactorService {
+	return &TwoFactorServiceProvider{
+		database: database,
+	}
+}
+
+// recovery codes are compared without dashes, spaces and case, e.g. "ABCDE-FGHIJ" == "abcdefghij"
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.Join(strings.FieldsFunc(code, func(r rune) bool {
+		return r == '-' || r == ' '
+	}), ""))
+}
+
+// replaces the recovery codes of the user with new ones, returns them, only their hashes are stored
+func (service *TwoFactorServiceProvider) generateRecoveryCodes(userId string) ([]string, error) {
+	id, err := strconv.Atoi(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if result := service.database.Where("user_id = ?", id).Delete(&entity.RecoveryCode{}); result.Error != nil {
+		return nil, result.Error
+	}
+
+	codes := []string{}
+	for i := 0; i < recoveryCodesCount; i++ {
+		bytes := make([]byte, 7)
+		if _, err := rand.Read(bytes); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(bytes))[:10]
+		code = code[:5] + "-" + code[5:]
+
+		if result := service.database.Create(&entity.RecoveryCode{UserId: id, CodeHash: auth.HashToken(normalizeRecoveryCode(code))}); result.Error != nil {
+			return nil, result.Error
+		}
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
+
+func (service *TwoFactorServiceProvider) getEnabled(userId string) (entity.TwoFactor, error) {
+	var twoFactor entity.TwoFactor
+	result := service.database.Where("user_id = ? and enabled = true", userId).First(&twoFactor)
+	if result.Error != nil {
+		return twoFactor, errors.New("two factor authentication is not enabled")
+	}
+	return twoFactor, nil
+}
+
+// the code is accepted only if it's newer than the last accepted one, so it can't be replayed
+func (service *TwoFactorServiceProvider) acceptCounter(userId int, counter int64) bool {
+	result := service.database.Model(&entity.TwoFactor{}).
+		Where("user_id = ? and last_counter < ?", userId, counter).
+		Update("last_counter", counter)
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+func (service *TwoFactorServiceProvider) GetStatus(userId string) (TwoFactorStatus, error) {
+	status := TwoFactorStatus{}
+
+	enabled, err := service.IsEnabled(userId)
+	if err != nil || !enabled {
+		return status, err
+	}
+	status.Enabled = true
+
+	var count int64
+	result := service.database.Model(&entity.RecoveryCode{}).Where("user_id = ? and used_at is null", userId).Count(&count)
+	status.RecoveryCodesLeft = int(count)
+	return status, result.Error
+}
+
+func (service *TwoFactorServiceProvider) IsEnabled(userId string) (bool, error) {
+	var count int64
+	result := service.database.Model(&entity.TwoFactor{}).Where("user_id = ? and enabled = true", userId).Count(&count)
+	return count > 0, result.Error
+}
+
+// Generates a new pending secret, 2FA is enabled once it's confirmed with a code.
+// The issuer shown in authenticator apps is set with TOTP_ISSUER env variable.
+func (service *TwoFactorServiceProvider) Enroll(user entity.User) (TwoFactorEnrollment, error) {
+	enrollment := TwoFactorEnrollment{}
+
+	enabled, err := service.IsEnabled(strconv.Itoa(user.Id))
+	if err != nil {
+		return enrollment, err
+	}
+	if enabled {
+		return enrollment, errors.New("two factor authentication is already enabled")
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return enrollment, err
+	}
+
+	// a pending secret of a previous enrollment is replaced
+	if result := service.database.Where("user_id = ?", user.Id).Delete(&entity.TwoFactor{}); result.Error != nil {
+		return enrollment, result.Error
+	}
+
+	twoFactor := entity.TwoFactor{UserId: user.Id, Secret: secret}
+	if result := service.database.Create(&twoFactor); result.Error != nil {
+		return enrollment, result.Error
+	}
+
+	issuer := os.Getenv("TOTP_ISSUER")
+	if issuer == "" {
+		issuer = "Blog"
+	}
+
+	enrollment.Secret = secret
+	enrollment.Uri = totp.URI(issuer, user.Login, secret)
+	return enrollment, nil
+}
+
+// enables 2FA if the code matches the pending secret, returns the recovery codes
+func (service *TwoFactorServiceProvider) Confirm(userId string, code string) ([]string, error) {
+	var twoFactor entity.TwoFactor
+	if result := service.database.Where("user_id = ?", userId).First(&twoFactor); result.Error != nil {
+		return nil, errors.New("two factor authentication enrollment was not started")
+	}
+
+	if twoFactor.Enabled {
+		return nil, errors.New("two factor authentication is already enabled")
+	}
+
+	counter, ok := totp.Validate(twoFactor.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	now := time.Now()
+	result := service.database.Model(&twoFactor).Updates(map[string]interface{}{
+		"enabled":      true,
+		"enabled_at":   now,
+		"last_counter": counter,
+	})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return service.generateRecoveryCodes(userId)
+}
+
+func (service *TwoFactorServiceProvider) Disable(userId string, code string) error {
+	if err := service.Verify(userId, code); err != nil {
+		return err
+	}
+
+	if result := service.database.Where("user_id = ?", userId).Delete(&entity.RecoveryCode{}); result.Error != nil {
+		return result.Error
+	}
+
+	result := service.database.Where("user_id = ?", userId).Delete(&entity.TwoFactor{})
+	return result.Error
+}
+
+// accepts either a TOTP code or an unused recovery code, which is used up
+func (service *TwoFactorServiceProvider) Verify(userId string, code string) error {
+	twoFactor, err := service.getEnabled(userId)
+	if err != nil {
+		return err
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		counter, ok := totp.Validate(twoFactor.Secret, code, time.Now())
+		if !ok || !service.acceptCounter(twoFactor.UserId, counter) {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	}
+
+	result := service.database.Model(&entity.RecoveryCode{}).
+		Where("user_id = ? and code_hash = ? and used_at is null", twoFactor.UserId, auth.HashToken(normalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidTwoFactorCode
+	}
+
+	return nil
+}
+
+func (service *TwoFactorServiceProvider) RegenerateRecoveryCodes(userId string, code string) ([]string, error) {
+	if err := service.Verify(userId, code); err != nil {
+		return nil, err
+	}
+
+	return service.generateRecoveryCodes(userId)
+}
+
+// issues a short-lived token proving that the user entered the correct password
+func (service *TwoFactorServiceProvider) StartChallenge(user entity.User) (string, error) {
+	return issueAccountToken(service.database, user, auth.PurposeTwoFactor, twoFactorChallengeLength)
+}
+
+// Checks the code for the challenge, returns the id of the user on success.
+// A challenge can be completed once, and allows maxChallengeAttempts codes.
+func (service *TwoFactorServiceProvider) CompleteChallenge(token string, code string) (string, error) {
+	claims, err := auth.ParseAccountToken(token, auth.PurposeTwoFactor)
+	if err != nil {
+		return "", ErrInvalidAccountToken
+	}
+
+	var record entity.AccountToken
+	result := service.database.Where("token_id = ? and purpose = ?", claims.Id, auth.PurposeTwoFactor).First(&record)
+	if result.Error != nil {
+		return "", ErrInvalidAccountToken
+	}
+
+	// the attempt is counted before the code is checked, so requests sent at once can't make more attempts
+	result = service.database.Model(&entity.AccountToken{}).
+		Where("id = ? and used_at is null and attempts < ? and expires_at > ?", record.Id, maxChallengeAttempts, time.Now()).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return "", ErrInvalidAccountToken
+	}
+
+	userId := strconv.Itoa(record.UserId)
+	if err := service.Verify(userId, code); err != nil {
+		return "", err
+	}
+
+	result = service.database.Model(&record).Where("used_at is null").Update("used_at", time.Now())
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return "", ErrInvalidAccountToken
+	}
+
+	return userId, nil
+}
@@ -1,37 +1,58 @@
 package service
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"io"
+	"log"
 	"strconv"
 
+	"github.com/danielblagy/blog-webapp-server/auth"
 	"github.com/danielblagy/blog-webapp-server/entity"
+	"github.com/danielblagy/blog-webapp-server/events"
+	"github.com/danielblagy/blog-webapp-server/imaging"
+	"github.com/danielblagy/blog-webapp-server/pagination"
+	"github.com/danielblagy/blog-webapp-server/storage"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 type UsersService interface {
-	GetAll() ([]entity.User, error)
+	GetAll(params pagination.Params) ([]entity.User, pagination.Cursors, error)
 	GetById(id string, authorized bool) (entity.User, error)
 	GetByLogin(login string) (entity.User, error)
+	GetByEmail(email string) (entity.User, error)
+	GetAccount(id string) (entity.User, error)
 	Create(user entity.User) (entity.User, error)
 	Update(id string, updatedData entity.EditableUserData) (entity.User, error)
 	Delete(id string) (entity.User, error)
 	Follow(userId string, userToFollow string) error
 	Unfollow(userId string, userToUnfollow string) error
-	GetFollowers(id string) ([]entity.User, error)
-	GetFollowing(id string) ([]entity.User, error)
+	GetFollowers(id string, params pagination.Params) ([]entity.User, pagination.Cursors, error)
+	GetFollowing(id string, params pagination.Params) ([]entity.User, pagination.Cursors, error)
 	IsFollowed(userId string, userToCheckId string) (bool, error)
+	Search(query string, limit int) ([]entity.UserSearchResult, error)
+	SetRole(id string, role string) (entity.User, error)
+	SetSuspended(id string, suspended bool) (entity.User, error)
+	SetProfileImage(id string, kind string, data io.ReadSeeker) (entity.User, error)
+	RemoveProfileImage(id string, kind string) (entity.User, error)
 }
 
 type UsersServiceProvider struct {
 	database        *gorm.DB
 	articlesService ArticlesService
+	bus             *events.Bus
+	storage         storage.Storage
 }
 
-func CreateUsersService(database *gorm.DB, articlesService ArticlesService) UsersService {
+func CreateUsersService(database *gorm.DB, articlesService ArticlesService, bus *events.Bus, storage storage.Storage) UsersService {
 	return &UsersServiceProvider{
 		database:        database,
 		articlesService: articlesService,
+		bus:             bus,
+		storage:         storage,
 	}
 }
 
@@ -42,7 +63,7 @@ func (service *UsersServiceProvider) loadAssociatedData(user *entity.User, acces
 
 	// load articles associated data
 	for i := range user.Articles {
-		if err := service.articlesService.LoadAssociatedData(&user.Articles[i]); err != nil {
+		if err := service.articlesService.LoadAssociatedData(&user.Articles[i], auth.Principal{}); err != nil {
 			return err
 		}
 	}
@@ -69,19 +90,32 @@ func (service *UsersServiceProvider) loadAssociatedFollowersData(user *entity.Us
 	return nil
 }
 
-func (service *UsersServiceProvider) GetAll() ([]entity.User, error) {
-	var users []entity.User
-	result := service.database.Find(&users)
+func (service *UsersServiceProvider) GetAll(params pagination.Params) ([]entity.User, pagination.Cursors, error) {
+	return service.getPage(service.database, params)
+}
+
+// fetches the page of users matching the query (the newest users first) and loads their followers data
+func (service *UsersServiceProvider) getPage(query *gorm.DB, params pagination.Params) ([]entity.User, pagination.Cursors, error) {
+	users := []entity.User{}
+	result := pagination.Apply(query, params, "", "id").Find(&users)
+	if result.Error != nil {
+		return users, pagination.Cursors{}, result.Error
+	}
+
+	hasMore := pagination.Trim(params, &users)
 
 	// load users associated data
 	for i := range users {
-		// TODO : handle "failed to load associated data" error in controller
 		if err := service.loadAssociatedFollowersData(&users[i]); err != nil {
-			return users, result.Error
+			return users, pagination.Cursors{}, err
 		}
 	}
 
-	return users, result.Error
+	cursors := pagination.NewCursors(params, hasMore, len(users), func(i int) pagination.Cursor {
+		return pagination.Cursor{Id: users[i].Id}
+	})
+
+	return users, cursors, nil
 }
 
 func (service *UsersServiceProvider) GetById(id string, authorized bool) (entity.User, error) {
@@ -107,12 +141,33 @@ func (service *UsersServiceProvider) GetByLogin(login string) (entity.User, erro
 	return user, result.Error
 }
 
+// email is expected to be normalized
+func (service *UsersServiceProvider) GetByEmail(email string) (entity.User, error) {
+	var user entity.User
+	result := service.database.Where("email = ?", email).First(&user)
+	return user, result.Error
+}
+
+// returns the user without any associated data, used for authorization checks
+func (service *UsersServiceProvider) GetAccount(id string) (entity.User, error) {
+	var user entity.User
+	result := service.database.First(&user, id)
+	return user, result.Error
+}
+
+// users signed up with an OpenID Connect provider have no password, they can't sign in with one
 func (service *UsersServiceProvider) Create(user entity.User) (entity.User, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), 0)
-	if err != nil {
-		return user, err
+	if user.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), 0)
+		if err != nil {
+			return user, err
+		}
+		user.Password = string(hash)
+	}
+
+	if user.Links == nil {
+		user.Links = []string{}
 	}
-	user.Password = string(hash)
 
 	result := service.database.Create(&user)
 	return user, result.Error
@@ -134,14 +189,85 @@ func (service *UsersServiceProvider) Update(id string, updatedData entity.Editab
 		user.Password = string(hash)
 	}
 
+	// email is expected to be normalized
+	if updatedData.Email != "" && (user.Email == nil || *user.Email != updatedData.Email) {
+		email := updatedData.Email
+		user.Email = &email
+		user.EmailVerified = false
+	}
+
+	// profile fields are expected to be normalized
+	if updatedData.Bio != nil {
+		user.Bio = *updatedData.Bio
+	}
+
+	if updatedData.Website != nil {
+		user.Website = *updatedData.Website
+	}
+
+	if updatedData.Links != nil {
+		user.Links = updatedData.Links
+	}
+
+	if updatedData.Location != nil {
+		user.Location = *updatedData.Location
+	}
+
 	result := service.database.Save(&user)
 	return user, result.Error
 }
 
 func (service *UsersServiceProvider) Delete(id string) (entity.User, error) {
 	user, _ := service.GetById(id, true) // getting the user before deleting to return
+
+	// linked identities would sign in to the deleted account
+	if result := service.database.Where("user_id = ?", id).Delete(&entity.Identity{}); result.Error != nil {
+		return user, result.Error
+	}
+
+	if result := service.database.Where("user_id = ?", id).Delete(&entity.Reaction{}); result.Error != nil {
+		return user, result.Error
+	}
+
+	if result := service.database.Where("user_id = ? or actor_id = ?", id, id).Delete(&entity.Notification{}); result.Error != nil {
+		return user, result.Error
+	}
+
+	if result := service.database.Where("user_id = ?", id).Delete(&entity.NotificationPreference{}); result.Error != nil {
+		return user, result.Error
+	}
+
+	ownedSeries := service.database.Table("series").Where("owner_id = ?", id).Select("id")
+	if result := service.database.Where("series_id in (?)", ownedSeries).Delete(&entity.SeriesEntry{}); result.Error != nil {
+		return user, result.Error
+	}
+
+	if result := service.database.Where("owner_id = ?", id).Delete(&entity.Series{}); result.Error != nil {
+		return user, result.Error
+	}
+
+	ownedCollections := service.database.Table("collections").Where("owner_id = ?", id).Select("id")
+	if result := service.database.Where("collection_id in (?)", ownedCollections).Delete(&entity.CollectionItem{}); result.Error != nil {
+		return user, result.Error
+	}
+
+	if result := service.database.Where("collection_id in (?) or user_id = ?", ownedCollections, id).Delete(&entity.CollectionFollower{}); result.Error != nil {
+		return user, result.Error
+	}
+
+	if result := service.database.Where("owner_id = ?", id).Delete(&entity.Collection{}); result.Error != nil {
+		return user, result.Error
+	}
+
 	result := service.database.Delete(&entity.User{}, id)
-	return user, result.Error
+	if result.Error != nil {
+		return user, result.Error
+	}
+
+	service.deleteImages(user.Avatar)
+	service.deleteImages(user.Header)
+
+	return user, nil
 }
 
 func (service *UsersServiceProvider) Follow(userId string, userToFollow string) error {
@@ -156,7 +282,12 @@ func (service *UsersServiceProvider) Follow(userId string, userToFollow string)
 	}
 
 	result := service.database.Create(&entity.Follower{FollowerId: iUserId, FollowsId: iUserToFollow})
-	return result.Error
+	if result.Error != nil {
+		return result.Error
+	}
+
+	service.bus.Publish(events.Event{Type: events.TypeFollow, ActorId: iUserId, UserId: iUserToFollow})
+	return nil
 }
 
 func (service *UsersServiceProvider) Unfollow(userId string, userToUnfollow string) error {
@@ -164,45 +295,195 @@ func (service *UsersServiceProvider) Unfollow(userId string, userToUnfollow stri
 	return result.Error
 }
 
-func (service *UsersServiceProvider) GetFollowers(id string) ([]entity.User, error) {
-	var followersIds []int
-	result := service.database.Table("followers").Where("follows_id = ?", id).Select("follower_id").Find(&followersIds)
+func (service *UsersServiceProvider) GetFollowers(id string, params pagination.Params) ([]entity.User, pagination.Cursors, error) {
+	followersIds := service.database.Table("followers").Where("follows_id = ?", id).Select("follower_id")
+	return service.getPage(service.database.Where("id in (?)", followersIds), params)
+}
 
-	var users []entity.User
-	result = service.database.Where("id in ?", followersIds).Find(&users)
+func (service *UsersServiceProvider) GetFollowing(id string, params pagination.Params) ([]entity.User, pagination.Cursors, error) {
+	followingIds := service.database.Table("followers").Where("follower_id = ?", id).Select("follows_id")
+	return service.getPage(service.database.Where("id in (?)", followingIds), params)
+}
 
-	// load users associated data
-	for i := range users {
-		// TODO : handle "failed to load associated data" error in controller
-		if err := service.loadAssociatedFollowersData(&users[i]); err != nil {
-			return users, result.Error
+func (service *UsersServiceProvider) IsFollowed(userId string, userToCheckId string) (bool, error) {
+	var followingIds []int
+	result := service.database.Table("followers").Where("follower_id = ? and follows_id = ?", userId, userToCheckId).Select("follows_id").Find(&followingIds)
+
+	return result.RowsAffected > 0, result.Error
+}
+
+type userSearchRow struct {
+	entity.User
+	Rank              float64
+	LoginHighlight    string
+	FullNameHighlight string
+}
+
+// full-text search over logins and full names, the most relevant users first
+func (service *UsersServiceProvider) Search(query string, limit int) ([]entity.UserSearchResult, error) {
+	var rows []userSearchRow
+	result := service.database.Raw(`
+		select users.*, ts_rank(users.search_vector, search_query) as rank,
+			ts_headline(?::regconfig, users.login, search_query, ?) as login_highlight,
+			ts_headline(?::regconfig, users.full_name, search_query, ?) as full_name_highlight
+		from users, websearch_to_tsquery(?::regconfig, ?) search_query
+		where users.search_vector @@ search_query
+		order by rank desc, users.id desc
+		limit ?`,
+		searchConfig, highlightAllOptions, searchConfig, highlightAllOptions, searchConfig, query, limit).Scan(&rows)
+	if result.Error != nil {
+		return []entity.UserSearchResult{}, result.Error
+	}
+
+	results := make([]entity.UserSearchResult, len(rows))
+	for i, row := range rows {
+		user := row.User
+		if err := service.loadAssociatedFollowersData(&user); err != nil {
+			return []entity.UserSearchResult{}, err
+		}
+
+		results[i] = entity.UserSearchResult{
+			User:              user,
+			Rank:              row.Rank,
+			LoginHighlight:    highlight(row.LoginHighlight),
+			FullNameHighlight: highlight(row.FullNameHighlight),
 		}
 	}
 
-	return users, result.Error
+	return results, nil
 }
 
-func (service *UsersServiceProvider) GetFollowing(id string) ([]entity.User, error) {
-	var followingIds []int
-	result := service.database.Table("followers").Where("follower_id = ?", id).Select("follows_id").Find(&followingIds)
+func (service *UsersServiceProvider) SetRole(id string, role string) (entity.User, error) {
+	if !entity.IsValidRole(role) {
+		return entity.User{}, errors.New("invalid role")
+	}
 
-	var users []entity.User
-	result = service.database.Where("id in ?", followingIds).Find(&users)
+	user, err := service.GetAccount(id)
+	if err != nil {
+		return user, err
+	}
 
-	// load users associated data
-	for i := range users {
-		// TODO : handle "failed to load associated data" error in controller
-		if err := service.loadAssociatedFollowersData(&users[i]); err != nil {
-			return users, result.Error
+	user.Role = role
+	result := service.database.Model(&user).Update("role", role)
+	return user, result.Error
+}
+
+// suspended users can't sign in or refresh their tokens
+func (service *UsersServiceProvider) SetSuspended(id string, suspended bool) (entity.User, error) {
+	user, err := service.GetAccount(id)
+	if err != nil {
+		return user, err
+	}
+
+	user.Suspended = suspended
+	result := service.database.Model(&user).Update("suspended", suspended)
+	return user, result.Error
+}
+
+// Promotes the user with the login to admin, if they aren't already. Used at startup to
+// bootstrap the first admin from configuration, the promotion is recorded in the audit log.
+func BootstrapAdmin(usersService UsersService, auditService AuditService, login string) error {
+	user, err := usersService.GetByLogin(login)
+	if err != nil {
+		return err
+	}
+
+	if user.Role == entity.RoleAdmin {
+		return nil
+	}
+
+	if _, err := usersService.SetRole(strconv.Itoa(user.Id), entity.RoleAdmin); err != nil {
+		return err
+	}
+
+	return auditService.Record(nil, AuditActionChangeRole, "user", user.Id, user.Role+" -> "+entity.RoleAdmin+" (bootstrapped from configuration)")
+}
+
+// Resizes the image to the sizes of the profile image kind and replaces the user's current image.
+// The keys are random, so clients never get an old image from a cache.
+// The image is re-encoded, so no metadata like EXIF is kept.
+func (service *UsersServiceProvider) SetProfileImage(id string, kind string, data io.ReadSeeker) (entity.User, error) {
+	var user entity.User
+	if result := service.database.First(&user, id); result.Error != nil {
+		return user, result.Error
+	}
+
+	sizes, ok := profileImageSizes[kind]
+	if !ok {
+		return user, errors.New("invalid profile image")
+	}
+
+	img, err := imaging.Decode(data)
+	if err != nil {
+		return user, err
+	}
+
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		return user, err
+	}
+	prefix := kind + "s/" + strconv.Itoa(user.Id) + "/" + hex.EncodeToString(random)
+
+	images := entity.ImageSet{}
+	for _, size := range sizes {
+		var encoded bytes.Buffer
+		if err := imaging.EncodeJPEG(&encoded, imaging.Fill(img, size.width, size.height)); err != nil {
+			service.deleteImages(images)
+			return user, err
 		}
+
+		key := prefix + "-" + size.name + ".jpg"
+		if err := service.storage.Put(key, "image/jpeg", &encoded); err != nil {
+			service.deleteImages(images)
+			return user, err
+		}
+		images[size.name] = key
+	}
+
+	previous := user.Avatar
+	if kind == entity.ProfileImageHeader {
+		previous = user.Header
 	}
 
-	return users, result.Error
+	if result := service.database.Model(&user).Update(kind, images); result.Error != nil {
+		service.deleteImages(images)
+		return user, result.Error
+	}
+
+	service.deleteImages(previous)
+
+	return service.GetById(id, true)
 }
 
-func (service *UsersServiceProvider) IsFollowed(userId string, userToCheckId string) (bool, error) {
-	var followingIds []int
-	result := service.database.Table("followers").Where("follower_id = ? and follows_id = ?", userId, userToCheckId).Select("follows_id").Find(&followingIds)
+func (service *UsersServiceProvider) RemoveProfileImage(id string, kind string) (entity.User, error) {
+	var user entity.User
+	if result := service.database.First(&user, id); result.Error != nil {
+		return user, result.Error
+	}
 
-	return result.RowsAffected > 0, result.Error
+	if !IsValidProfileImage(kind) {
+		return user, errors.New("invalid profile image")
+	}
+
+	previous := user.Avatar
+	if kind == entity.ProfileImageHeader {
+		previous = user.Header
+	}
+
+	if result := service.database.Model(&user).Update(kind, entity.ImageSet(nil)); result.Error != nil {
+		return user, result.Error
+	}
+
+	service.deleteImages(previous)
+
+	return service.GetById(id, true)
+}
+
+// the files are not needed anymore, so failing to delete them is only logged
+func (service *UsersServiceProvider) deleteImages(images entity.ImageSet) {
+	for _, key := range images {
+		if err := service.storage.Delete(key); err != nil {
+			log.Printf("Failed to delete image '%s': %s", key, err.Error())
+		}
+	}
 }
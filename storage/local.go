@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Keeps files in a directory on the server, they are served by the router at /uploads
+type LocalStorage struct {
+	Directory string
+	baseUrl   string
+}
+
+func CreateLocalStorage(directory string, baseUrl string) (*LocalStorage, error) {
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return nil, err
+	}
+
+	return &LocalStorage{
+		Directory: directory,
+		baseUrl:   baseUrl,
+	}, nil
+}
+
+// keys can't point outside of the directory
+func (storage *LocalStorage) path(key string) (string, error) {
+	cleaned := path.Clean("/" + key)
+	if cleaned == "/" || cleaned != "/"+key {
+		return "", errors.New("invalid storage key")
+	}
+	return filepath.Join(storage.Directory, filepath.FromSlash(strings.TrimPrefix(cleaned, "/"))), nil
+}
+
+// the file is written under a temporary name first, so it's never served partially written
+func (storage *LocalStorage) Put(key string, contentType string, data io.Reader) error {
+	filePath, err := storage.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+
+	temp, err := ioutil.TempFile(filepath.Dir(filePath), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := io.Copy(temp, data); err != nil {
+		temp.Close()
+		return err
+	}
+
+	if err := temp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(temp.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), filePath)
+}
+
+func (storage *LocalStorage) Get(key string) (io.ReadCloser, error) {
+	filePath, err := storage.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(filePath)
+}
+
+// deleting a missing file is not an error
+func (storage *LocalStorage) Delete(key string) error {
+	filePath, err := storage.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (storage *LocalStorage) Url(key string) string {
+	return storage.baseUrl + "/" + key
+}
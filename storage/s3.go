@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Keeps files in a bucket of S3 or an S3-compatible service (e.g. MinIO), requests are signed
+// with AWS Signature Version 4. The bucket must allow public reads, files are linked directly.
+type S3Storage struct {
+	endpoint        *url.URL
+	region          string
+	bucket          string
+	accessKeyId     string
+	secretAccessKey string
+	pathStyle       bool   // bucket in the path (endpoint/bucket/key) instead of the host (bucket.endpoint/key)
+	publicUrl       string // url prefix of the files, e.g. a CDN
+	client          *http.Client
+}
+
+type S3Config struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyId     string
+	SecretAccessKey string
+	PathStyle       bool
+	PublicUrl       string // the bucket's url if empty
+}
+
+func CreateS3Storage(config S3Config) (*S3Storage, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(config.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("invalid S3 endpoint '%s'", config.Endpoint)
+	}
+
+	storage := &S3Storage{
+		endpoint:        endpoint,
+		region:          config.Region,
+		bucket:          config.Bucket,
+		accessKeyId:     config.AccessKeyId,
+		secretAccessKey: config.SecretAccessKey,
+		pathStyle:       config.PathStyle,
+		publicUrl:       strings.TrimSuffix(config.PublicUrl, "/"),
+		client:          &http.Client{Timeout: time.Minute},
+	}
+
+	if storage.publicUrl == "" {
+		storage.publicUrl = strings.TrimSuffix(storage.objectUrl("").String(), "/")
+	}
+
+	return storage, nil
+}
+
+func (storage *S3Storage) objectUrl(key string) *url.URL {
+	objectUrl := *storage.endpoint
+	if storage.pathStyle {
+		objectUrl.Path = "/" + storage.bucket + "/" + key
+	} else {
+		objectUrl.Host = storage.bucket + "." + objectUrl.Host
+		objectUrl.Path = "/" + key
+	}
+	objectUrl.RawPath = uriEncode(objectUrl.Path, false)
+	return &objectUrl
+}
+
+// files get random keys and never change, so they can be cached forever
+func (storage *S3Storage) Put(key string, contentType string, data io.Reader) error {
+	body, err := ioutil.ReadAll(data)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPut, storage.objectUrl(key).String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", contentType)
+	request.Header.Set("Cache-Control", "public, max-age=31536000, immutable")
+
+	return storage.do(request, body)
+}
+
+func (storage *S3Storage) Get(key string) (io.ReadCloser, error) {
+	request, err := http.NewRequest(http.MethodGet, storage.objectUrl(key).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := storage.send(request, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Body, nil
+}
+
+// deleting a missing file is not an error
+func (storage *S3Storage) Delete(key string) error {
+	request, err := http.NewRequest(http.MethodDelete, storage.objectUrl(key).String(), nil)
+	if err != nil {
+		return err
+	}
+
+	return storage.do(request, nil)
+}
+
+func (storage *S3Storage) Url(key string) string {
+	return storage.publicUrl + "/" + uriEncode(key, false)
+}
+
+func (storage *S3Storage) do(request *http.Request, body []byte) error {
+	response, err := storage.send(request, body)
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+// signs and sends the request, the response body is left open only for successful responses
+func (storage *S3Storage) send(request *http.Request, body []byte) (*http.Response, error) {
+	storage.sign(request, body, time.Now())
+
+	response, err := storage.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		defer response.Body.Close()
+		message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1024))
+		return nil, fmt.Errorf("S3 %s request failed with status %d: %s", request.Method, response.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	return response, nil
+}
+
+// Adds AWS Signature Version 4 authorization, all headers set on the request are signed
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func (storage *S3Storage) sign(request *http.Request, body []byte, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	payloadHash := sha256Hex(body)
+
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		canonicalQuery(request.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + storage.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSha256([]byte("AWS4"+storage.secretAccessKey), date)
+	key = hmacSha256(key, storage.region)
+	key = hmacSha256(key, "s3")
+	key = hmacSha256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSha256(key, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		storage.accessKeyId, scope, signedHeaders, signature))
+}
+
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := []string{}
+	for _, name := range names {
+		values := query[name]
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// percent-encodes everything except unreserved characters, and slashes unless encodeSlash is true
+func uriEncode(value string, encodeSlash bool) string {
+	var encoded strings.Builder
+	for _, b := range []byte(value) {
+		if (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9') ||
+			b == '-' || b == '_' || b == '.' || b == '~' || (b == '/' && !encodeSlash) {
+			encoded.WriteByte(b)
+		} else {
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return encoded.String()
+}
+
+func sha256Hex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// Keeps uploaded files, keys are slash-separated paths like "avatars/12/5f0c-small.jpg"
+type Storage interface {
+	Put(key string, contentType string, data io.Reader) error
+	Get(key string) (io.ReadCloser, error) // the caller closes the reader
+	Delete(key string) error
+	Url(key string) string
+}
+
+// The driver is set with STORAGE_DRIVER env variable:
+// 'local' (default) keeps files in UPLOADS_DIRECTORY ("uploads" by default) and links them
+// with UPLOADS_URL as the url prefix ("/uploads" by default),
+// 's3' keeps them in S3_BUCKET, see CreateS3Storage.
+func CreateStorageFromEnv() (Storage, error) {
+	switch os.Getenv("STORAGE_DRIVER") {
+	case "", "local":
+		directory := os.Getenv("UPLOADS_DIRECTORY")
+		if directory == "" {
+			directory = "uploads"
+		}
+
+		baseUrl := strings.TrimSuffix(os.Getenv("UPLOADS_URL"), "/")
+		if baseUrl == "" {
+			baseUrl = "/uploads"
+		}
+
+		local, err := CreateLocalStorage(directory, baseUrl)
+		if err != nil {
+			return nil, err
+		}
+		return local, nil
+	case "s3":
+		config := S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyId:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			PathStyle:       os.Getenv("S3_PATH_STYLE") == "true",
+			PublicUrl:       os.Getenv("S3_PUBLIC_URL"),
+		}
+
+		if config.Region == "" {
+			config.Region = "us-east-1"
+		}
+		if config.Endpoint == "" {
+			config.Endpoint = "https://s3." + config.Region + ".amazonaws.com"
+		}
+		if config.Bucket == "" || config.AccessKeyId == "" || config.SecretAccessKey == "" {
+			return nil, errors.New("S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY must be set")
+		}
+
+		s3, err := CreateS3Storage(config)
+		if err != nil {
+			return nil, err
+		}
+		return s3, nil
+	default:
+		return nil, errors.New("unknown STORAGE_DRIVER '" + os.Getenv("STORAGE_DRIVER") + "'")
+	}
+}
@@ -0,0 +1,105 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// time-based one-time passwords (RFC 6238) with the parameters authenticator apps use by default:
+// HMAC-SHA1, 6 digits, 30 second period
+const (
+	Digits = 6
+	Period = 30 // seconds
+
+	// codes of the previous and the next periods are accepted as well, to allow for clock drift
+	skew = 1
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// returns a random 160-bit secret encoded with base32, the way authenticator apps expect it
+func GenerateSecret() (string, error) {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(secret), nil
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	return encoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+}
+
+// the number of the period the time is in
+func Counter(t time.Time) int64 {
+	return t.Unix() / Period
+}
+
+// HOTP value (RFC 4226) for the counter
+func hotp(key []byte, counter int64) string {
+	message := make([]byte, 8)
+	binary.BigEndian.PutUint64(message, uint64(counter))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", Digits, value%1000000)
+}
+
+// returns the code for the time
+func Code(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, Counter(t)), nil
+}
+
+// Checks the code against the periods around the time. Returns the counter of the matched period,
+// callers should store it and reject codes with the same or lower counter, so a code can't be used twice.
+func Validate(secret string, code string, t time.Time) (int64, bool) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, false
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) != Digits {
+		return 0, false
+	}
+
+	counter := Counter(t)
+	for i := int64(-skew); i <= skew; i++ {
+		if subtle.ConstantTimeCompare([]byte(hotp(key, counter+i)), []byte(code)) == 1 {
+			return counter + i, true
+		}
+	}
+
+	return 0, false
+}
+
+// otpauth:// URI for authenticator apps, usually shown as a QR code
+func URI(issuer string, account string, secret string) string {
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(Digits))
+	query.Set("period", fmt.Sprint(Period))
+
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
@@ -0,0 +1,64 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B, HMAC-SHA1 with the ASCII secret "12345678901234567890",
+// 6 digit codes are the last 6 digits of the 8 digit test values
+func TestCode(t *testing.T) {
+	secret := encoding.EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, test := range tests {
+		code, err := Code(secret, time.Unix(test.unix, 0))
+		if err != nil {
+			t.Fatalf("Code(%d) returned an error: %s", test.unix, err)
+		}
+		if code != test.code {
+			t.Errorf("Code(%d) = %s, want %s", test.unix, code, test.code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret := encoding.EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(1111111111, 0)
+
+	tests := []struct {
+		name    string
+		code    string
+		counter int64
+		ok      bool
+	}{
+		{"current period", "050471", Counter(now), true},
+		{"previous period", "081804", Counter(now) - 1, true},
+		{"surrounding spaces", " 050471 ", Counter(now), true},
+		{"wrong code", "123456", 0, false},
+		{"too short", "05047", 0, false},
+		{"too long", "0504710", 0, false},
+	}
+
+	for _, test := range tests {
+		counter, ok := Validate(secret, test.code, now)
+		if ok != test.ok || counter != test.counter {
+			t.Errorf("%s: Validate() = %d, %t, want %d, %t", test.name, counter, ok, test.counter, test.ok)
+		}
+	}
+
+	if _, ok := Validate("not base32!", "050471", now); ok {
+		t.Errorf("Validate() accepted an invalid secret")
+	}
+}